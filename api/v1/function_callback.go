@@ -1,57 +1,54 @@
 package v1
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	resp "github.com/yunhanshu-net/function-go/pkg/dto/response"
-	"github.com/yunhanshu-net/function-server/pkg/dto/runcher"
 	"github.com/yunhanshu-net/function-server/pkg/response"
+	"github.com/yunhanshu-net/function-server/service"
 	"io"
 	"net/http"
 )
 
 func (r *Functions) Callback(c *gin.Context) {
-	req := &runcher.RunFunctionReq{
-		User:   c.Param("user"),
-		Method: http.MethodPost,
-		Runner: c.Param("runner"),
-		Router: "_callback",
-	}
-	rn, err := r.runner.GetByUserName(c, req.User, req.Runner)
+	rn, err := r.runner.GetByUserName(c, c.Param("user"), c.Param("runner"))
 	if err != nil {
 		response.ParamError(c, fmt.Sprintf("获取runner失败：%s", err.Error()))
 		return
 	}
-	req.Version = rn.Version
 	all, err := io.ReadAll(c.Request.Body)
 	if err != nil {
 		response.ServerError(c, err.Error())
 		return
 	}
-	req.Body = string(all)
-	function2, err := r.runcher.RunFunction2(c, req)
-	if err != nil {
-		response.ServerError(c, err.Error())
-		return
+
+	result, err := r.funcRun.Callback(c, &service.CallbackOptions{
+		Runner:   rn,
+		Router:   c.Param("router"),
+		Method:   c.Query("method"),
+		Type:     c.Query("type"),
+		Body:     all,
+		Operator: c.GetString("user"),
+	})
+	if result != nil {
+		ctx := c.Copy()
+		go r.runnerFunc.SaveRunRecord(ctx, result.Func, result.Record)
 	}
-	var res resp.RunFunctionResp
-	err = json.Unmarshal(function2.Data, &res)
 	if err != nil {
+		if errors.Is(err, service.ErrRuncherUnavailable) {
+			response.Unavailable(c, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrCallbackInvalid) {
+			response.ParamError(c, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrRunnerNotRunning) || errors.Is(err, service.ErrFuncDisabled) {
+			response.Conflict(c, err.Error())
+			return
+		}
 		response.ServerError(c, err.Error())
 		return
 	}
-	if res.MetaData == nil {
-		res.MetaData = make(map[string]interface{})
-	}
-	for k, v := range function2.Header {
-		if k != "code" {
-			if len(v) > 0 {
-				res.MetaData[k] = v[0]
-			}
-		}
-	}
-	res.MetaData["version"] = rn.Version
-	c.JSON(http.StatusOK, res)
-
+	c.JSON(http.StatusOK, result.Resp)
 }
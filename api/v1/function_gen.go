@@ -56,10 +56,14 @@ func (api *RunnerFuncAPI) GeneratingList(c *gin.Context) {
 		return
 	}
 	var list []model.FunctionGen
-	table, err := query.AutoPaginateTable(c,
-		db.GetDB().
-			Where("runner_id = ?", req.RunnerID),
-		&model.FunctionGen{}, &list, &req.PageInfoReq)
+	tx := db.GetDB().Where("runner_id = ?", req.RunnerID)
+	if req.Status != "" {
+		tx = tx.Where("status = ?", req.Status)
+	}
+	if req.Reviewer != "" {
+		tx = tx.Where("reviewer = ?", req.Reviewer)
+	}
+	table, err := query.AutoPaginateTable(c, tx, &model.FunctionGen{}, &list, &req.PageInfoReq)
 	if err != nil {
 		response.ServerError(c, err.Error())
 		return
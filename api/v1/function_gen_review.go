@@ -0,0 +1,130 @@
+package v1
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yunhanshu-net/function-server/pkg/dto"
+	"github.com/yunhanshu-net/function-server/pkg/response"
+	"github.com/yunhanshu-net/function-server/service"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// FunctionGenReviewAPI 生成函数审核API控制器
+type FunctionGenReviewAPI struct {
+	service *service.FunctionGenReview
+}
+
+// NewFunctionGenReviewAPI 创建生成函数审核API控制器
+func NewFunctionGenReviewAPI(db *gorm.DB) *FunctionGenReviewAPI {
+	return &FunctionGenReviewAPI{service: service.NewFunctionGenReview(db)}
+}
+
+// Approve 审核通过，启用生成的函数
+func (api *FunctionGenReviewAPI) Approve(c *gin.Context) {
+	api.review(c, service.ReviewActionApprove)
+}
+
+// Reject 拒绝
+func (api *FunctionGenReviewAPI) Reject(c *gin.Context) {
+	api.review(c, service.ReviewActionReject)
+}
+
+// RequestChanges 要求修改
+func (api *FunctionGenReviewAPI) RequestChanges(c *gin.Context) {
+	api.review(c, service.ReviewActionRequestChanges)
+}
+
+// Submit 修改后重新提交审核
+func (api *FunctionGenReviewAPI) Submit(c *gin.Context) {
+	api.review(c, service.ReviewActionSubmit)
+}
+
+func (api *FunctionGenReviewAPI) review(c *gin.Context, action string) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的ID")
+		return
+	}
+	var req dto.ReviewFunctionGenReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, "参数解析失败: "+err.Error())
+		return
+	}
+	gen, err := api.service.Review(c, id, action, req.Comment, c.GetString("user"))
+	if err != nil {
+		logger.Error(c, "审核生成函数失败", err, zap.Int64("id", id), zap.String("action", action))
+		switch {
+		case errors.Is(err, service.ErrNotReviewer):
+			response.Forbidden(c, err.Error())
+		case errors.Is(err, service.ErrFunctionGenReview):
+			response.Conflict(c, err.Error())
+		default:
+			response.ServerError(c, "审核失败: "+err.Error())
+		}
+		return
+	}
+	response.Success(c, gen)
+}
+
+// Assign 指定审核人
+func (api *FunctionGenReviewAPI) Assign(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的ID")
+		return
+	}
+	var req dto.AssignReviewerReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, "参数解析失败: "+err.Error())
+		return
+	}
+	gen, err := api.service.Assign(c, id, req.Reviewer, c.GetString("user"))
+	if err != nil {
+		if errors.Is(err, service.ErrFunctionGenReview) {
+			response.Conflict(c, err.Error())
+			return
+		}
+		response.ServerError(c, "指定审核人失败: "+err.Error())
+		return
+	}
+	response.Success(c, gen)
+}
+
+// Comment 添加评论
+func (api *FunctionGenReviewAPI) Comment(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的ID")
+		return
+	}
+	var req dto.ReviewFunctionGenReq
+	if err := c.ShouldBindJSON(&req); err != nil || req.Comment == "" {
+		response.ParamError(c, "评论内容不能为空")
+		return
+	}
+	review, err := api.service.Comment(c, id, req.Comment, c.GetString("user"))
+	if err != nil {
+		response.ServerError(c, "添加评论失败: "+err.Error())
+		return
+	}
+	response.Success(c, review)
+}
+
+// Reviews 获取审核记录和评论
+func (api *FunctionGenReviewAPI) Reviews(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的ID")
+		return
+	}
+	reviews, err := api.service.Reviews(c, id)
+	if err != nil {
+		response.ServerError(c, "获取审核记录失败")
+		return
+	}
+	response.Success(c, reviews)
+}
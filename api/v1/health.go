@@ -0,0 +1,35 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yunhanshu-net/function-server/pkg/dto"
+	"github.com/yunhanshu-net/function-server/service"
+	"gorm.io/gorm"
+)
+
+// HealthAPI 健康检查相关API
+type HealthAPI struct {
+	service *service.Health
+}
+
+// NewHealthAPI 创建健康检查API
+func NewHealthAPI(db *gorm.DB) *HealthAPI {
+	return &HealthAPI{service: service.NewHealth(db)}
+}
+
+// Healthz 存活检查，只表示进程还在运行，不检查数据库和runcher，依赖不可用时不应重启进程
+func (api *HealthAPI) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, &dto.HealthResp{Status: dto.HealthStatusOK})
+}
+
+// Readyz 就绪检查，数据库和runcher都正常时才返回200
+func (api *HealthAPI) Readyz(c *gin.Context) {
+	resp := api.service.Check(c)
+	status := http.StatusOK
+	if resp.Status != dto.HealthStatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, resp)
+}
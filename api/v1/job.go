@@ -0,0 +1,39 @@
+package v1
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yunhanshu-net/function-server/pkg/response"
+	"github.com/yunhanshu-net/function-server/service"
+	"gorm.io/gorm"
+)
+
+// JobAPI 后台任务API控制器
+type JobAPI struct {
+	service *service.Job
+}
+
+// NewJobAPI 创建后台任务API控制器
+func NewJobAPI(db *gorm.DB) *JobAPI {
+	return &JobAPI{service: service.NewJob(db)}
+}
+
+// Get 获取任务的状态和进度
+func (api *JobAPI) Get(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的ID")
+		return
+	}
+	job, err := api.service.Get(c, id)
+	if err != nil {
+		response.ServerError(c, "获取任务失败")
+		return
+	}
+	if job == nil {
+		response.NotFound(c, "任务不存在")
+		return
+	}
+	response.Success(c, job)
+}
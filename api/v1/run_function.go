@@ -1,63 +1,49 @@
 package v1
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	resp "github.com/yunhanshu-net/function-go/pkg/dto/response"
-	"github.com/yunhanshu-net/function-server/model"
-	"github.com/yunhanshu-net/function-server/pkg/db"
+	"github.com/yunhanshu-net/function-server/pkg/dto"
 	"github.com/yunhanshu-net/function-server/pkg/dto/runcher"
 	"github.com/yunhanshu-net/function-server/pkg/response"
 	"github.com/yunhanshu-net/function-server/service"
 	"github.com/yunhanshu-net/pkg/logger"
-	"github.com/yunhanshu-net/pkg/x/urlx"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 	"io"
 	"net/http"
 	"strconv"
-	"time"
+	"strings"
 )
 
 type Functions struct {
-	runcher service.RuncherService
-	runner  *service.Runner
+	runner     *service.Runner
+	runnerFunc *service.RunnerFunc
+	funcRun    *service.FuncRun
 }
 
 func NewFunctions(db *gorm.DB) *Functions {
 	return &Functions{
-		runcher: service.GetRuncherService(),
-		runner:  service.NewRunner(db),
+		runner:     service.NewRunner(db),
+		runnerFunc: service.NewRunnerFunc(db),
+		funcRun:    service.NewFuncRun(db),
 	}
 }
 
 func (r *Functions) Run(c *gin.Context) {
 
+	// 地址中的 runner@env 表示执行该环境发布的版本
+	runnerName, env := service.SplitRunnerEnv(c.Param("runner"))
 	req := &runcher.RunFunctionReq{
 		User:   c.Param("user"),
 		Method: c.Request.Method,
-		Runner: c.Param("runner"),
+		Runner: runnerName,
 		Router: c.Param("router"),
 	}
 
-	log := model.FuncRunRecord{
-		Base: model.Base{
-			CreatedBy: c.GetString("user"),
-			UpdatedBy: c.GetString("user"),
-		},
-		FuncId:  1,
-		Request: json.RawMessage(req.Body),
-		StartTs: time.Now().UnixMilli(),
-	}
 	if req.Method == http.MethodGet {
 		req.RawQuery = c.Request.URL.RawQuery
-		toMap := urlx.QueryToMap(req.RawQuery)
-		marshal, err := json.Marshal(toMap)
-		if err != nil {
-			response.ParamError(c, fmt.Sprintf("json.Marshal 失败：%s", err.Error()))
-			return
-		}
-		log.Request = marshal
 	} else {
 
 		b, err := io.ReadAll(c.Request.Body)
@@ -66,59 +52,142 @@ func (r *Functions) Run(c *gin.Context) {
 		}
 		defer c.Request.Body.Close()
 		req.Body = string(b)
-		log.Request = json.RawMessage(req.Body)
 	}
 	rn, err := r.runner.GetByUserName(c, req.User, req.Runner)
 	if err != nil {
 		response.ParamError(c, fmt.Sprintf("获取runner失败：%s", err.Error()))
 		return
 	}
-	req.Version = rn.Version
 
-	function2, err := r.runcher.RunFunction2(c, req)
+	opts := &service.RunOptions{
+		Runner:   rn,
+		Req:      req,
+		Env:      env,
+		Operator: c.GetString("user"),
+		Mock:     isMockRequest(c),
+		// 客户端断开连接时取消执行
+		ClientDone: c.Request.Context().Done(),
+	}
+	if get := c.Request.Header.Get("X-Function-ID"); get != "" {
+		opts.FuncID, err = strconv.ParseInt(get, 10, 64)
+		if err != nil {
+			logger.Errorf(c, "函数id获取失败！")
+		}
+	}
+
+	result, err := r.funcRun.Execute(c, opts)
+	if result != nil {
+		c.Header("X-Run-ID", result.Record.RunID)
+		ctx := c.Copy()
+		go r.runnerFunc.SaveRunRecord(ctx, result.Func, result.Record)
+	}
 	if err != nil {
+		if errors.Is(err, service.ErrRuncherUnavailable) {
+			response.Unavailable(c, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrRunnerNotRunning) || errors.Is(err, service.ErrFuncDisabled) {
+			response.Conflict(c, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrRunnerEnv) || errors.Is(err, service.ErrFuncMismatch) {
+			response.ParamError(c, err.Error())
+			return
+		}
 		response.ServerError(c, err.Error())
 		return
 	}
-	log.EndTs = time.Now().UnixMilli()
-	log.Cost = log.EndTs - log.StartTs
+	c.JSON(http.StatusOK, result.Resp)
+}
 
-	get := c.Request.Header.Get("X-Function-ID")
-	funcId, err := strconv.Atoi(get)
+// Replay 重放一条函数执行记录，可以指定runner版本，返回新旧结果的差异
+func (r *Functions) Replay(c *gin.Context) {
+	recordID, err := strconv.ParseInt(c.Param("record_id"), 10, 64)
 	if err != nil {
-		logger.Errorf(c, "函数id获取失败！")
+		response.ParamError(c, "无效的执行记录ID")
+		return
 	}
-	log.FuncId = int64(funcId)
 
-	log.Response = function2.Data
-	var res resp.RunFunctionResp
-	err = json.Unmarshal(function2.Data, &res)
+	var req dto.ReplayRunReq
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.ParamError(c, "参数解析失败: "+err.Error())
+			return
+		}
+	}
+
+	resp, err := r.funcRun.Replay(c, recordID, req.Version, c.GetString("user"))
 	if err != nil {
-		log.Status = "fail"
-		log.Message = err.Error()
+		logger.Error(c, "重放函数执行记录失败", err, zap.Int64("record_id", recordID))
+		if errors.Is(err, service.ErrRunForbidden) {
+			response.Forbidden(c, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrRuncherUnavailable) {
+			response.Unavailable(c, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrRunnerNotRunning) || errors.Is(err, service.ErrFuncDisabled) {
+			response.Conflict(c, err.Error())
+			return
+		}
 		response.ServerError(c, err.Error())
 		return
 	}
-	if res.MetaData == nil {
-		res.MetaData = make(map[string]interface{})
-	}
-	for k, v := range function2.Header {
-		if k != "code" {
-			if len(v) > 0 {
-				res.MetaData[k] = v[0]
-			}
+	response.Success(c, resp)
+}
+
+// Cancel 取消执行中的函数
+func (r *Functions) Cancel(c *gin.Context) {
+	runID := c.Param("run_id")
+	if err := r.funcRun.Cancel(c, runID, c.GetString("user")); err != nil {
+		logger.Error(c, "取消函数执行失败", err, zap.String("run_id", runID))
+		if errors.Is(err, service.ErrRunNotFound) {
+			response.NotFound(c, err.Error())
+			return
 		}
-	}
-	res.MetaData["version"] = rn.Version
-	log.Status = "success"
-	go func() {
-		marshal, err2 := json.Marshal(res)
-		if err2 != nil {
-			logger.Errorf(c, "<UNK>")
-		} else {
-			log.Response = marshal
+		if errors.Is(err, service.ErrRunForbidden) {
+			response.Forbidden(c, err.Error())
+			return
 		}
-		db.GetDB().Model(&model.FuncRunRecord{}).Create(&log)
-	}()
-	c.JSON(http.StatusOK, res)
+		if errors.Is(err, service.ErrRuncherUnavailable) {
+			response.Unavailable(c, err.Error())
+			return
+		}
+		response.ServerError(c, err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// Runs 获取当前用户执行中的函数
+func (r *Functions) Runs(c *gin.Context) {
+	response.Success(c, r.funcRun.ListInflightRuns(c, c.GetString("user")))
+}
+
+// Open 打开函数页面，返回函数信息，AutoRun函数同时返回默认输入的执行结果，refresh=true时忽略缓存
+func (r *Functions) Open(c *gin.Context) {
+	funcID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的函数ID")
+		return
+	}
+
+	resp, err := r.funcRun.Open(c, funcID, c.GetString("user"), c.Query("refresh") == "true")
+	if err != nil {
+		logger.Error(c, "打开函数页面失败", err, zap.Int64("func_id", funcID))
+		response.ServerError(c, err.Error())
+		return
+	}
+	response.Success(c, resp)
+}
+
+// isMockRequest 请求头 X-Mock 为 true/1/on 时模拟执行
+func isMockRequest(c *gin.Context) bool {
+	switch strings.ToLower(c.Request.Header.Get("X-Mock")) {
+	case "true", "1", "on":
+		return true
+	default:
+		return false
+	}
 }
@@ -0,0 +1,73 @@
+package v1
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yunhanshu-net/function-server/pkg/dto"
+	"github.com/yunhanshu-net/function-server/pkg/dto/runcher"
+	"github.com/yunhanshu-net/function-server/pkg/response"
+	"github.com/yunhanshu-net/function-server/service"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// RuncherNodeAPI runcher节点API控制器
+type RuncherNodeAPI struct {
+	service *service.RuncherNode
+}
+
+// NewRuncherNodeAPI 创建runcher节点API控制器
+func NewRuncherNodeAPI(db *gorm.DB) *RuncherNodeAPI {
+	return &RuncherNodeAPI{service: service.NewRuncherNode(db)}
+}
+
+// Heartbeat 节点心跳
+func (api *RuncherNodeAPI) Heartbeat(c *gin.Context) {
+	var req runcher.Heartbeat
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, "参数解析失败: "+err.Error())
+		return
+	}
+	if err := api.service.Heartbeat(c, &req); err != nil {
+		logger.Error(c, "处理runcher节点心跳失败", err, zap.String("node", req.Name))
+		response.ServerError(c, err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// List 获取节点列表
+func (api *RuncherNodeAPI) List(c *gin.Context) {
+	nodes, err := api.service.List(c)
+	if err != nil {
+		response.ServerError(c, err.Error())
+		return
+	}
+	response.Success(c, nodes)
+}
+
+// UpdateStatus 修改节点状态
+func (api *RuncherNodeAPI) UpdateStatus(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的ID")
+		return
+	}
+	var req dto.UpdateRuncherNodeStatusReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, "参数解析失败: "+err.Error())
+		return
+	}
+	if err := api.service.UpdateStatus(c, id, req.Status); err != nil {
+		if errors.Is(err, service.ErrRuncherNodeStatus) {
+			response.ParamError(c, err.Error())
+			return
+		}
+		response.ServerError(c, err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
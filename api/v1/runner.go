@@ -1,9 +1,11 @@
 package v1
 
 import (
+	"errors"
 	"fmt"
 	"github.com/yunhanshu-net/function-server/pkg/db"
 	"github.com/yunhanshu-net/function-server/pkg/dto/base"
+	"io"
 	"strconv"
 	"time"
 
@@ -20,12 +22,14 @@ import (
 // RunnerAPI Runner API控制器
 type RunnerAPI struct {
 	service *service.Runner
+	jobs    *service.Job
 }
 
 // NewRunnerAPI 创建Runner API控制器
 func NewRunnerAPI(db *gorm.DB) *RunnerAPI {
 	return &RunnerAPI{
 		service: service.NewRunner(db),
+		jobs:    service.NewJob(db),
 	}
 }
 
@@ -196,7 +200,7 @@ func (api *RunnerAPI) Delete(c *gin.Context) {
 	response.Success(c, nil)
 }
 
-// Fork 复制Runner
+// Fork 完整复制Runner，复制在后台任务中执行，返回任务
 func (api *RunnerAPI) Fork(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -204,24 +208,241 @@ func (api *RunnerAPI) Fork(c *gin.Context) {
 		response.ParamError(c, "无效的ID")
 		return
 	}
+	var req dto.ForkRunnerReq
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.ParamError(c, "参数解析失败: "+err.Error())
+		return
+	}
 
-	logger.Debug(c, "开始处理Runner复制请求", zap.Int64("id", id))
-
-	// 设置操作者信息，实际项目中应从JWT或Session获取
-	operator := "admin"
-
-	newRunner, err := api.service.Fork(c, id, operator)
+	job, err := api.service.Fork(c, id, req.Name, req.Title, c.GetString("user"))
 	if err != nil {
 		logger.Error(c, "Fork Runner失败", err, zap.Int64("id", id))
 		response.ServerError(c, "Fork Runner失败: "+err.Error())
 		return
 	}
+	logger.Info(c, "开始Fork Runner", zap.Int64("source_id", id), zap.Int64("job_id", job.ID))
+	response.Success(c, job)
+}
+
+// ForkJobs 获取Runner的fork任务
+func (api *RunnerAPI) ForkJobs(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的ID")
+		return
+	}
+	jobs, err := api.jobs.ListBySource(c, model.JobTypeRunnerFork, id)
+	if err != nil {
+		response.ServerError(c, "获取fork任务失败")
+		return
+	}
+	response.Success(c, jobs)
+}
+
+// UpstreamStatus 获取上游自上次同步以来变化的函数
+func (api *RunnerAPI) UpstreamStatus(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的ID")
+		return
+	}
+	status, err := api.service.UpstreamStatus(c, id)
+	if err != nil {
+		logger.Error(c, "获取上游变化失败", err, zap.Int64("id", id))
+		response.ServerError(c, "获取上游变化失败: "+err.Error())
+		return
+	}
+	response.Success(c, status)
+}
 
-	logger.Info(c, "Fork Runner成功",
-		zap.Int64("source_id", id),
-		zap.Int64("new_id", newRunner.ID),
-		zap.String("title", newRunner.Title))
-	response.Success(c, newRunner)
+// Export 导出Runner的目录和函数源码
+func (api *RunnerAPI) Export(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的ID")
+		return
+	}
+	resp, err := api.service.Export(c, id)
+	if err != nil {
+		logger.Error(c, "导出Runner失败", err, zap.Int64("id", id))
+		response.ServerError(c, "导出Runner失败: "+err.Error())
+		return
+	}
+	response.Success(c, resp)
+}
+
+// SyncUpstream 同步选中的上游变化，有冲突的变化需要force才会覆盖
+func (api *RunnerAPI) SyncUpstream(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的ID")
+		return
+	}
+	var req dto.SyncUpstreamReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, "参数解析失败: "+err.Error())
+		return
+	}
+	result, err := api.service.SyncUpstream(c, id, req.UpstreamFuncIDs, req.Force, c.GetString("user"))
+	if err != nil {
+		logger.Error(c, "同步上游失败", err, zap.Int64("id", id))
+		if errors.Is(err, service.ErrRunnerArchived) {
+			response.Conflict(c, err.Error())
+			return
+		}
+		response.ServerError(c, "同步上游失败: "+err.Error())
+		return
+	}
+	response.Success(c, result)
+}
+
+// Migrate 把Runner迁移到另一个runcher节点
+func (api *RunnerAPI) Migrate(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Error(c, "解析Runner ID失败", err, zap.String("id_param", c.Param("id")))
+		response.ParamError(c, "无效的ID")
+		return
+	}
+
+	var req dto.MigrateRunnerReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, "参数解析失败: "+err.Error())
+		return
+	}
+
+	runner, err := api.service.Migrate(c, id, req.RuncherID, c.GetString("user"))
+	if err != nil {
+		logger.Error(c, "迁移Runner失败", err, zap.Int64("id", id), zap.Int64("runcher_id", req.RuncherID))
+		if errors.Is(err, service.ErrRunnerArchived) {
+			response.Conflict(c, err.Error())
+			return
+		}
+		response.ServerError(c, "迁移Runner失败: "+err.Error())
+		return
+	}
+	response.Success(c, runner)
+}
+
+// Rollback 回滚Runner到之前的版本
+func (api *RunnerAPI) Rollback(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Error(c, "解析Runner ID失败", err, zap.String("id_param", c.Param("id")))
+		response.ParamError(c, "无效的ID")
+		return
+	}
+
+	var req dto.RollbackRunnerReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, "参数解析失败: "+err.Error())
+		return
+	}
+
+	result, err := api.service.Rollback(c, id, req.Version, c.GetString("user"))
+	if err != nil {
+		logger.Error(c, "回滚Runner失败", err, zap.Int64("id", id), zap.String("version", req.Version))
+		if errors.Is(err, service.ErrRunnerArchived) {
+			response.Conflict(c, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrRuncherUnavailable) {
+			response.Unavailable(c, err.Error())
+			return
+		}
+		response.ServerError(c, "回滚Runner失败: "+err.Error())
+		return
+	}
+	resp := dto.RollbackRunnerResp{
+		ID:         id,
+		Version:    result.Runner.Version,
+		RollbackTo: result.Target,
+		Added:      []string{},
+		Removed:    []string{},
+		Updated:    []string{},
+		Skipped:    []string{},
+	}
+	for _, info := range result.Added {
+		resp.Added = append(resp.Added, info.EnglishName)
+	}
+	for _, info := range result.Removed {
+		resp.Removed = append(resp.Removed, info.EnglishName)
+	}
+	for _, info := range result.Updated {
+		resp.Updated = append(resp.Updated, info.EnglishName)
+	}
+	for _, info := range result.Skipped {
+		resp.Skipped = append(resp.Skipped, info.EnglishName)
+	}
+	response.Success(c, resp)
+}
+
+// Start 启动Runner
+func (api *RunnerAPI) Start(c *gin.Context) {
+	api.changeStatus(c, service.RunnerActionStart)
+}
+
+// Stop 停止Runner
+func (api *RunnerAPI) Stop(c *gin.Context) {
+	api.changeStatus(c, service.RunnerActionStop)
+}
+
+// Suspend 暂停Runner
+func (api *RunnerAPI) Suspend(c *gin.Context) {
+	api.changeStatus(c, service.RunnerActionSuspend)
+}
+
+// Archive 归档Runner
+func (api *RunnerAPI) Archive(c *gin.Context) {
+	api.changeStatus(c, service.RunnerActionArchive)
+}
+
+func (api *RunnerAPI) changeStatus(c *gin.Context, action string) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Error(c, "解析Runner ID失败", err, zap.String("id_param", c.Param("id")))
+		response.ParamError(c, "无效的ID")
+		return
+	}
+
+	var req dto.ChangeRunnerStatusReq
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.ParamError(c, "参数解析失败: "+err.Error())
+			return
+		}
+	}
+
+	runner, err := api.service.ChangeStatus(c, id, action, req.Reason, c.GetString("user"))
+	if err != nil {
+		logger.Error(c, "修改Runner状态失败", err, zap.Int64("id", id), zap.String("action", action))
+		if errors.Is(err, service.ErrRunnerStatusTransition) {
+			response.Conflict(c, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrRuncherUnavailable) {
+			response.Unavailable(c, err.Error())
+			return
+		}
+		response.ServerError(c, err.Error())
+		return
+	}
+	response.Success(c, runner)
+}
+
+// StatusHistory 查看Runner状态变更历史
+func (api *RunnerAPI) StatusHistory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的ID")
+		return
+	}
+	logs, err := api.service.StatusHistory(c, id)
+	if err != nil {
+		response.ServerError(c, "获取Runner状态变更历史失败")
+		return
+	}
+	response.Success(c, logs)
 }
 
 // Version 查看Runner版本历史
@@ -246,6 +467,30 @@ func (api *RunnerAPI) Version(c *gin.Context) {
 	response.Success(c, versions)
 }
 
+// VersionDiff 比较Runner两个版本的函数差异
+func (api *RunnerAPI) VersionDiff(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Error(c, "解析Runner ID失败", err, zap.String("id_param", c.Param("id")))
+		response.ParamError(c, "无效的ID")
+		return
+	}
+
+	var req dto.DiffRunnerVersionReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.ParamError(c, "参数解析失败: "+err.Error())
+		return
+	}
+
+	diff, err := api.service.DiffVersions(c, id, req.From, req.To)
+	if err != nil {
+		logger.Error(c, "比较Runner版本失败", err, zap.Int64("id", id), zap.String("from", req.From), zap.String("to", req.To))
+		response.ServerError(c, "比较Runner版本失败: "+err.Error())
+		return
+	}
+	response.Success(c, diff)
+}
+
 // GetVersionHistory 获取Runner版本历史
 func (api *RunnerAPI) GetVersionHistory(c *gin.Context) {
 	// 使用GetRunnerVersionHistoryReq DTO
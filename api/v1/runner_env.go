@@ -0,0 +1,112 @@
+package v1
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yunhanshu-net/function-server/pkg/dto"
+	"github.com/yunhanshu-net/function-server/pkg/response"
+	"github.com/yunhanshu-net/function-server/service"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// RunnerEnvAPI Runner发布环境API控制器
+type RunnerEnvAPI struct {
+	service *service.RunnerEnv
+}
+
+// NewRunnerEnvAPI 创建Runner发布环境API控制器
+func NewRunnerEnvAPI(db *gorm.DB) *RunnerEnvAPI {
+	return &RunnerEnvAPI{service: service.NewRunnerEnv(db)}
+}
+
+// List 获取Runner的全部环境
+func (api *RunnerEnvAPI) List(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的ID")
+		return
+	}
+	envs, err := api.service.List(c, id)
+	if err != nil {
+		response.ServerError(c, err.Error())
+		return
+	}
+	response.Success(c, envs)
+}
+
+// SetVersion 把环境指向runner的某个版本
+func (api *RunnerEnvAPI) SetVersion(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的ID")
+		return
+	}
+	var req dto.SetRunnerEnvVersionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, "参数解析失败: "+err.Error())
+		return
+	}
+	env, err := api.service.SetVersion(c, id, c.Param("env"), req.Version, c.GetString("user"))
+	if err != nil {
+		api.fail(c, "设置环境版本失败", err)
+		return
+	}
+	response.Success(c, env)
+}
+
+// Promote 把一个环境的版本发布到后面的环境
+func (api *RunnerEnvAPI) Promote(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的ID")
+		return
+	}
+	var req dto.PromoteRunnerEnvReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, "参数解析失败: "+err.Error())
+		return
+	}
+	env, err := api.service.Promote(c, id, req.From, req.To, c.GetString("user"))
+	if err != nil {
+		api.fail(c, "发布环境失败", err)
+		return
+	}
+	response.Success(c, env)
+}
+
+// SetConfig 设置环境变量
+func (api *RunnerEnvAPI) SetConfig(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.ParamError(c, "无效的ID")
+		return
+	}
+	var req dto.SetRunnerEnvConfigReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, "参数解析失败: "+err.Error())
+		return
+	}
+	env, err := api.service.SetConfig(c, id, c.Param("env"), req.Config, c.GetString("user"))
+	if err != nil {
+		api.fail(c, "设置环境变量失败", err)
+		return
+	}
+	response.Success(c, env)
+}
+
+func (api *RunnerEnvAPI) fail(c *gin.Context, msg string, err error) {
+	logger.Error(c, msg, err, zap.String("id", c.Param("id")), zap.String("env", c.Param("env")))
+	if errors.Is(err, service.ErrRunnerEnv) {
+		response.ParamError(c, err.Error())
+		return
+	}
+	if errors.Is(err, service.ErrRunnerArchived) {
+		response.Conflict(c, err.Error())
+		return
+	}
+	response.ServerError(c, msg+": "+err.Error())
+}
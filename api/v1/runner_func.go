@@ -2,6 +2,7 @@ package v1
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -68,6 +69,10 @@ func (api *RunnerFuncAPI) Create(c *gin.Context) {
 	// 调用服务层创建函数
 	if err := api.service.Create(c, runnerFunc); err != nil {
 		logger.Error(c, "创建RunnerFunc失败", err)
+		if errors.Is(err, service.ErrRunnerArchived) {
+			response.Conflict(c, err.Error())
+			return
+		}
 		response.ServerError(c, "创建函数失败: "+err.Error())
 		return
 	}
@@ -220,6 +225,67 @@ func (api *RunnerFuncAPI) Versions(c *gin.Context) {
 	logger.Info(c, "获取RunnerFunc详情成功", zap.Int64("id", id))
 	response.Success(c, runnerFunc)
 }
+
+// UpdateCode 修改函数代码并重新部署，函数ID不变
+func (api *RunnerFuncAPI) UpdateCode(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Error(c, "解析RunnerFunc ID失败", err, zap.String("id_param", c.Param("id")))
+		response.ParamError(c, "无效的ID")
+		return
+	}
+	var req dto.UpdateFuncCodeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, "参数解析失败: "+err.Error())
+		return
+	}
+
+	runnerFunc, err := api.service.UpdateCode(c, id, req.Code, req.Comment, c.GetString("user"))
+	if err != nil {
+		logger.Error(c, "修改函数代码失败", err, zap.Int64("id", id))
+		if errors.Is(err, service.ErrRunnerArchived) {
+			response.Conflict(c, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrRuncherUnavailable) {
+			response.Unavailable(c, err.Error())
+			return
+		}
+		response.ServerError(c, "修改函数代码失败: "+err.Error())
+		return
+	}
+	response.Success(c, runnerFunc)
+}
+
+// Source 获取函数当前或历史版本的源码
+func (api *RunnerFuncAPI) Source(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Error(c, "解析RunnerFunc ID失败", err, zap.String("id_param", c.Param("id")))
+		response.ParamError(c, "无效的ID")
+		return
+	}
+	var req dto.GetFuncSourceReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.ParamError(c, "参数解析失败: "+err.Error())
+		return
+	}
+
+	version, code, err := api.service.Source(c, id, req.Version)
+	if err != nil {
+		logger.Error(c, "获取函数源码失败", err, zap.Int64("id", id), zap.String("version", req.Version))
+		response.ServerError(c, "获取函数源码失败")
+		return
+	}
+	if version == nil {
+		response.NotFound(c, "没有保存该版本的源码")
+		return
+	}
+	var resp dto.GetFuncSourceResp
+	resp.FromModel(version, code)
+	response.Success(c, resp)
+}
+
 func (api *RunnerFuncAPI) GetByTreeId(c *gin.Context) {
 	// 使用GetRunnerFuncReq DTO
 	var req dto.GetRunnerFuncReq
@@ -293,6 +359,9 @@ func (api *RunnerFuncAPI) GetFuncRecord(c *gin.Context) {
 
 	f := c.Param("func_id")
 	d := db.GetDB().Where("func_id = ?", f)
+	if traceID := c.Query("trace_id"); traceID != "" {
+		d = d.Where("trace_id = ?", traceID)
+	}
 
 	var list []model.FuncRunRecord
 	paginate, err := query.AutoPaginateTable(c, d, &model.FuncRunRecord{}, &list, &req.PageInfoReq)
@@ -303,6 +372,54 @@ func (api *RunnerFuncAPI) GetFuncRecord(c *gin.Context) {
 	response.Success(c, paginate)
 }
 
+// GetTraceRecords 根据链路ID获取函数执行记录
+func (api *RunnerFuncAPI) GetTraceRecords(c *gin.Context) {
+	traceID := c.Param("trace_id")
+	records, err := api.service.GetRunRecordsByTraceID(c, traceID)
+	if err != nil {
+		logger.Error(c, "根据链路ID获取函数执行记录失败", err, zap.String("trace_id", traceID))
+		response.ServerError(c, "获取执行记录失败: "+err.Error())
+		return
+	}
+	response.Success(c, records)
+}
+
+// ContractStats 获取函数返回值契约违规统计
+func (api *RunnerFuncAPI) ContractStats(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Error(c, "解析RunnerFunc ID失败", err, zap.String("id_param", c.Param("id")))
+		response.ParamError(c, "无效的ID")
+		return
+	}
+
+	stats, err := api.service.GetContractStats(c, id)
+	if err != nil {
+		logger.Error(c, "获取函数契约违规统计失败", err, zap.Int64("id", id))
+		response.ServerError(c, "获取函数契约违规统计失败: "+err.Error())
+		return
+	}
+	response.Success(c, stats)
+}
+
+// Callbacks 获取函数支持的回调列表
+func (api *RunnerFuncAPI) Callbacks(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		logger.Error(c, "解析RunnerFunc ID失败", err, zap.String("id_param", c.Param("id")))
+		response.ParamError(c, "无效的ID")
+		return
+	}
+
+	callbacks, err := api.service.GetCallbacks(c, id)
+	if err != nil {
+		logger.Error(c, "获取函数回调列表失败", err, zap.Int64("id", id))
+		response.ServerError(c, "获取函数回调列表失败: "+err.Error())
+		return
+	}
+	response.Success(c, callbacks)
+}
+
 // Update 更新函数
 func (api *RunnerFuncAPI) Update(c *gin.Context) {
 	// 使用UpdateRunnerFuncReq DTO
@@ -326,11 +443,15 @@ func (api *RunnerFuncAPI) Update(c *gin.Context) {
 
 	// 直接创建模型对象
 	updateData := &model.RunnerFunc{
-		Name:        req.Name,
-		Title:       req.Title,
-		TreeID:      req.TreeID,
-		Description: req.Desc,
-		IsPublic:    req.IsPublic,
+		Name:         req.Name,
+		Title:        req.Title,
+		TreeID:       req.TreeID,
+		Description:  req.Desc,
+		IsPublic:     req.IsPublic,
+		ContractMode: req.ContractMode,
+		RedactFields: req.RedactFields,
+		PayloadStore: req.PayloadStore,
+		Mock:         req.Mock,
 		// Type, Status, Content, Config字段在模型中不存在，暂时移除
 	}
 
@@ -385,6 +506,10 @@ func (api *RunnerFuncAPI) Delete(c *gin.Context) {
 	// 调用服务层删除函数
 	if err := api.service.Delete(c, id, operator); err != nil {
 		logger.Error(c, "删除RunnerFunc失败", err, zap.Int64("id", id))
+		if errors.Is(err, service.ErrRunnerArchived) {
+			response.Conflict(c, err.Error())
+			return
+		}
 		response.ServerError(c, "删除函数失败: "+err.Error())
 		return
 	}
@@ -412,6 +537,10 @@ func (api *RunnerFuncAPI) DeleteByIds(c *gin.Context) {
 	// 调用服务层删除函数
 	if err := api.service.DeleteByIds(c, req.Ids, operator); err != nil {
 		logger.Errorf(c, "删除RunnerFunc失败err:%s %+v", err, req)
+		if errors.Is(err, service.ErrRunnerArchived) {
+			response.Conflict(c, err.Error())
+			return
+		}
 		response.ServerError(c, "删除函数失败: "+err.Error())
 		return
 	}
@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"errors"
 	"fmt"
 	"github.com/yunhanshu-net/function-server/pkg/db"
 	"github.com/yunhanshu-net/function-server/pkg/dto"
@@ -52,6 +53,10 @@ func (api *ServiceTreeAPI) Create(c *gin.Context) {
 	// 调用服务层创建目录
 	if err := api.service.CreateNode(c, &serviceTree); err != nil {
 		logger.Error(c, "创建ServiceTree失败", err)
+		if errors.Is(err, service.ErrRunnerArchived) {
+			response.Conflict(c, err.Error())
+			return
+		}
 		response.ServerError(c, "创建目录失败: "+err.Error())
 		return
 	}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/yunhanshu-net/function-server/pkg/config"
 	"github.com/yunhanshu-net/function-server/pkg/db"
+	"github.com/yunhanshu-net/function-server/pkg/tracing"
 	"github.com/yunhanshu-net/function-server/router"
 	"github.com/yunhanshu-net/function-server/service"
 	"github.com/yunhanshu-net/pkg/logger"
@@ -40,30 +41,30 @@ func main() {
 		log.Fatalf("初始化日志失败: %v", err)
 	}
 
+	// 初始化链路追踪，失败时不影响启动
+	shutdownTracing, err := tracing.Init(ctx, cfg.TracingConfig)
+	if err != nil {
+		logger.Error(ctx, "初始化链路追踪失败", err)
+		shutdownTracing = func(context.Context) error { return nil }
+	}
+
 	// 初始化数据库连接
 	if err := db.Init(cfg.DBConfig); err != nil {
 		logger.Fatal(ctx, "初始化数据库连接失败", err)
 	}
 
-	// 初始化RuncherService
-	runcherOptions := service.RuncherOptions{
-		NatsURL: cfg.RuncherConfig.NatsURL,
-		Timeout: time.Duration(cfg.RuncherConfig.Timeout) * time.Second,
-	}
+	// runcher连接成功后订阅节点心跳
+	service.NewRuncherNode(db.GetDB()).Watch()
 
-	var err error
-	runcherService, err := service.NewRuncherService(runcherOptions)
-	if err != nil {
-		logger.Error(ctx, "初始化Runcher服务失败", err)
-		// 继续执行，不要因为Runcher服务初始化失败而中断启动
-		// 这里仅记录错误，让应用可以启动，但函数执行功能将不可用
+	// 初始化RuncherService
+	// 连接失败时不中断启动，后台重试连接，连接成功后自动生效
+	// 连接成功前服务以降级模式运行：函数执行和部署接口返回503，/readyz返回503
+	if err := service.StartRuncherService(service.RuncherOptionsFromConfig(cfg.RuncherConfig)); err != nil {
+		logger.Error(ctx, "初始化Runcher服务失败，后台重试连接", err)
 	} else {
 		logger.Info(ctx, "Runcher服务初始化成功")
-		defer runcherService.Close()
-
-		// 设置全局RuncherService实例
-		service.SetGlobalRuncherService(runcherService)
 	}
+	defer service.StopRuncherService()
 
 	// 初始化路由
 	r := router.Init()
@@ -96,6 +97,10 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		logger.Fatal(ctx, "服务器关闭出错", err)
 	}
+	// 导出缓冲中的span
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error(ctx, "关闭链路追踪出错", err)
+	}
 
 	logger.Info(ctx, "服务器已关闭")
 }
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/yunhanshu-net/function-server/pkg/response"
+	"github.com/yunhanshu-net/function-server/service"
+)
+
+// RequireRuncher 依赖runcher的接口（部署、回调等）在runcher不可用时直接返回503
+func RequireRuncher() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if service.GetRuncherService() == nil {
+			response.Unavailable(c, service.ErrRuncherUnavailable.Error())
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -2,27 +2,50 @@ package middleware
 
 import (
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"github.com/yunhanshu-net/function-server/pkg/trace"
 	"github.com/yunhanshu-net/pkg/constants"
-	"time"
 )
 
 // WithTraceID 为请求添加跟踪ID的中间件
+// 优先使用W3C traceparent中的trace_id，其次使用请求头中的跟踪ID，都没有时生成新的链路
 func WithTraceID() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 检查请求头中是否已有跟踪ID
+		tracestate := c.GetHeader(trace.HeaderTracestate)
+		parent, ok := trace.Parse(c.GetHeader(trace.HeaderTraceparent))
 		traceID := c.GetHeader(constants.HttpTraceID)
-		if traceID == "" {
-			// 如果没有，则生成一个新的跟踪ID，格式为: 时间戳-UUID
-			traceID = time.Now().Format("20060102150405") + "-" + uuid.New().String()
-			c.Request.Header.Set(constants.HttpTraceID, traceID)
+		switch {
+		case ok:
+			traceID = parent.TraceID
+		case traceID != "":
+			// 兼容只传了跟踪ID的调用方，不是W3C格式时traceparent的trace_id由跟踪ID推导，同一个跟踪ID对应同一条链路
+			parent = trace.NewWithTraceID(traceID)
+			tracestate = ""
+		default:
+			parent = trace.New()
+			traceID = parent.TraceID
+			tracestate = ""
+		}
+		// 本服务作为链路上的一跳，向下游传递新的parent_id
+		traceparent := parent.Child().String()
+		c.Request.Header.Set(constants.HttpTraceID, traceID)
+		// 补全请求头中的traceparent，OpenTelemetry的span与日志使用同一个trace_id
+		c.Request.Header.Set(trace.HeaderTraceparent, parent.String())
+		if tracestate == "" {
+			c.Request.Header.Del(trace.HeaderTracestate)
 		}
 
 		// 在响应头中也返回跟踪ID
 		c.Header(constants.HttpTraceID, traceID)
+		c.Header(trace.HeaderTraceparent, traceparent)
+		if tracestate != "" {
+			c.Header(trace.HeaderTracestate, tracestate)
+		}
 
 		// 将跟踪ID存储在gin.Context中，这样可以直接通过context获取
 		c.Set(constants.TraceID, traceID)
+		c.Set(trace.KeyTraceparent, traceparent)
+		c.Set(trace.KeyTracestate, tracestate)
+		c.Request = c.Request.WithContext(trace.WithContext(c.Request.Context(), traceID, traceparent, tracestate))
 
 		c.Next()
 	}
@@ -7,13 +7,41 @@ type FuncRunRecord struct {
 	FuncId   int64           `json:"func_id"`
 	Request  json.RawMessage `json:"request" gorm:"type:json"`
 	Response json.RawMessage `json:"response" gorm:"type:json"`
-	Status   string          `json:"status"` //success,fail_run
+	Status   string          `json:"status"` //running,success,fail_run,fail_contract,fail_timeout,cancelled
 	Message  string          `json:"message"`
 	StartTs  int64           `json:"start_ts" gorm:"column:start_ts"`
 	EndTs    int64           `json:"end_ts" gorm:"column:end_ts"`
 	Cost     int64           `json:"cost" gorm:"column:cost"`
+	RunnerID int64           `json:"runner_id"`
+	Version  string          `json:"version"` //执行时runner的版本
+	Env      string          `json:"env"`     //执行的环境，为空表示直接执行runner当前版本
+	Method   string          `json:"method"`
+	Router   string          `json:"router"`
+	ReplayOf int64           `json:"replay_of"`                              //重放的原始执行记录ID，0表示不是重放
+	Mock     bool            `json:"mock"`                                   //模拟执行，结果由Response参数生成
+	Callback string          `json:"callback"`                               //回调类型，为空表示普通执行
+	RunID    string          `json:"run_id" gorm:"type:varchar(64);index"`   //执行ID，用于取消执行
+	TraceID  string          `json:"trace_id" gorm:"type:varchar(64);index"` //链路ID
+
+	ContractStatus     string          `json:"contract_status"`                      //契约校验结果：空表示未校验，pass，violated
+	ContractViolations json.RawMessage `json:"contract_violations" gorm:"type:json"` //违规明细
+
+	RequestTruncated  bool `json:"request_truncated"`  //request超过最大保存大小被截断
+	RequestRedacted   bool `json:"request_redacted"`   //request中有字段被脱敏
+	ResponseTruncated bool `json:"response_truncated"` //response超过最大保存大小被截断
+	PayloadHashed     bool `json:"payload_hashed"`     //request/response只保存了哈希
 }
 
+const (
+	ContractStatusPass     = "pass"
+	ContractStatusViolated = "violated"
+)
+
+const (
+	PayloadStoreFull = "full"
+	PayloadStoreHash = "hash"
+)
+
 func (FuncRunRecord) TableName() string {
 	return "func_run_record"
 }
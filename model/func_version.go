@@ -1,19 +1,61 @@
 package model
 
-import "encoding/json"
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+)
 
 // FuncVersion 表示函数版本记录
 type FuncVersion struct {
 	Base
-	RunnerID int64           `json:"runner_id"`
-	FuncID   int64           `json:"func_id"`
-	Version  string          `json:"version"`
-	Comment  string          `json:"comment"`
-	MetaData json.RawMessage `json:"metadata"`
-	Hash     string          `json:"hash"`
+	RunnerID   int64           `json:"runner_id"`
+	FuncID     int64           `json:"func_id"`
+	Version    string          `json:"version"`
+	Comment    string          `json:"comment"`
+	MetaData   json.RawMessage `json:"metadata"`
+	Hash       string          `json:"hash"`
+	Source     []byte          `json:"-" gorm:"type:mediumblob"` // gzip压缩后的函数源码
+	SourceSize int             `json:"source_size"`              // 源码压缩前的字节数
 }
 
 // TableName 表名
 func (FuncVersion) TableName() string {
 	return "func_version"
 }
+
+// SetSource 压缩保存函数源码
+func (v *FuncVersion) SetSource(code string) error {
+	if code == "" {
+		v.Source, v.SourceSize = nil, 0
+		return nil
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(code)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	v.Source, v.SourceSize = buf.Bytes(), len(code)
+	return nil
+}
+
+// GetSource 解压函数源码，没有保存源码时返回空字符串
+func (v *FuncVersion) GetSource() (string, error) {
+	if len(v.Source) == 0 {
+		return "", nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(v.Source))
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	code, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(code), nil
+}
@@ -1,5 +1,14 @@
 package model
 
+// 生成函数的审核状态
+const (
+	FunctionGenStatusGenerating       = "生成中"
+	FunctionGenStatusPending          = "待审核"
+	FunctionGenStatusApproved         = "已审核"
+	FunctionGenStatusRejected         = "已拒绝"
+	FunctionGenStatusChangesRequested = "待修改"
+)
+
 type FunctionGen struct {
 	Base
 
@@ -11,7 +20,7 @@ type FunctionGen struct {
 	Level      int64  `json:"level"`                             //函数复杂度：1-100
 	Quality    string `json:"quality"`                           //质量，优，良，中，差
 	Enable     int    `json:"enable"`                            //是否启用，-1，1
-	Status     string `json:"status"`                            //状态，生成中，待审核，已审核
+	Status     string `json:"status"`                            //状态，生成中，待审核，已审核，已拒绝，待修改
 	Classify   string `json:"classify"`                          //分类
 	Tags       string `json:"tags"`                              // 标签
 	RenderType string `json:"render_type"`                       // 功能渲染类型
@@ -21,8 +30,23 @@ type FunctionGen struct {
 	TreeID     int64  `json:"tree_id"`                           // 关联的树ID
 	Length     int    `json:"length"`                            // 字符数，根据字符数来判断是否是复杂函数
 	RunnerID   int64  `json:"runner_id"`                         //所属工作空间
+	Reviewer   string `json:"reviewer" gorm:"index"`             //指定的审核人，为空时任何人都可以审核
+	ReviewedBy string `json:"reviewed_by"`                       //实际审核人
+	ReviewedAt *Time  `json:"reviewed_at"`                       //审核时间
 }
 
 func (f *FunctionGen) TableName() string {
 	return "function_gen"
 }
+
+// FunctionGenReview 生成函数的审核记录，包括指定审核人、评论和审核结果
+type FunctionGenReview struct {
+	Base
+	GenID   int64  `json:"gen_id" gorm:"index"`
+	Action  string `json:"action"` //assign, comment, approve, reject, request_changes, submit
+	Comment string `json:"comment" gorm:"type:text"`
+}
+
+func (FunctionGenReview) TableName() string {
+	return "function_gen_review"
+}
@@ -0,0 +1,41 @@
+package model
+
+import "encoding/json"
+
+// 后台任务状态
+const (
+	JobStatusPending = "pending"
+	JobStatusRunning = "running"
+	JobStatusSuccess = "success"
+	JobStatusFail    = "fail"
+)
+
+// 后台任务类型
+const (
+	JobTypeRunnerFork = "runner_fork"
+)
+
+// Job 耗时较长的后台任务，接口立即返回任务，通过任务查询进度和结果
+type Job struct {
+	Base
+	Type       string          `json:"type" gorm:"index"`
+	SourceID   int64           `json:"source_id" gorm:"index"` // 任务处理的对象，如fork的源runner
+	TargetID   int64           `json:"target_id"`              // 任务产生的对象，如fork出的runner
+	Status     string          `json:"status"`
+	Step       string          `json:"step"`                     // 当前步骤
+	Total      int             `json:"total"`                    // 总步数
+	Done       int             `json:"done"`                     // 已完成步数
+	Message    string          `json:"message" gorm:"type:text"` // 失败原因
+	Result     json.RawMessage `json:"result" gorm:"type:json"`
+	StartedAt  *Time           `json:"started_at"`
+	FinishedAt *Time           `json:"finished_at"`
+}
+
+func (Job) TableName() string {
+	return "job"
+}
+
+// Finished 任务是否已经结束
+func (j *Job) Finished() bool {
+	return j.Status == JobStatusSuccess || j.Status == JobStatusFail
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// RuncherNode runcher集群节点，节点通过心跳注册
+type RuncherNode struct {
+	Base
+	Name          string `json:"name" gorm:"type:varchar(64);uniqueIndex"` //节点名称，节点专属subject为 runcher.{name}.xxx
+	Address       string `json:"address"`                                  //节点地址，仅用于展示
+	Labels        string `json:"labels"`                                   //节点标签，逗号分隔，如 gpu,region-sh
+	Capacity      int    `json:"capacity"`                                 //最多承载的runner数量，0表示不限制
+	Version       string `json:"version"`                                  //runcher版本
+	Status        string `json:"status"`                                   //active，draining（不再分配新的runner）
+	LastHeartbeat *Time  `json:"last_heartbeat"`                           //最近一次心跳时间
+	Runners       int64  `json:"runners" gorm:"default:0"`                 //已占用的容量，分配runner时原子加1，runner删除或迁出时减1
+
+	RunnerCount int64 `json:"runner_count" gorm:"-"` //节点上的runner数量
+	Healthy     bool  `json:"healthy" gorm:"-"`      //心跳是否正常
+}
+
+const (
+	RuncherNodeStatusActive   = "active"
+	RuncherNodeStatusDraining = "draining"
+)
+
+// IsRuncherNodeStatus 是否是合法的节点状态
+func IsRuncherNodeStatus(status string) bool {
+	return status == RuncherNodeStatusActive || status == RuncherNodeStatusDraining
+}
+
+func (n *RuncherNode) TableName() string {
+	return "runcher_node"
+}
+
+// IsHealthy 最近一次心跳在timeout以内
+func (n *RuncherNode) IsHealthy(now time.Time, timeout time.Duration) bool {
+	return n.LastHeartbeat != nil && now.Sub(time.Time(*n.LastHeartbeat)) <= timeout
+}
+
+// HasLabels 节点是否包含全部标签
+func (n *RuncherNode) HasLabels(labels []string) bool {
+	own := make(map[string]bool)
+	for _, label := range strings.Split(n.Labels, ",") {
+		own[strings.TrimSpace(label)] = true
+	}
+	for _, label := range labels {
+		if label = strings.TrimSpace(label); label != "" && !own[label] {
+			return false
+		}
+	}
+	return true
+}
+
+// Full 节点上的runner数量是否已经达到容量
+func (n *RuncherNode) Full() bool {
+	return n.Capacity > 0 && n.RunnerCount >= int64(n.Capacity)
+}
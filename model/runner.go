@@ -20,8 +20,37 @@ type Runner struct {
 	ForkFromVersion string `json:"fork_from_version"`
 	ForkFromID      *int64 `json:"fork_from_id"`
 
-	FullNamePath string `json:"full_name_path" gorm:"-"`
-	User         string `json:"user"`
+	FullNamePath  string `json:"full_name_path" gorm:"-"`
+	RuncherLabels string `json:"runcher_labels,omitempty" gorm:"-"` //创建时要求runcher节点具备的标签，逗号分隔
+	User          string `json:"user"`
+}
+
+// Runner状态
+const (
+	RunnerStatusRunning   int8 = 1 // 运行中，可以执行函数
+	RunnerStatusStopped   int8 = 2 // 已停止，runcher释放资源，可以重新启动
+	RunnerStatusSuspended int8 = 3 // 已暂停，一般由管理员因违规、欠费等原因暂停
+	RunnerStatusArchived  int8 = 4 // 已归档，只保留数据，不能再启动
+)
+
+var runnerStatusNames = map[int8]string{
+	RunnerStatusRunning:   "running",
+	RunnerStatusStopped:   "stopped",
+	RunnerStatusSuspended: "suspended",
+	RunnerStatusArchived:  "archived",
+}
+
+// RunnerStatusName 状态名称
+func RunnerStatusName(status int8) string {
+	if name, ok := runnerStatusNames[status]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// IsRunning 是否可以执行函数，0是加状态之前创建的runner，按运行中处理
+func (r *Runner) IsRunning() bool {
+	return r.Status == RunnerStatusRunning || r.Status == 0
 }
 
 func (r *Runner) TableName() string {
@@ -0,0 +1,52 @@
+package model
+
+import "encoding/json"
+
+// Runner环境，函数按 dev -> staging -> prod 的顺序发布
+const (
+	RunnerEnvDev     = "dev"
+	RunnerEnvStaging = "staging"
+	RunnerEnvProd    = "prod"
+)
+
+// RunnerEnvs 全部环境，按发布顺序排列
+var RunnerEnvs = []string{RunnerEnvDev, RunnerEnvStaging, RunnerEnvProd}
+
+// RunnerEnvIndex 环境在发布顺序中的位置，不支持的环境返回-1
+func RunnerEnvIndex(env string) int {
+	for i, e := range RunnerEnvs {
+		if e == env {
+			return i
+		}
+	}
+	return -1
+}
+
+// RunnerEnv Runner的发布环境，指向一个RunnerVersion
+type RunnerEnv struct {
+	Base
+	RunnerID     int64           `json:"runner_id" gorm:"uniqueIndex:idx_runner_env"`
+	Name         string          `json:"name" gorm:"type:varchar(32);uniqueIndex:idx_runner_env"` //dev, staging, prod
+	Version      string          `json:"version"`                                                 //为空时使用runner当前版本
+	PromotedFrom string          `json:"promoted_from"`                                           //最近一次从哪个环境发布过来
+	Config       json.RawMessage `json:"config" gorm:"type:json"`                                 //环境变量，执行函数时传给runner
+
+	CurrentVersion string `json:"current_version" gorm:"-"` //实际使用的版本
+}
+
+// TableName 表名
+func (RunnerEnv) TableName() string {
+	return "runner_env"
+}
+
+// ConfigVars 解析环境变量
+func (e *RunnerEnv) ConfigVars() (map[string]string, error) {
+	vars := make(map[string]string)
+	if len(e.Config) == 0 || string(e.Config) == "null" {
+		return vars, nil
+	}
+	if err := json.Unmarshal(e.Config, &vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
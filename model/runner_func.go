@@ -32,9 +32,19 @@ type RunnerFunc struct {
 	ForkFromID      *int64          `json:"fork_from_id"`
 	Method          string          `json:"method" gorm:"type:varchar(255);column:method"`
 	Path            string          `json:"path" gorm:"type:varchar(255);column:path"`
+	ContractMode    string          `json:"contract_mode"` //返回值契约校验模式：off, warn, strict，为空时使用全局配置
+	RedactFields    string          `json:"redact_fields"` //执行记录脱敏规则，逗号分隔的字段名或json路径，会和全局默认规则合并
+	PayloadStore    string          `json:"payload_store"` //执行记录保存方式：full, hash，为空时使用全局配置
+	Mock            string          `json:"mock"`          //模拟模式：on 时不调用runner，根据Response参数生成返回结果，off或空表示关闭
+	Disabled        bool            `json:"disabled"`      //未启用的函数不能执行，AI生成的函数审核通过后才启用
 	Code            string          `json:"-" gorm:"-"`
 }
 
+const (
+	MockOn  = "on"
+	MockOff = "off"
+)
+
 func (RunnerFunc) TableName() string {
 	return "runner_func"
 }
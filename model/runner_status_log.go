@@ -0,0 +1,16 @@
+package model
+
+// RunnerStatusLog Runner状态变更记录
+type RunnerStatusLog struct {
+	Base
+	RunnerID int64  `json:"runner_id" gorm:"index"`
+	Action   string `json:"action"` //start, stop, suspend, archive
+	From     int8   `json:"from"`
+	To       int8   `json:"to"`
+	Reason   string `json:"reason"`
+}
+
+// TableName 表名
+func (RunnerStatusLog) TableName() string {
+	return "runner_status_log"
+}
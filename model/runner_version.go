@@ -12,6 +12,10 @@ type RunnerVersion struct {
 	Log      string          `json:"log"`     //变更日志
 	Desc     string          `json:"desc"`    //用户描述
 	MetaData json.RawMessage `json:"meta_data"`
+
+	CallbackStatus string          `json:"callback_status"` // 版本变更系统回调结果：success, fail，为空表示没有回调
+	CallbackMsg    string          `json:"callback_msg"`    // 回调失败原因
+	CallbackResult json.RawMessage `json:"callback_result"` // runner返回的回调结果
 }
 
 // TableName 表名
@@ -13,6 +13,8 @@ type Config struct {
 	DBConfig      DBConfig      `json:"database"`
 	LogConfig     LogConfig     `json:"log"`
 	RuncherConfig RuncherConfig `json:"runcher"`
+	RunConfig     RunConfig     `json:"run"`
+	TracingConfig TracingConfig `json:"tracing"`
 }
 
 // ServerConfig 服务器配置
@@ -48,8 +50,52 @@ type LogConfig struct {
 
 // RuncherConfig Runcher服务配置
 type RuncherConfig struct {
-	NatsURL string `json:"nats_url"` // NATS服务器URL
-	Timeout int    `json:"timeout"`  // 请求超时时间（秒）
+	Transport string `json:"transport"` // 请求runcher的方式：nats, http, fake（进程内模拟，离线开发测试用），默认nats
+	NatsURL   string `json:"nats_url"`  // NATS服务器URL，多个地址用逗号分隔
+	BaseURL   string `json:"base_url"`  // transport为http时runcher的地址，如 http://runcher:8080
+	Timeout   int    `json:"timeout"`   // 请求超时时间（秒）
+
+	// 认证，按 token、user/password、nkey 的顺序取第一个配置了的，http只支持token和user/password
+	Token        string `json:"token"`
+	User         string `json:"user"`
+	Password     string `json:"password"`
+	NkeySeedFile string `json:"nkey_seed_file"` // nkey种子文件路径
+
+	// TLS，配置了证书时自动开启
+	TLS     bool   `json:"tls"`
+	TLSCert string `json:"tls_cert"` // 客户端证书
+	TLSKey  string `json:"tls_key"`  // 客户端私钥
+	TLSCA   string `json:"tls_ca"`   // 服务端CA证书
+
+	// 重连
+	MaxReconnects  int `json:"max_reconnects"`  // 断线后最大重连次数，0表示一直重连
+	ReconnectWait  int `json:"reconnect_wait"`  // 重连间隔（秒），启动时连接失败的重试间隔从该值开始翻倍
+	ConnectTimeout int `json:"connect_timeout"` // 建立连接超时时间（秒）
+
+	// 集群，runcher节点通过心跳注册，超过该时间没有心跳的节点不再分配runner
+	HeartbeatTimeout int `json:"heartbeat_timeout"` // 心跳超时时间（秒）
+}
+
+// RunConfig 函数执行相关配置
+type RunConfig struct {
+	ContractMode   string   `json:"contract_mode"`    // 返回值契约校验模式：off, warn, strict，函数上未单独设置时使用
+	RedactFields   []string `json:"redact_fields"`    // 执行记录中默认脱敏的字段，为空时使用内置的password、token等
+	MaxPayloadSize int      `json:"max_payload_size"` // 执行记录中request/response最大保存字节数，超出截断，0表示不限制
+	PayloadStore   string   `json:"payload_store"`    // 执行记录保存方式：full, hash，函数上未单独设置时使用
+
+	CallbackTimeout  int            `json:"callback_timeout"`   // 回调默认超时时间（秒）
+	CallbackTimeouts map[string]int `json:"callback_timeouts"`  // 按回调类型单独设置超时时间（秒），如 {"OnPageLoad": 5}
+	AutoRunCacheTTL  int            `json:"auto_run_cache_ttl"` // 打开页面自动运行结果按用户缓存的时间（秒），0表示不缓存
+}
+
+// TracingConfig OpenTelemetry链路追踪配置
+type TracingConfig struct {
+	Enabled     bool    `json:"enabled"`
+	Exporter    string  `json:"exporter"`     // otlp, stdout
+	Endpoint    string  `json:"endpoint"`     // OTLP HTTP地址，如 localhost:4318 或 http://collector:4318/v1/traces
+	Insecure    bool    `json:"insecure"`     // OTLP使用http而不是https
+	ServiceName string  `json:"service_name"` // 上报的服务名
+	SampleRatio float64 `json:"sample_ratio"` // 采样比例，0~1，0或不合法时全部采样
 }
 
 var config Config
@@ -101,8 +147,28 @@ func Init() error {
 				Compress:   true,
 			},
 			RuncherConfig: RuncherConfig{
-				NatsURL: "nats://localhost:4222",
-				Timeout: 20,
+				Transport:      "nats",
+				NatsURL:        "nats://localhost:4222",
+				Timeout:        20,
+				ReconnectWait:  2,
+				ConnectTimeout: 2,
+
+				HeartbeatTimeout: 30,
+			},
+			RunConfig: RunConfig{
+				ContractMode:    "off",
+				MaxPayloadSize:  64 * 1024,
+				PayloadStore:    "full",
+				CallbackTimeout: 10,
+				AutoRunCacheTTL: 30,
+			},
+			TracingConfig: TracingConfig{
+				Enabled:     false,
+				Exporter:    "otlp",
+				Endpoint:    "localhost:4318",
+				Insecure:    true,
+				ServiceName: "function-server",
+				SampleRatio: 1,
 			},
 		}
 
@@ -0,0 +1,195 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yunhanshu-net/function-server/pkg/dto/api"
+)
+
+// 契约校验模式
+const (
+	ModeOff    = "off"    // 不校验
+	ModeWarn   = "warn"   // 只记录违规，不影响返回
+	ModeStrict = "strict" // 违规时直接返回错误
+)
+
+// 违规规则
+const (
+	RuleRequired = "required" // 必填字段缺失
+	RuleType     = "type"     // 字段类型不一致
+	RuleUnknown  = "unknown"  // 返回了未声明的字段
+	RuleShape    = "shape"    // 整体结构不一致，例如声明是对象却返回了字符串
+)
+
+// maxCheckRows 表格类数据最多校验的行数，避免大表格拖慢请求
+const maxCheckRows = 50
+
+// Violation 一条契约违规记录
+type Violation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// NormalizeMode 规范化校验模式，未知的模式按关闭处理
+func NormalizeMode(mode string) string {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case ModeWarn:
+		return ModeWarn
+	case ModeStrict:
+		return ModeStrict
+	default:
+		return ModeOff
+	}
+}
+
+// Check 根据函数声明的Response参数校验runner返回的data
+// schema 为 RunnerFunc.Response 字段的原始json，data 为runner返回的data字段
+func Check(schema json.RawMessage, data interface{}) ([]Violation, error) {
+	if len(schema) == 0 || string(schema) == "null" {
+		return nil, nil
+	}
+	var params api.Params
+	if err := json.Unmarshal(schema, &params); err != nil {
+		return nil, fmt.Errorf("解析Response参数失败: %w", err)
+	}
+	if len(params.Children) == 0 {
+		return nil, nil
+	}
+
+	switch v := data.(type) {
+	case nil:
+		return checkObject("", params.Children, nil), nil
+	case map[string]interface{}:
+		return checkObject("", params.Children, v), nil
+	case []interface{}:
+		// 表格类的返回是一个对象数组，逐行校验
+		var violations []Violation
+		for i, row := range v {
+			if i >= maxCheckRows {
+				break
+			}
+			prefix := fmt.Sprintf("[%d]", i)
+			obj, ok := row.(map[string]interface{})
+			if !ok {
+				violations = append(violations, Violation{
+					Field:   prefix,
+					Rule:    RuleShape,
+					Message: fmt.Sprintf("期望对象，实际为%s", typeName(row)),
+				})
+				continue
+			}
+			violations = append(violations, checkObject(prefix, params.Children, obj)...)
+		}
+		return violations, nil
+	default:
+		return []Violation{{
+			Rule:    RuleShape,
+			Message: fmt.Sprintf("期望对象或对象数组，实际为%s", typeName(data)),
+		}}, nil
+	}
+}
+
+// checkObject 校验单个对象的字段
+func checkObject(prefix string, fields []*api.ParamInfo, obj map[string]interface{}) []Violation {
+	var violations []Violation
+	declared := make(map[string]struct{}, len(fields))
+	for _, field := range fields {
+		if field == nil || field.Code == "" {
+			continue
+		}
+		declared[field.Code] = struct{}{}
+		name := joinField(prefix, field.Code)
+		value, exists := obj[field.Code]
+		if !exists || value == nil {
+			if field.Required {
+				violations = append(violations, Violation{
+					Field:   name,
+					Rule:    RuleRequired,
+					Message: "必填字段缺失",
+				})
+			}
+			continue
+		}
+		if !matchType(field.ValueType, value) {
+			violations = append(violations, Violation{
+				Field:   name,
+				Rule:    RuleType,
+				Message: fmt.Sprintf("期望类型%s，实际为%s", field.ValueType, typeName(value)),
+			})
+		}
+	}
+
+	// 按字段名排序，保证每次输出一致
+	var unknown []string
+	for k := range obj {
+		if _, ok := declared[k]; !ok {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	for _, k := range unknown {
+		violations = append(violations, Violation{
+			Field:   joinField(prefix, k),
+			Rule:    RuleUnknown,
+			Message: "返回了未声明的字段",
+		})
+	}
+	return violations
+}
+
+// matchType 判断值是否符合声明的类型，无法识别的类型一律放过
+func matchType(valueType string, value interface{}) bool {
+	vt := strings.ToLower(strings.TrimSpace(valueType))
+	switch {
+	case vt == "":
+		return true
+	case vt == "string" || vt == "text" || vt == "time" || vt == "date":
+		_, ok := value.(string)
+		return ok
+	case vt == "number" || vt == "float" || vt == "float64" || vt == "float32" ||
+		strings.HasPrefix(vt, "int") || strings.HasPrefix(vt, "uint"):
+		_, ok := value.(float64)
+		return ok
+	case vt == "bool" || vt == "boolean":
+		_, ok := value.(bool)
+		return ok
+	case vt == "array" || vt == "slice" || vt == "list" || strings.HasPrefix(vt, "[]"):
+		_, ok := value.([]interface{})
+		return ok
+	case vt == "object" || vt == "map" || vt == "struct" || strings.HasPrefix(vt, "map["):
+		_, ok := value.(map[string]interface{})
+		return ok
+	default:
+		return true
+	}
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func joinField(prefix, code string) string {
+	if prefix == "" {
+		return code
+	}
+	return prefix + "." + code
+}
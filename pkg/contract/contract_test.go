@@ -0,0 +1,44 @@
+package contract
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	schema := json.RawMessage(`{"render_type":"form","children":[
+		{"code":"name","value_type":"string","required":true},
+		{"code":"age","value_type":"number"}
+	]}`)
+
+	cases := []struct {
+		name  string
+		data  string
+		rules []string
+	}{
+		{name: "ok", data: `{"name":"a","age":1}`},
+		{name: "missing required", data: `{"age":1}`, rules: []string{RuleRequired}},
+		{name: "wrong type", data: `{"name":"a","age":"1"}`, rules: []string{RuleType}},
+		{name: "unknown field", data: `{"name":"a","extra":true}`, rules: []string{RuleUnknown}},
+		{name: "table rows", data: `[{"name":"a"},{"age":2}]`, rules: []string{RuleRequired}},
+		{name: "wrong shape", data: `"a"`, rules: []string{RuleShape}},
+	}
+	for _, c := range cases {
+		var data interface{}
+		if err := json.Unmarshal([]byte(c.data), &data); err != nil {
+			t.Fatal(err)
+		}
+		violations, err := Check(schema, data)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if len(violations) != len(c.rules) {
+			t.Fatalf("%s: got %+v", c.name, violations)
+		}
+		for i, rule := range c.rules {
+			if violations[i].Rule != rule {
+				t.Errorf("%s: got rule %s, want %s", c.name, violations[i].Rule, rule)
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/yunhanshu-net/function-server/pkg/dto/api"
+)
+
+// mockTableRows 表格类返回模拟生成的行数
+const mockTableRows = 3
+
+// Mock 根据函数声明的Response参数生成模拟的data，字段值优先使用Example，没有时使用类型的零值
+// 返回的renderType为参数声明的渲染类型
+func Mock(schema json.RawMessage) (data interface{}, renderType string, err error) {
+	if len(schema) == 0 || string(schema) == "null" {
+		return map[string]interface{}{}, "", nil
+	}
+	var params api.Params
+	if err := json.Unmarshal(schema, &params); err != nil {
+		return nil, "", fmt.Errorf("解析Response参数失败: %w", err)
+	}
+
+	row := make(map[string]interface{}, len(params.Children))
+	for _, field := range params.Children {
+		if field == nil || field.Code == "" {
+			continue
+		}
+		row[field.Code] = mockValue(field)
+	}
+
+	if params.RenderType == "table" {
+		rows := make([]interface{}, 0, mockTableRows)
+		for i := 0; i < mockTableRows; i++ {
+			rows = append(rows, row)
+		}
+		return rows, params.RenderType, nil
+	}
+	return row, params.RenderType, nil
+}
+
+// mockValue 按声明的类型解析Example，解析失败时返回类型的零值
+func mockValue(field *api.ParamInfo) interface{} {
+	vt := strings.ToLower(strings.TrimSpace(field.ValueType))
+	example := strings.TrimSpace(field.Example)
+	switch {
+	case vt == "number" || vt == "float" || vt == "float64" || vt == "float32" ||
+		strings.HasPrefix(vt, "int") || strings.HasPrefix(vt, "uint"):
+		if v, err := strconv.ParseFloat(example, 64); err == nil {
+			return v
+		}
+		return 0
+	case vt == "bool" || vt == "boolean":
+		if v, err := strconv.ParseBool(example); err == nil {
+			return v
+		}
+		return false
+	case vt == "array" || vt == "slice" || vt == "list" || strings.HasPrefix(vt, "[]"):
+		var v []interface{}
+		if err := json.Unmarshal([]byte(example), &v); err == nil {
+			return v
+		}
+		return []interface{}{}
+	case vt == "object" || vt == "map" || vt == "struct" || strings.HasPrefix(vt, "map["):
+		var v map[string]interface{}
+		if err := json.Unmarshal([]byte(example), &v); err == nil {
+			return v
+		}
+		return map[string]interface{}{}
+	default:
+		return field.Example
+	}
+}
+
+// Defaults 根据函数声明的Request参数生成默认输入，取参数widget_config中的default_value，没有设置的参数不填
+func Defaults(schema json.RawMessage) (map[string]interface{}, error) {
+	inputs := make(map[string]interface{})
+	if len(schema) == 0 || string(schema) == "null" {
+		return inputs, nil
+	}
+	var params api.Params
+	if err := json.Unmarshal(schema, &params); err != nil {
+		return nil, fmt.Errorf("解析Request参数失败: %w", err)
+	}
+	for _, field := range params.Children {
+		if field == nil || field.Code == "" {
+			continue
+		}
+		widgetConfig, ok := field.WidgetConfig.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if v, ok := widgetConfig["default_value"]; ok && v != nil && v != "" {
+			inputs[field.Code] = v
+		}
+	}
+	return inputs, nil
+}
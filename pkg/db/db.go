@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/yunhanshu-net/function-server/pkg/config"
+	"github.com/yunhanshu-net/function-server/pkg/metrics"
+	"github.com/yunhanshu-net/function-server/pkg/tracing"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -51,6 +53,14 @@ func Init(cfg config.DBConfig) error {
 	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
 	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
 	sqlDB.SetConnMaxLifetime(time.Duration(cfg.MaxLifetime) * time.Second)
+	if err := metrics.RegisterDB(cfg.Name, sqlDB); err != nil {
+		return fmt.Errorf("注册数据库连接池指标失败: %w", err)
+	}
+
+	// 数据库操作记录OpenTelemetry span
+	if err := DB.Use(tracing.GormPlugin{}); err != nil {
+		return fmt.Errorf("注册链路追踪插件失败: %w", err)
+	}
 
 	// 不自动迁移数据库表结构，假设表结构已存在
 	err = DB.AutoMigrate(
@@ -61,6 +71,11 @@ func Init(cfg config.DBConfig) error {
 		&model.RunnerVersion{},
 		&model.FuncRunRecord{},
 		&model.FunctionGen{},
+		&model.RuncherNode{},
+		&model.RunnerStatusLog{},
+		&model.RunnerEnv{},
+		&model.FunctionGenReview{},
+		&model.Job{},
 	)
 	if err != nil {
 		return err
@@ -4,7 +4,9 @@ import "github.com/yunhanshu-net/pkg/query"
 
 // FunctionGenReq 获取生成函数列表请求
 type FunctionGenListReq struct {
-	RunnerID int64 `json:"runner_id" form:"runner_id"`
+	RunnerID int64  `json:"runner_id" form:"runner_id"`
+	Status   string `json:"status" form:"status"`     // 按状态过滤，如 待审核
+	Reviewer string `json:"reviewer" form:"reviewer"` // 按审核人过滤
 
 	query.PageInfoReq
 }
@@ -22,3 +24,13 @@ type FunctionGenReq struct {
 type GeneratingCount struct {
 	RunnerID int64 `json:"runner_id" form:"runner_id"`
 }
+
+// AssignReviewerReq 指定审核人
+type AssignReviewerReq struct {
+	Reviewer string `json:"reviewer" binding:"required"`
+}
+
+// ReviewFunctionGenReq 审核生成的函数，评论时comment必填
+type ReviewFunctionGenReq struct {
+	Comment string `json:"comment"`
+}
@@ -0,0 +1,25 @@
+package dto
+
+// ===========================================================================
+// 健康检查
+// ===========================================================================
+
+// 健康状态
+const (
+	HealthStatusOK       = "ok"       // 所有依赖正常
+	HealthStatusDegraded = "degraded" // runcher不可用，函数执行和部署不可用，其余接口正常
+	HealthStatusDown     = "down"     // 数据库不可用
+)
+
+// HealthCheck 单个依赖的检查结果
+type HealthCheck struct {
+	Status  string `json:"status"`          // ok, fail
+	Error   string `json:"error,omitempty"` // 失败原因
+	Latency int64  `json:"latency_ms"`      // 检查耗时（毫秒）
+}
+
+// HealthResp 健康检查响应
+type HealthResp struct {
+	Status string                  `json:"status"`           // ok, degraded, down
+	Checks map[string]*HealthCheck `json:"checks,omitempty"` // key为依赖名：mysql, runcher，存活检查不检查依赖
+}
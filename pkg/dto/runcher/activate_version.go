@@ -0,0 +1,15 @@
+package runcher
+
+// ActivateVersionReq 让runcher把runner切换到之前的版本
+type ActivateVersionReq struct {
+	User           string `json:"user"`
+	Runner         string `json:"runner"`
+	Version        string `json:"version"`         // 要切换到的版本
+	CurrentVersion string `json:"current_version"` // 当前版本
+}
+
+// ActivateVersionResp runcher切换版本后的结果，代码内容与目标版本一致，版本号可能是新生成的
+type ActivateVersionResp struct {
+	Version string `json:"version"`
+	Hash    string `json:"hash"`
+}
@@ -0,0 +1,10 @@
+package runcher
+
+// Heartbeat runcher节点心跳，节点定时发布到NATS的runcher.heartbeat，或者调用 POST /api/v1/runcher/heartbeat
+type Heartbeat struct {
+	Name     string   `json:"name" binding:"required"` // 节点名称，只能包含字母、数字、-和_
+	Address  string   `json:"address"`                 // 节点地址
+	Labels   []string `json:"labels"`                  // 节点标签
+	Capacity int      `json:"capacity"`                // 最多承载的runner数量，0表示不限制
+	Version  string   `json:"version"`                 // runcher版本
+}
@@ -8,4 +8,17 @@ type RunFunctionReq struct {
 	Body     string `json:"body"`
 	Version  string `json:"version"`
 	RawQuery string `json:"raw_query"`
+	RunID    string `json:"run_id"`
+
+	Env       string            `json:"env"`        // 执行的环境，为空表示不区分环境
+	EnvConfig map[string]string `json:"env_config"` // 环境变量
+}
+
+// CancelRunReq 取消执行中的函数
+type CancelRunReq struct {
+	User    string `json:"user"`
+	Runner  string `json:"runner"`
+	Version string `json:"version"`
+	RunID   string `json:"run_id"`
+	Reason  string `json:"reason"`
 }
@@ -0,0 +1,11 @@
+package runcher
+
+// RunnerStatusReq 通知runcher runner的状态变化，stop、suspend、archive时runcher释放runner的进程，start时重新加载
+type RunnerStatusReq struct {
+	User    string `json:"user"`
+	Runner  string `json:"runner"`
+	Version string `json:"version"`
+	Action  string `json:"action"` // start, stop, suspend, archive
+	Status  string `json:"status"` // 变更后的状态
+	Reason  string `json:"reason"`
+}
@@ -0,0 +1,15 @@
+package dto
+
+// ===========================================================================
+// runcher节点
+// ===========================================================================
+
+// UpdateRuncherNodeStatusReq 修改节点状态，draining的节点不再分配新的runner
+type UpdateRuncherNodeStatusReq struct {
+	Status string `json:"status" binding:"required,oneof=active draining"`
+}
+
+// MigrateRunnerReq 把runner迁移到另一个runcher节点
+type MigrateRunnerReq struct {
+	RuncherID int64 `json:"runcher_id" binding:"required"` // 目标节点ID
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/pkg/jsondiff"
 )
 
 // ===========================================================================
@@ -155,7 +156,9 @@ func (resp *ListRunnerResp) FromModel(runner *model.Runner) {
 // ForkRunnerReq Fork Runner请求
 type ForkRunnerReq struct {
 	BaseRequest
-	ID int64 `json:"-"` // 源Runner ID，从路径参数获取
+	ID    int64  `json:"-"`     // 源Runner ID，从路径参数获取
+	Name  string `json:"name"`  // 新Runner名称，为空时使用源Runner的名称
+	Title string `json:"title"` // 新Runner标题，为空时使用源Runner的标题
 }
 
 // ForkRunnerResp Fork Runner响应
@@ -189,3 +192,147 @@ type VersionHistoryItem struct {
 	CreatedBy string    `json:"created_by"` // 创建者
 	CreatedAt time.Time `json:"created_at"` // 创建时间
 }
+
+// RollbackRunnerReq 回滚Runner到之前的版本
+type RollbackRunnerReq struct {
+	Version string `json:"version" binding:"required"` // 要回滚到的版本
+}
+
+// RollbackRunnerResp 回滚Runner响应
+type RollbackRunnerResp struct {
+	ID         int64    `json:"id"`          // Runner ID
+	Version    string   `json:"version"`     // 回滚后的版本
+	RollbackTo string   `json:"rollback_to"` // 回滚到的版本
+	Added      []string `json:"added"`       // 恢复的函数
+	Removed    []string `json:"removed"`     // 删除的函数
+	Updated    []string `json:"updated"`     // 更新的函数
+	Skipped    []string `json:"skipped"`     // 代码已恢复但没有函数记录，需要重新创建的函数
+}
+
+// DiffRunnerVersionReq 比较Runner两个版本
+type DiffRunnerVersionReq struct {
+	From string `form:"from" binding:"required"` // 旧版本
+	To   string `form:"to"`                      // 新版本，为空时使用当前版本
+}
+
+// DiffRunnerVersionResp 两个版本的函数差异
+type DiffRunnerVersionResp struct {
+	From    string      `json:"from"`
+	To      string      `json:"to"`
+	Added   []*FuncDiff `json:"added"`   // 新增的函数
+	Removed []*FuncDiff `json:"removed"` // 删除的函数
+	Changed []*FuncDiff `json:"changed"` // 有变化的函数
+}
+
+// FuncDiff 函数在两个版本之间的差异
+type FuncDiff struct {
+	Router    string            `json:"router"`
+	Method    string            `json:"method"`
+	Name      string            `json:"name"`
+	Title     string            `json:"title"`
+	Fields    []jsondiff.Change `json:"fields,omitempty"`     // 标题、描述、超时等函数信息的变化
+	ParamsIn  []*ParamDiff      `json:"params_in,omitempty"`  // 请求参数的变化
+	ParamsOut []*ParamDiff      `json:"params_out,omitempty"` // 响应参数的变化
+	CodeDiff  string            `json:"code_diff,omitempty"`  // 源码的unified格式差异，两个版本都保存了源码时才有
+}
+
+// ParamDiff 参数的变化
+type ParamDiff struct {
+	Code        string `json:"code"`
+	Type        string `json:"type"` // added, removed, changed
+	OldType     string `json:"old_type,omitempty"`
+	NewType     string `json:"new_type,omitempty"`
+	OldRequired bool   `json:"old_required"`
+	NewRequired bool   `json:"new_required"`
+}
+
+// ===========================================================================
+// 与上游同步
+// ===========================================================================
+
+// 上游函数的变化类型
+const (
+	UpstreamChangeAdded   = "added"
+	UpstreamChangeUpdated = "updated"
+	UpstreamChangeRemoved = "removed"
+)
+
+// UpstreamStatusResp fork出的Runner相对上游的变化
+type UpstreamStatusResp struct {
+	UpstreamID      int64             `json:"upstream_id"`
+	Upstream        string            `json:"upstream"`          // 上游Runner，user/name
+	UpstreamVersion string            `json:"upstream_version"`  // 上游当前版本
+	ForkFromVersion string            `json:"fork_from_version"` // fork时上游的版本
+	Changes         []*UpstreamChange `json:"changes"`
+}
+
+// UpstreamChange 上游函数自上次同步以来的变化
+type UpstreamChange struct {
+	UpstreamFuncID int64  `json:"upstream_func_id"`
+	FuncID         int64  `json:"func_id"` // fork中对应的函数，上游新增的函数为0
+	Name           string `json:"name"`
+	Title          string `json:"title"`
+	Method         string `json:"method"`
+	Router         string `json:"router"`
+	Type           string `json:"type"`                // added, updated, removed
+	BaseVersion    string `json:"base_version"`        // 上次同步时上游的版本
+	ForkModified   bool   `json:"fork_modified"`       // fork在上次同步之后修改了函数
+	Conflict       bool   `json:"conflict"`            // 上游和fork都修改了函数
+	CodeDiff       string `json:"code_diff,omitempty"` // 上游源码相对上次同步的差异
+}
+
+// SyncUpstreamReq 同步上游的变化
+type SyncUpstreamReq struct {
+	UpstreamFuncIDs []int64 `json:"upstream_func_ids" binding:"required"` // 要同步的上游函数
+	Force           bool    `json:"force"`                                // 冲突时使用上游的代码覆盖fork的修改
+}
+
+// SyncUpstreamResp 同步结果
+type SyncUpstreamResp struct {
+	Synced          []*UpstreamChange `json:"synced"`            // 已同步的变化
+	Conflicts       []*UpstreamChange `json:"conflicts"`         // 有冲突没有同步的变化，使用force覆盖
+	ForkFromVersion string            `json:"fork_from_version"` // 同步后runner的同步版本，所有变化都同步后更新为上游当前的版本
+}
+
+// ===========================================================================
+// Runner导出
+// ===========================================================================
+
+// ExportRunnerResp 导出Runner的目录和函数源码，用于备份或在其他环境重建
+type ExportRunnerResp struct {
+	User        string           `json:"user"`
+	Name        string           `json:"name"`
+	Title       string           `json:"title"`
+	Description string           `json:"description,omitempty"`
+	Version     string           `json:"version"`
+	Packages    []*ExportPackage `json:"packages"` // 按层级排列，先创建上级目录
+	Functions   []*ExportFunc    `json:"functions"`
+}
+
+// ExportPackage 导出的package
+type ExportPackage struct {
+	Path        string `json:"path"` // 相对runner的package路径，如 order/refund
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
+// ExportFunc 导出的函数
+type ExportFunc struct {
+	Package     string `json:"package"` // 函数所在的package路径
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Method      string `json:"method"`
+	Router      string `json:"router"`
+	Code        string `json:"code"` // 函数源码，为空表示没有保存源码
+}
+
+// ===========================================================================
+// Runner状态
+// ===========================================================================
+
+// ChangeRunnerStatusReq 启动、停止、暂停、归档Runner
+type ChangeRunnerStatusReq struct {
+	Reason string `json:"reason"` // 变更原因
+}
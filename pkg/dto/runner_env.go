@@ -0,0 +1,21 @@
+package dto
+
+// ===========================================================================
+// Runner发布环境
+// ===========================================================================
+
+// SetRunnerEnvVersionReq 把环境指向runner的某个版本
+type SetRunnerEnvVersionReq struct {
+	Version string `json:"version" binding:"required"`
+}
+
+// PromoteRunnerEnvReq 把一个环境的版本发布到后面的环境
+type PromoteRunnerEnvReq struct {
+	From string `json:"from" binding:"required"` // 源环境，如 dev
+	To   string `json:"to" binding:"required"`   // 目标环境，如 staging
+}
+
+// SetRunnerEnvConfigReq 设置环境变量
+type SetRunnerEnvConfigReq struct {
+	Config map[string]string `json:"config"`
+}
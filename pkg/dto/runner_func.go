@@ -1,11 +1,14 @@
 package dto
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/yunhanshu-net/pkg/query"
 
 	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/pkg/contract"
+	"github.com/yunhanshu-net/function-server/pkg/jsondiff"
 )
 
 // ===========================================================================
@@ -136,16 +139,25 @@ type UpdateRunnerFuncReq struct {
 	IsPublic bool   `json:"is_public"`                // 是否公开
 	Content  string `json:"content"`                  // 函数内容
 	Config   string `json:"config"`                   // 函数配置
+
+	ContractMode string `json:"contract_mode"` // 返回值契约校验模式：off, warn, strict
+	RedactFields string `json:"redact_fields"` // 执行记录脱敏规则，逗号分隔的字段名或json路径
+	PayloadStore string `json:"payload_store"` // 执行记录保存方式：full, hash
+	Mock         string `json:"mock"`          // 模拟模式：on, off
 }
 
 // ToModel 转换为模型
 func (req *UpdateRunnerFuncReq) ToModel() *model.RunnerFunc {
 	return &model.RunnerFunc{
-		Name:        req.Name,
-		Title:       req.Title,
-		TreeID:      req.TreeID,
-		Description: req.Desc,
-		IsPublic:    req.IsPublic,
+		Name:         req.Name,
+		Title:        req.Title,
+		TreeID:       req.TreeID,
+		Description:  req.Desc,
+		IsPublic:     req.IsPublic,
+		ContractMode: req.ContractMode,
+		RedactFields: req.RedactFields,
+		PayloadStore: req.PayloadStore,
+		Mock:         req.Mock,
 	}
 }
 
@@ -165,6 +177,12 @@ func (resp *UpdateRunnerFuncResp) FromModel(runnerFunc *model.RunnerFunc) {
 	resp.UpdatedAt = time.Time(runnerFunc.UpdatedAt)
 }
 
+// UpdateFuncCodeReq 修改函数代码并重新部署请求
+type UpdateFuncCodeReq struct {
+	Code    string `json:"code" binding:"required"` // 新的函数代码
+	Comment string `json:"comment"`                 // 修改说明
+}
+
 // ===========================================================================
 // 删除RunnerFunc
 // ===========================================================================
@@ -374,6 +392,35 @@ func (resp *SaveVersionResp) FromModel(version *model.FuncVersion) {
 	resp.CreatedAt = time.Time(version.CreatedAt)
 }
 
+// ===========================================================================
+// 获取函数源码
+// ===========================================================================
+
+// GetFuncSourceReq 获取函数源码请求
+type GetFuncSourceReq struct {
+	Version string `form:"version"` // 函数版本，为空时获取当前版本
+}
+
+// GetFuncSourceResp 获取函数源码响应
+type GetFuncSourceResp struct {
+	FuncID    int64     `json:"func_id"`    // 函数 ID
+	Version   string    `json:"version"`    // 版本号
+	Hash      string    `json:"hash"`       // 版本哈希
+	Code      string    `json:"code"`       // 源码
+	CreatedBy string    `json:"created_by"` // 创建者
+	CreatedAt time.Time `json:"created_at"` // 创建时间
+}
+
+// FromModel 从模型转换
+func (resp *GetFuncSourceResp) FromModel(version *model.FuncVersion, code string) {
+	resp.FuncID = version.FuncID
+	resp.Version = version.Version
+	resp.Hash = version.Hash
+	resp.Code = code
+	resp.CreatedBy = version.CreatedBy
+	resp.CreatedAt = time.Time(version.CreatedAt)
+}
+
 // ===========================================================================
 // 更新函数状态
 // ===========================================================================
@@ -440,3 +487,91 @@ func (resp *GetUserRecentFuncRecordsResp) FromFuncRunRecord(record *model.FuncRu
 		resp.FullNamePath = serviceTree.FullNamePath
 	}
 }
+
+// ===========================================================================
+// 函数返回值契约
+// ===========================================================================
+
+// ContractViolationRecord 一次执行的契约违规记录
+type ContractViolationRecord struct {
+	RecordID   int64                `json:"record_id"`  // 执行记录ID
+	Status     string               `json:"status"`     // 执行状态
+	Violations []contract.Violation `json:"violations"` // 违规明细
+	CreatedAt  time.Time            `json:"created_at"` // 执行时间
+}
+
+// GetContractStatsResp 获取函数契约违规统计响应
+type GetContractStatsResp struct {
+	FuncID         int64                      `json:"func_id"`         // 函数ID
+	Mode           string                     `json:"mode"`            // 当前生效的校验模式
+	CheckedCount   int64                      `json:"checked_count"`   // 做过校验的执行次数
+	ViolationCount int64                      `json:"violation_count"` // 违规的执行次数
+	Recent         []*ContractViolationRecord `json:"recent"`          // 最近的违规记录
+}
+
+// ===========================================================================
+// 重放执行记录
+// ===========================================================================
+
+// ReplayRunReq 重放执行记录请求
+type ReplayRunReq struct {
+	Version string `json:"version"` // 重放使用的runner版本，为空时使用原记录的版本
+}
+
+// ReplayRunResp 重放执行记录响应
+type ReplayRunResp struct {
+	RecordID          int64             `json:"record_id"`          // 重放生成的新执行记录ID
+	ReplayOf          int64             `json:"replay_of"`          // 原执行记录ID
+	OldVersion        string            `json:"old_version"`        // 原执行的runner版本
+	NewVersion        string            `json:"new_version"`        // 重放的runner版本
+	OldStatus         string            `json:"old_status"`         // 原执行状态
+	NewStatus         string            `json:"new_status"`         // 重放执行状态
+	Message           string            `json:"message"`            // 重放执行的错误信息
+	Old               json.RawMessage   `json:"old"`                // 原执行结果
+	New               json.RawMessage   `json:"new"`                // 重放执行结果
+	Same              bool              `json:"same"`               // 两次结果是否一致
+	OriginalTruncated bool              `json:"original_truncated"` // 原执行结果被截断或只保存了哈希，差异仅供参考
+	Changes           []jsondiff.Change `json:"changes"`            // 差异明细，trace_id和meta_data不参与比较
+}
+
+// ===========================================================================
+// 函数回调
+// ===========================================================================
+
+// FuncCallbackInfo 函数支持的回调
+type FuncCallbackInfo struct {
+	Type    string `json:"type"`    // 回调类型
+	Desc    string `json:"desc"`    // 回调说明
+	Known   bool   `json:"known"`   // 是否为平台已知的回调类型
+	Timeout int    `json:"timeout"` // 超时时间（秒）
+}
+
+// ===========================================================================
+// 打开函数页面
+// ===========================================================================
+
+// OpenFuncResp 打开函数页面响应，AutoRun函数会同时返回默认输入的执行结果
+type OpenFuncResp struct {
+	Func    *model.RunnerFunc      `json:"func"`             // 函数信息
+	AutoRun bool                   `json:"auto_run"`         // 是否自动运行
+	Inputs  map[string]interface{} `json:"inputs,omitempty"` // 自动运行使用的默认输入
+	Result  interface{}            `json:"result,omitempty"` // 自动运行的结果，格式同执行函数接口
+	Cached  bool                   `json:"cached"`           // 结果是否来自缓存
+	RunAt   *time.Time             `json:"run_at,omitempty"` // 结果的执行时间
+	Error   string                 `json:"error,omitempty"`  // 自动运行失败的原因，不影响函数信息的返回
+}
+
+// ===========================================================================
+// 执行中的函数
+// ===========================================================================
+
+// InflightRun 执行中的函数
+type InflightRun struct {
+	RunID   string    `json:"run_id"`   // 执行ID
+	User    string    `json:"user"`     // runner所属用户
+	Runner  string    `json:"runner"`   // runner名称
+	Version string    `json:"version"`  // runner版本
+	Method  string    `json:"method"`   // 请求方法
+	Router  string    `json:"router"`   // 函数路由
+	StartAt time.Time `json:"start_at"` // 开始执行时间
+}
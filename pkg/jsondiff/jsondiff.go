@@ -0,0 +1,103 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+const (
+	TypeAdded   = "added"
+	TypeRemoved = "removed"
+	TypeChanged = "changed"
+)
+
+// Change 一处差异
+type Change struct {
+	Path string      `json:"path"` // 差异所在路径，如 data.list[0].name
+	Type string      `json:"type"` // added, removed, changed
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// DiffRaw 比较两段json
+func DiffRaw(oldRaw, newRaw json.RawMessage) ([]Change, error) {
+	var oldVal, newVal interface{}
+	if len(oldRaw) > 0 {
+		if err := json.Unmarshal(oldRaw, &oldVal); err != nil {
+			return nil, fmt.Errorf("解析旧json失败: %w", err)
+		}
+	}
+	if len(newRaw) > 0 {
+		if err := json.Unmarshal(newRaw, &newVal); err != nil {
+			return nil, fmt.Errorf("解析新json失败: %w", err)
+		}
+	}
+	return Diff(oldVal, newVal), nil
+}
+
+// Diff 比较两个json解析后的值，返回按路径排序的差异列表
+func Diff(oldVal, newVal interface{}) []Change {
+	var changes []Change
+	diff("", oldVal, newVal, &changes)
+	return changes
+}
+
+func diff(path string, oldVal, newVal interface{}, changes *[]Change) {
+	switch o := oldVal.(type) {
+	case map[string]interface{}:
+		n, ok := newVal.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(o)+len(n))
+		for k := range o {
+			keys = append(keys, k)
+		}
+		for k := range n {
+			if _, exist := o[k]; !exist {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			childPath := k
+			if path != "" {
+				childPath = path + "." + k
+			}
+			ov, oldOk := o[k]
+			nv, newOk := n[k]
+			switch {
+			case !oldOk:
+				*changes = append(*changes, Change{Path: childPath, Type: TypeAdded, New: nv})
+			case !newOk:
+				*changes = append(*changes, Change{Path: childPath, Type: TypeRemoved, Old: ov})
+			default:
+				diff(childPath, ov, nv, changes)
+			}
+		}
+		return
+	case []interface{}:
+		n, ok := newVal.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(o) || i < len(n); i++ {
+			childPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(o):
+				*changes = append(*changes, Change{Path: childPath, Type: TypeAdded, New: n[i]})
+			case i >= len(n):
+				*changes = append(*changes, Change{Path: childPath, Type: TypeRemoved, Old: o[i]})
+			default:
+				diff(childPath, o[i], n[i], changes)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(oldVal, newVal) {
+		*changes = append(*changes, Change{Path: path, Type: TypeChanged, Old: oldVal, New: newVal})
+	}
+}
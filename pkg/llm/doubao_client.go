@@ -9,6 +9,8 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/yunhanshu-net/function-server/pkg/metrics"
 )
 
 // DoubaoConfig 豆包配置
@@ -131,6 +133,7 @@ func (c *DoubaoClient) Chat(ctx context.Context, messages []DoubaoMessage) (*Dou
 		return nil, fmt.Errorf("doubao api error: %s", doubaoResp.Error.Message)
 	}
 
+	metrics.AddLLMTokens("doubao", c.config.Model, doubaoResp.Usage.PromptTokens, doubaoResp.Usage.CompletionTokens)
 	if len(doubaoResp.Choices) == 0 {
 		return nil, fmt.Errorf("no choices in response")
 	}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/yunhanshu-net/function-server/pkg/metrics"
 	"github.com/yunhanshu-net/pkg/logger"
 )
 
@@ -186,6 +187,8 @@ func (c *QwenClient) doRequest(ctx context.Context, req *QwenRequest) (*QwenResp
 		return nil, fmt.Errorf("解析千问响应失败: %w, 响应内容: %s", err, string(respBody))
 	}
 
+	metrics.AddLLMTokens("qwen", req.Model, qwenResp.Usage.PromptTokens, qwenResp.Usage.CompletionTokens)
+
 	// 验证响应
 	if len(qwenResp.Choices) == 0 {
 		return nil, fmt.Errorf("千问返回空的响应选择")
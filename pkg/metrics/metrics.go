@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "function_server"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP请求数，按方法、路由和状态码统计",
+	}, []string{"method", "route", "code"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP请求耗时，按方法和路由统计",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	functionRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "function_runs_total",
+		Help:      "函数执行次数，status为success以外的都是失败",
+	}, []string{"runner", "function", "status"})
+
+	functionRunDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "function_run_duration_seconds",
+		Help:      "函数执行耗时",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, []string{"runner", "function"})
+
+	runcherDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "runcher_request_duration_seconds",
+		Help:      "runcher NATS请求耗时，按subject统计",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, []string{"subject"})
+
+	runcherFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "runcher_request_failures_total",
+		Help:      "runcher NATS请求失败次数，按subject统计",
+	}, []string{"subject"})
+
+	generationInflight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "function_gen_inflight",
+		Help:      "执行中的函数生成任务数",
+	}, []string{"provider"})
+
+	llmTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_tokens_total",
+		Help:      "大模型token用量，type为prompt或completion",
+	}, []string{"provider", "model", "type"})
+)
+
+// Handler /metrics 接口
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware 统计HTTP请求数和耗时，路由使用注册时的路径避免标签过多
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unknown"
+		}
+		httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveFunctionRun 记录一次函数执行，runner为user/runner，function为函数名，没有找到函数时为unknown
+func ObserveFunctionRun(runner, function, status string, cost time.Duration) {
+	functionRuns.WithLabelValues(runner, function, status).Inc()
+	functionRunDuration.WithLabelValues(runner, function).Observe(cost.Seconds())
+}
+
+// ObserveRuncherRequest 记录一次runcher请求，subject中的user、runner、version用*代替
+func ObserveRuncherRequest(subject string, cost time.Duration, err error) {
+	runcherDuration.WithLabelValues(subject).Observe(cost.Seconds())
+	if err != nil {
+		runcherFailures.WithLabelValues(subject).Inc()
+	}
+}
+
+// TrackGeneration 函数生成任务开始，返回的函数在任务结束时调用
+func TrackGeneration(provider string) func() {
+	gauge := generationInflight.WithLabelValues(provider)
+	gauge.Inc()
+	return gauge.Dec
+}
+
+// AddLLMTokens 记录大模型token用量
+func AddLLMTokens(provider, model string, promptTokens, completionTokens int) {
+	llmTokens.WithLabelValues(provider, model, "prompt").Add(float64(promptTokens))
+	llmTokens.WithLabelValues(provider, model, "completion").Add(float64(completionTokens))
+}
+
+// RegisterDB 注册数据库连接池指标，重复注册时忽略
+func RegisterDB(name string, db *sql.DB) error {
+	err := prometheus.Register(collectors.NewDBStatsCollector(db, name))
+	var already prometheus.AlreadyRegisteredError
+	if errors.As(err, &already) {
+		return nil
+	}
+	return err
+}
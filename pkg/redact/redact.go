@@ -0,0 +1,197 @@
+package redact
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Mask 脱敏后的占位值
+const Mask = "******"
+
+// DefaultFields 全局默认的脱敏字段，配置中没有设置时使用
+var DefaultFields = []string{
+	"password", "passwd", "pwd",
+	"token", "access_token", "refresh_token",
+	"secret", "api_key", "apikey", "authorization",
+}
+
+// Options 处理选项
+type Options struct {
+	Fields   []string // 脱敏规则，不带"."的按字段名在任意层级匹配，带"."的按json路径从根匹配，路径中可以使用*
+	MaxSize  int      // 最大保存字节数，小于等于0表示不限制
+	HashOnly bool     // 只保存哈希
+}
+
+// Truncated 被截断的内容保存的结构
+type Truncated struct {
+	Truncated bool   `json:"truncated"`
+	Size      int    `json:"size"`
+	Preview   string `json:"preview"`
+}
+
+// Hashed 只保存哈希时保存的结构
+type Hashed struct {
+	Sha256 string `json:"sha256"`
+	Size   int    `json:"size"`
+}
+
+// Apply 按选项处理一段json，返回处理后的json以及是否被截断
+// 先脱敏，再根据选项哈希或截断，保证结果依然是合法的json
+func Apply(raw json.RawMessage, opts Options) (json.RawMessage, bool) {
+	if len(raw) == 0 {
+		return raw, false
+	}
+	out := Fields(raw, opts.Fields)
+
+	if opts.HashOnly {
+		return hash(out), false
+	}
+
+	if opts.MaxSize > 0 && len(out) > opts.MaxSize {
+		preview := out[:opts.MaxSize]
+		// 避免把一个utf8字符截成两半
+		for len(preview) > 0 && !utf8.Valid(preview) {
+			preview = preview[:len(preview)-1]
+		}
+		truncated, _ := json.Marshal(Truncated{Truncated: true, Size: len(out), Preview: string(preview)})
+		return truncated, true
+	}
+	return out, false
+}
+
+// Fields 按规则脱敏json，不是json时的处理同FieldsMasked
+func Fields(raw json.RawMessage, rules []string) json.RawMessage {
+	out, _ := FieldsMasked(raw, rules)
+	return out
+}
+
+// FieldsMasked 按规则脱敏json，同时返回是否有字段被替换为占位值
+// 不是json时按表单（key=value&...）按字段名脱敏，有字段被替换时结果保存为json字符串；
+// 既不是json也不是表单时无法判断是否包含敏感字段，只保存哈希并视为已脱敏
+func FieldsMasked(raw json.RawMessage, rules []string) (json.RawMessage, bool) {
+	if len(raw) == 0 || len(rules) == 0 {
+		return raw, false
+	}
+
+	names := make(map[string]struct{})
+	var paths [][]string
+	for _, rule := range rules {
+		rule = strings.TrimPrefix(strings.TrimSpace(rule), "$.")
+		if rule == "" {
+			continue
+		}
+		if strings.Contains(rule, ".") {
+			paths = append(paths, strings.Split(rule, "."))
+		} else {
+			names[strings.ToLower(rule)] = struct{}{}
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return formMasked(raw, names)
+	}
+
+	w := &walker{names: names, paths: paths}
+	v = w.walk(v, nil)
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return raw, false
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), w.masked
+}
+
+// formMasked 脱敏表单中名称匹配的字段，保留其他字段的原始内容和顺序
+func formMasked(raw []byte, names map[string]struct{}) (json.RawMessage, bool) {
+	text := strings.TrimSpace(string(raw))
+	if !utf8.ValidString(text) || !strings.Contains(text, "=") {
+		return hash(raw), true
+	}
+	pairs := strings.Split(text, "&")
+	masked := false
+	for i, pair := range pairs {
+		key, _, found := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			return hash(raw), true
+		}
+		if _, ok := names[strings.ToLower(name)]; ok && found {
+			pairs[i] = key + "=" + Mask
+			masked = true
+		}
+	}
+	if !masked {
+		return raw, false
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(strings.Join(pairs, "&"))
+	return bytes.TrimRight(buf.Bytes(), "\n"), true
+}
+
+// hash 返回内容的哈希
+func hash(raw []byte) json.RawMessage {
+	sum := sha256.Sum256(raw)
+	hashed, _ := json.Marshal(Hashed{Sha256: hex.EncodeToString(sum[:]), Size: len(raw)})
+	return hashed
+}
+
+type walker struct {
+	names  map[string]struct{}
+	paths  [][]string
+	masked bool
+}
+
+func (w *walker) walk(v interface{}, path []string) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			childPath := append(path[:len(path):len(path)], k)
+			if _, ok := w.names[strings.ToLower(k)]; ok || w.matchPath(childPath) {
+				val[k] = Mask
+				w.masked = true
+				continue
+			}
+			val[k] = w.walk(child, childPath)
+		}
+		return val
+	case []interface{}:
+		// 数组对路径透明，a.b 同样匹配 a[0].b
+		for i, child := range val {
+			val[i] = w.walk(child, path)
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+func (w *walker) matchPath(path []string) bool {
+	for _, rule := range w.paths {
+		if len(rule) != len(path) {
+			continue
+		}
+		matched := true
+		for i, seg := range rule {
+			if seg != "*" && seg != path[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
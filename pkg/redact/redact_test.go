@@ -0,0 +1,55 @@
+package redact
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestFields(t *testing.T) {
+	raw := json.RawMessage(`{"user":"a","Password":"p","data":{"list":[{"id":1,"secret_key":"s"}],"token":"t"}}`)
+	got := string(Fields(raw, []string{"password", "token", "data.list.secret_key"}))
+	want := `{"Password":"******","data":{"list":[{"id":1,"secret_key":"******"}],"token":"******"},"user":"a"}`
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	if _, masked := FieldsMasked(raw, []string{"password"}); !masked {
+		t.Fatal("expect masked")
+	}
+	if _, masked := FieldsMasked(raw, []string{"not_exist"}); masked {
+		t.Fatal("expect not masked")
+	}
+
+	form := json.RawMessage(`user=a&Password=p%3D1&token`)
+	out, masked := FieldsMasked(form, DefaultFields)
+	if !masked || string(out) != `"user=a&Password=******&token"` {
+		t.Fatalf("expect masked form, got %s masked=%v", out, masked)
+	}
+	plain := json.RawMessage(`user=a&id=1`)
+	if out, masked := FieldsMasked(plain, DefaultFields); masked || string(out) != string(plain) {
+		t.Fatalf("form without sensitive fields should be returned as is, got %s", out)
+	}
+
+	invalid := json.RawMessage(`not json`)
+	out, masked = FieldsMasked(invalid, DefaultFields)
+	var hashed Hashed
+	if !masked || json.Unmarshal(out, &hashed) != nil || hashed.Sha256 == "" {
+		t.Fatalf("unparseable body should be hashed, got %s", out)
+	}
+}
+
+func TestApply(t *testing.T) {
+	raw := json.RawMessage(`{"text":"` + strings.Repeat("中", 100) + `"}`)
+
+	out, truncated := Apply(raw, Options{MaxSize: 32})
+	if !truncated || !json.Valid(out) {
+		t.Fatalf("expect valid truncated json, got %s", out)
+	}
+
+	out, truncated = Apply(raw, Options{MaxSize: 32, HashOnly: true})
+	var hashed Hashed
+	if truncated || json.Unmarshal(out, &hashed) != nil || hashed.Sha256 == "" {
+		t.Fatalf("expect hashed json, got %s", out)
+	}
+}
@@ -13,7 +13,9 @@ const (
 	CodeUnauthorized = 401
 	CodeForbidden    = 403
 	CodeNotFound     = 404
+	CodeConflict     = 409
 	CodeServerError  = 500
+	CodeUnavailable  = 503
 )
 
 // 响应消息
@@ -23,7 +25,9 @@ var msgMap = map[int]string{
 	CodeUnauthorized: "未授权",
 	CodeForbidden:    "禁止访问",
 	CodeNotFound:     "资源不存在",
+	CodeConflict:     "当前状态不允许该操作",
 	CodeServerError:  "服务器内部错误",
+	CodeUnavailable:  "服务暂不可用",
 }
 
 // Response 统一响应结构
@@ -64,8 +68,12 @@ func Fail(c *gin.Context, code int, msg string) {
 		httpStatus = http.StatusForbidden
 	case CodeNotFound:
 		httpStatus = http.StatusNotFound
+	case CodeConflict:
+		httpStatus = http.StatusConflict
 	case CodeServerError:
 		httpStatus = http.StatusInternalServerError
+	case CodeUnavailable:
+		httpStatus = http.StatusServiceUnavailable
 	}
 
 	c.JSON(httpStatus, Response{
@@ -95,7 +103,17 @@ func NotFound(c *gin.Context, msg string) {
 	Fail(c, CodeNotFound, msg)
 }
 
+// Conflict 资源当前状态不允许该操作，如执行已停止的runner
+func Conflict(c *gin.Context, msg string) {
+	Fail(c, CodeConflict, msg)
+}
+
 // ServerError 服务器错误响应
 func ServerError(c *gin.Context, msg string) {
 	Fail(c, CodeServerError, msg)
 }
+
+// Unavailable 服务不可用响应，依赖的服务（如runcher）异常时使用
+func Unavailable(c *gin.Context, msg string) {
+	Fail(c, CodeUnavailable, msg)
+}
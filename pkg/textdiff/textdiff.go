@@ -0,0 +1,124 @@
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxLines 超过该行数时不计算差异，避免O(n*m)的内存占用
+const maxLines = 5000
+
+// Unified 按行比较两段文本，返回unified格式的差异，没有差异时返回空字符串
+// context为每处差异前后保留的相同行数
+func Unified(oldName, newName, oldText, newText string, context int) string {
+	if oldText == newText {
+		return ""
+	}
+	a, b := splitLines(oldText), splitLines(newText)
+	if len(a) > maxLines || len(b) > maxLines {
+		return fmt.Sprintf("--- %s\n+++ %s\n@@ 文件过大，不计算差异 @@\n", oldName, newName)
+	}
+	ops := diffLines(a, b)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// 找到一段差异以及前后的context行
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' && next-end < 2*context {
+				next++
+			}
+			if next < len(ops) && ops[next].kind != ' ' {
+				end = next
+				continue
+			}
+			break
+		}
+		stop := end + context
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+		oldStart, newStart, oldCount, newCount := ops[start].oldLine, ops[start].newLine, 0, 0
+		for _, op := range ops[start:stop] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, op := range ops[start:stop] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.text)
+			sb.WriteByte('\n')
+		}
+		i = stop
+	}
+	return sb.String()
+}
+
+type op struct {
+	kind    byte // ' ' 相同，'-' 删除，'+' 新增
+	text    string
+	oldLine int // 从1开始的行号
+	newLine int
+}
+
+// diffLines 基于最长公共子序列计算逐行的编辑序列
+func diffLines(a, b []string) []op {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var ops []op
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			ops = append(ops, op{kind: ' ', text: a[i], oldLine: i + 1, newLine: j + 1})
+			i++
+			j++
+		case j < m && (i == n || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, op{kind: '+', text: b[j], oldLine: i + 1, newLine: j + 1})
+			j++
+		default:
+			ops = append(ops, op{kind: '-', text: a[i], oldLine: i + 1, newLine: j + 1})
+			i++
+		}
+	}
+	return ops
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,15 @@
+package textdiff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	if got := Unified("a", "b", "x\n", "x\n", 3); got != "" {
+		t.Fatalf("相同文本不应有差异: %q", got)
+	}
+	old := "package demo\n\nfunc Add(a, b int) int {\n\treturn a + b\n}\n"
+	cur := "package demo\n\nfunc Add(a, b int) int {\n\treturn a + b + 1\n}\n"
+	want := "--- v1\n+++ v2\n@@ -2,4 +2,4 @@\n \n func Add(a, b int) int {\n-\treturn a + b\n+\treturn a + b + 1\n }\n"
+	if got := Unified("v1", "v2", old, cur, 2); got != want {
+		t.Fatalf("差异不符合预期:\n%s\n期望:\n%s", got, want)
+	}
+}
@@ -0,0 +1,164 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/yunhanshu-net/pkg/constants"
+)
+
+// W3C Trace Context 请求头
+const (
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+)
+
+// context中保存traceparent和tracestate的key，trace_id沿用constants.TraceID，日志会从中读取
+const (
+	KeyTraceparent = "traceparent"
+	KeyTracestate  = "tracestate"
+)
+
+const (
+	traceIDLen  = 32
+	parentIDLen = 16
+)
+
+// Traceparent W3C traceparent，格式：version-trace_id-parent_id-flags
+type Traceparent struct {
+	Version  string
+	TraceID  string
+	ParentID string
+	Flags    string
+}
+
+// Parse 解析traceparent，格式不合法时ok为false
+func Parse(s string) (tp Traceparent, ok bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 {
+		return tp, false
+	}
+	tp = Traceparent{Version: parts[0], TraceID: parts[1], ParentID: parts[2], Flags: parts[3]}
+	// 版本00必须正好4段，未来的版本允许在后面追加字段
+	if tp.Version == "00" && len(parts) != 4 {
+		return tp, false
+	}
+	if !isHex(tp.Version, 2) || tp.Version == "ff" ||
+		!isHex(tp.TraceID, traceIDLen) || isZero(tp.TraceID) ||
+		!isHex(tp.ParentID, parentIDLen) || isZero(tp.ParentID) ||
+		!isHex(tp.Flags, 2) {
+		return tp, false
+	}
+	return tp, true
+}
+
+// New 生成一个新的traceparent，默认采样
+func New() Traceparent {
+	return Traceparent{Version: "00", TraceID: randomHex(traceIDLen), ParentID: randomHex(parentIDLen), Flags: "01"}
+}
+
+// NewWithTraceID 使用已有的trace_id生成traceparent，trace_id不是合法的W3C格式时由它推导
+func NewWithTraceID(traceID string) Traceparent {
+	tp := New()
+	if derived := DeriveTraceID(traceID); derived != "" {
+		tp.TraceID = derived
+	}
+	return tp
+}
+
+// DeriveTraceID 把旧格式的跟踪ID转换为W3C的trace_id，同一个跟踪ID总是得到同一个trace_id
+// 去掉"-"并转小写后是32位十六进制（如uuid）时直接使用，否则取sha256的前16字节，为空时返回空
+func DeriveTraceID(traceID string) string {
+	if traceID == "" {
+		return ""
+	}
+	if id := strings.ToLower(strings.ReplaceAll(traceID, "-", "")); isHex(id, traceIDLen) && !isZero(id) {
+		return id
+	}
+	sum := sha256.Sum256([]byte(traceID))
+	return hex.EncodeToString(sum[:traceIDLen/2])
+}
+
+// Child 生成同一条链路上的下一跳，trace_id不变，parent_id重新生成
+func (tp Traceparent) Child() Traceparent {
+	return Traceparent{Version: "00", TraceID: tp.TraceID, ParentID: randomHex(parentIDLen), Flags: tp.Flags}
+}
+
+// String 输出traceparent请求头的值
+func (tp Traceparent) String() string {
+	return tp.Version + "-" + tp.TraceID + "-" + tp.ParentID + "-" + tp.Flags
+}
+
+// WithContext 把链路信息放到context中
+func WithContext(ctx context.Context, traceID, traceparent, tracestate string) context.Context {
+	ctx = context.WithValue(ctx, constants.TraceID, traceID)
+	ctx = context.WithValue(ctx, KeyTraceparent, traceparent)
+	return context.WithValue(ctx, KeyTracestate, tracestate)
+}
+
+// FromContext 获取context中的trace_id
+func FromContext(ctx context.Context) string {
+	return stringValue(ctx, constants.TraceID)
+}
+
+// TraceparentFromContext 获取context中的traceparent，没有时根据trace_id生成
+func TraceparentFromContext(ctx context.Context) string {
+	if traceparent := stringValue(ctx, KeyTraceparent); traceparent != "" {
+		return traceparent
+	}
+	if traceID := FromContext(ctx); traceID != "" {
+		return NewWithTraceID(traceID).String()
+	}
+	return ""
+}
+
+// TracestateFromContext 获取context中的tracestate
+func TracestateFromContext(ctx context.Context) string {
+	return stringValue(ctx, KeyTracestate)
+}
+
+// Detach 返回一个只带有链路信息的新context，用于请求结束后还要继续执行的goroutine
+func Detach(ctx context.Context) context.Context {
+	return WithContext(context.Background(), FromContext(ctx), stringValue(ctx, KeyTraceparent), TracestateFromContext(ctx))
+}
+
+func stringValue(ctx context.Context, key string) string {
+	if ctx == nil {
+		return ""
+	}
+	if v, ok := ctx.Value(key).(string); ok {
+		return v
+	}
+	return ""
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n/2)
+	for {
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+		if s := hex.EncodeToString(b); !isZero(s) {
+			return s
+		}
+	}
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
@@ -0,0 +1,60 @@
+package trace
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in string
+		ok bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"20240101-uuid", false},
+	}
+	for _, c := range cases {
+		if _, ok := Parse(c.in); ok != c.ok {
+			t.Errorf("Parse(%q) ok = %v, want %v", c.in, ok, c.ok)
+		}
+	}
+}
+
+func TestChild(t *testing.T) {
+	tp := New()
+	if _, ok := Parse(tp.String()); !ok {
+		t.Fatalf("generated traceparent is invalid: %s", tp)
+	}
+	child := tp.Child()
+	if child.TraceID != tp.TraceID || child.ParentID == tp.ParentID {
+		t.Fatalf("child should keep trace id and change parent id: %s -> %s", tp, child)
+	}
+}
+
+func TestDeriveTraceID(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"4bf92f3577b34da6a3ce929d0e0e4736", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"4BF92F35-77B3-4DA6-A3CE-929D0E0E4736", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if got := DeriveTraceID(c.in); got != c.want {
+			t.Errorf("DeriveTraceID(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+
+	legacy := "20240101-uuid"
+	derived := DeriveTraceID(legacy)
+	if !isHex(derived, traceIDLen) || derived != DeriveTraceID(legacy) {
+		t.Fatalf("legacy trace id should derive a stable W3C trace id, got %q", derived)
+	}
+	if tp := NewWithTraceID(legacy); tp.TraceID != derived {
+		t.Fatalf("traceparent should use derived trace id, got %s", tp)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/gin-gonic/gin"
+	"github.com/yunhanshu-net/function-server/pkg/trace"
 	"github.com/yunhanshu-net/pkg/constants"
 	"github.com/yunhanshu-net/pkg/logger"
 )
@@ -23,12 +24,7 @@ func GetTraceID(c *gin.Context) string {
 
 // GetTraceIDFromContext 从context中获取TraceID
 func GetTraceIDFromContext(ctx context.Context) string {
-	if id := ctx.Value(constants.TraceID); id != nil {
-		if traceID, ok := id.(string); ok {
-			return traceID
-		}
-	}
-	return ""
+	return trace.FromContext(ctx)
 }
 
 // GetContextWithTraceID 创建带有跟踪ID的上下文
@@ -0,0 +1,72 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// FunctionGenRepo 生成函数仓库
+type FunctionGenRepo struct {
+	db *gorm.DB
+}
+
+// NewFunctionGenRepo 创建生成函数仓库
+func NewFunctionGenRepo(db *gorm.DB) *FunctionGenRepo {
+	return &FunctionGenRepo{db: db}
+}
+
+// GetDB 获取数据库连接
+func (r *FunctionGenRepo) GetDB() *gorm.DB {
+	return r.db
+}
+
+// Get 获取生成记录，不存在时返回nil
+func (r *FunctionGenRepo) Get(ctx context.Context, id int64) (*model.FunctionGen, error) {
+	var gen model.FunctionGen
+	err := r.db.WithContext(ctx).First(&gen, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		logger.Error(ctx, "获取生成函数失败", err, zap.Int64("id", id))
+		return nil, err
+	}
+	return &gen, nil
+}
+
+// UpdateStatusFrom 状态为from时更新，返回是否更新成功，用于防止重复审核
+func (r *FunctionGenRepo) UpdateStatusFrom(ctx context.Context, tx *gorm.DB, id int64, from []string, updates map[string]interface{}) (bool, error) {
+	result := tx.WithContext(ctx).Model(&model.FunctionGen{}).Where("id = ? AND status IN ?", id, from).Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+// Update 更新生成记录
+func (r *FunctionGenRepo) Update(ctx context.Context, id int64, updates map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(&model.FunctionGen{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// CreateReviewWithTx 使用事务保存审核记录
+func (r *FunctionGenRepo) CreateReviewWithTx(ctx context.Context, tx *gorm.DB, review *model.FunctionGenReview) error {
+	return tx.WithContext(ctx).Create(review).Error
+}
+
+// GetReviews 获取审核记录，按时间顺序排列
+func (r *FunctionGenRepo) GetReviews(ctx context.Context, genID int64) ([]model.FunctionGenReview, error) {
+	var reviews []model.FunctionGenReview
+	err := r.db.WithContext(ctx).Where("gen_id = ?", genID).Order("id ASC").Find(&reviews).Error
+	if err != nil {
+		logger.Error(ctx, "获取审核记录失败", err, zap.Int64("gen_id", genID))
+		return nil, err
+	}
+	return reviews, nil
+}
+
+// SetFuncDisabledWithTx 使用事务启用或停用函数
+func (r *FunctionGenRepo) SetFuncDisabledWithTx(ctx context.Context, tx *gorm.DB, funcID int64, disabled bool) error {
+	return tx.WithContext(ctx).Model(&model.RunnerFunc{}).Where("id = ?", funcID).Update("disabled", disabled).Error
+}
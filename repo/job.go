@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// JobRepo 后台任务仓库
+type JobRepo struct {
+	db *gorm.DB
+}
+
+// NewJobRepo 创建后台任务仓库
+func NewJobRepo(db *gorm.DB) *JobRepo {
+	return &JobRepo{db: db}
+}
+
+// Create 创建任务
+func (r *JobRepo) Create(ctx context.Context, job *model.Job) error {
+	return r.db.WithContext(ctx).Create(job).Error
+}
+
+// Get 获取任务，不存在时返回nil
+func (r *JobRepo) Get(ctx context.Context, id int64) (*model.Job, error) {
+	var job model.Job
+	err := r.db.WithContext(ctx).First(&job, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		logger.Error(ctx, "获取任务失败", err, zap.Int64("id", id))
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Update 更新任务
+func (r *JobRepo) Update(ctx context.Context, id int64, updates map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(&model.Job{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// ListBySource 获取对象上的任务，最新的在前
+func (r *JobRepo) ListBySource(ctx context.Context, jobType string, sourceID int64) ([]model.Job, error) {
+	var jobs []model.Job
+	err := r.db.WithContext(ctx).Where("type = ? AND source_id = ?", jobType, sourceID).
+		Order("id DESC").Find(&jobs).Error
+	if err != nil {
+		logger.Error(ctx, "获取任务列表失败", err, zap.String("type", jobType), zap.Int64("source_id", sourceID))
+		return nil, err
+	}
+	return jobs, nil
+}
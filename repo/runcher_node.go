@@ -0,0 +1,108 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// RuncherNodeRepo runcher节点仓库
+type RuncherNodeRepo struct {
+	db *gorm.DB
+}
+
+// NewRuncherNodeRepo 创建runcher节点仓库
+func NewRuncherNodeRepo(db *gorm.DB) *RuncherNodeRepo {
+	return &RuncherNodeRepo{db: db}
+}
+
+// Create 创建节点
+func (r *RuncherNodeRepo) Create(ctx context.Context, node *model.RuncherNode) error {
+	return r.db.WithContext(ctx).Create(node).Error
+}
+
+// Get 获取节点，不存在时返回nil
+func (r *RuncherNodeRepo) Get(ctx context.Context, id int64) (*model.RuncherNode, error) {
+	var node model.RuncherNode
+	err := r.db.WithContext(ctx).First(&node, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		logger.Error(ctx, "获取runcher节点失败", err, zap.Int64("id", id))
+		return nil, err
+	}
+	return &node, nil
+}
+
+// GetByName 根据名称获取节点，不存在时返回nil
+func (r *RuncherNodeRepo) GetByName(ctx context.Context, name string) (*model.RuncherNode, error) {
+	var node model.RuncherNode
+	err := r.db.WithContext(ctx).Where("name = ?", name).First(&node).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		logger.Error(ctx, "获取runcher节点失败", err, zap.String("name", name))
+		return nil, err
+	}
+	return &node, nil
+}
+
+// List 获取全部节点
+func (r *RuncherNodeRepo) List(ctx context.Context) ([]*model.RuncherNode, error) {
+	var nodes []*model.RuncherNode
+	err := r.db.WithContext(ctx).Order("id").Find(&nodes).Error
+	return nodes, err
+}
+
+// Update 更新节点
+func (r *RuncherNodeRepo) Update(ctx context.Context, id int64, updates map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(&model.RuncherNode{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// Reserve 原子地占用节点的一个容量，节点已满时返回false
+// count为节点上实际的runner数量，占用数小于实际数量时以实际数量为准
+func (r *RuncherNodeRepo) Reserve(ctx context.Context, id int64, count int64) (bool, error) {
+	used := gorm.Expr("GREATEST(runners, ?)", count)
+	res := r.db.WithContext(ctx).Model(&model.RuncherNode{}).
+		Where("id = ? AND (capacity = 0 OR GREATEST(runners, ?) < capacity)", id, count).
+		Update("runners", gorm.Expr("? + 1", used))
+	if res.Error != nil {
+		logger.Error(ctx, "占用runcher节点容量失败", res.Error, zap.Int64("id", id))
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// Release 释放节点的一个容量
+func (r *RuncherNodeRepo) Release(ctx context.Context, id int64) error {
+	return r.db.WithContext(ctx).Model(&model.RuncherNode{}).
+		Where("id = ? AND runners > 0", id).
+		Update("runners", gorm.Expr("runners - 1")).Error
+}
+
+// CountRunners 统计每个节点上的runner数量，key为节点ID
+func (r *RuncherNodeRepo) CountRunners(ctx context.Context) (map[int64]int64, error) {
+	var rows []struct {
+		RuncherID int64
+		Count     int64
+	}
+	err := r.db.WithContext(ctx).Model(&model.Runner{}).
+		Select("runcher_id, count(*) as count").
+		Where("runcher_id IS NOT NULL").
+		Group("runcher_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[int64]int64, len(rows))
+	for _, row := range rows {
+		counts[row.RuncherID] = row.Count
+	}
+	return counts, nil
+}
@@ -71,6 +71,25 @@ func (r *RunnerRepo) Delete(ctx context.Context, id int64) error {
 	return r.db.WithContext(ctx).Delete(&model.Runner{}, id).Error
 }
 
+// DeleteWithChildren 在一个事务中删除Runner以及它的服务树节点和函数
+func (r *RunnerRepo) DeleteWithChildren(ctx context.Context, id int64, deletedBy string) error {
+	logger.Debug(ctx, "开始删除Runner及其服务树和函数", zap.Int64("id", id))
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, m := range []interface{}{&model.RunnerFunc{}, &model.ServiceTree{}} {
+			if err := tx.Model(m).Where("runner_id = ?", id).Update("deleted_by", deletedBy).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("runner_id = ?", id).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&model.Runner{}).Where("id = ?", id).Update("deleted_by", deletedBy).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Runner{}, id).Error
+	})
+}
+
 // List 获取Runner列表
 func (r *RunnerRepo) List(ctx context.Context, page, pageSize int, conditions map[string]interface{}) ([]model.Runner, int64, error) {
 	logger.Debug(ctx, "开始获取Runner列表", zap.Int("page", page), zap.Int("pageSize", pageSize))
@@ -175,6 +194,19 @@ func (r *RunnerRepo) GetVersions(ctx context.Context, runnerID int64) ([]model.R
 	return versions, nil
 }
 
+// ListVersions 获取Runner部署成功的版本列表，按创建顺序排列，版本变更回调失败（已回滚）的版本不包含在内
+func (r *RunnerRepo) ListVersions(ctx context.Context, runnerID int64) ([]model.RunnerVersion, error) {
+	var versions []model.RunnerVersion
+	err := r.db.WithContext(ctx).
+		Where("runner_id = ? AND (callback_status IS NULL OR callback_status <> ?)", runnerID, "fail").
+		Order("id ASC").Find(&versions).Error
+	if err != nil {
+		logger.Error(ctx, "获取Runner版本列表失败", err, zap.Int64("runner_id", runnerID))
+		return nil, err
+	}
+	return versions, nil
+}
+
 // BatchCreate 批量创建Runner
 func (r *RunnerRepo) BatchCreate(ctx context.Context, runners []model.Runner) error {
 	logger.Debug(ctx, "开始批量创建Runner", zap.Int("count", len(runners)))
@@ -192,3 +224,30 @@ func (r *RunnerRepo) SaveVersionWithTx(ctx context.Context, tx *gorm.DB, version
 	logger.Debug(ctx, "使用事务保存Runner版本", zap.Int64("runner_id", version.RunnerID), zap.String("version", version.Version))
 	return tx.Create(version).Error
 }
+
+// UpdateWithTx 使用事务更新Runner
+func (r *RunnerRepo) UpdateWithTx(ctx context.Context, tx *gorm.DB, id int64, runner *model.Runner) error {
+	return tx.WithContext(ctx).Model(&model.Runner{}).Where("id = ?", id).Updates(runner).Error
+}
+
+// UpdateStatusFrom 状态为from时更新为to，返回是否更新成功，用于防止并发修改状态
+func (r *RunnerRepo) UpdateStatusFrom(ctx context.Context, tx *gorm.DB, id int64, from, to int8) (bool, error) {
+	result := tx.WithContext(ctx).Model(&model.Runner{}).Where("id = ? AND status = ?", id, from).Update("status", to)
+	return result.RowsAffected > 0, result.Error
+}
+
+// CreateStatusLogWithTx 使用事务保存状态变更记录
+func (r *RunnerRepo) CreateStatusLogWithTx(ctx context.Context, tx *gorm.DB, log *model.RunnerStatusLog) error {
+	return tx.WithContext(ctx).Create(log).Error
+}
+
+// GetStatusLogs 获取状态变更记录，最新的在前
+func (r *RunnerRepo) GetStatusLogs(ctx context.Context, runnerID int64) ([]model.RunnerStatusLog, error) {
+	var logs []model.RunnerStatusLog
+	err := r.db.WithContext(ctx).Where("runner_id = ?", runnerID).Order("id DESC").Find(&logs).Error
+	if err != nil {
+		logger.Error(ctx, "获取Runner状态变更记录失败", err, zap.Int64("runner_id", runnerID))
+		return nil, err
+	}
+	return logs, nil
+}
@@ -0,0 +1,51 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// RunnerEnvRepo Runner环境仓库
+type RunnerEnvRepo struct {
+	db *gorm.DB
+}
+
+// NewRunnerEnvRepo 创建Runner环境仓库
+func NewRunnerEnvRepo(db *gorm.DB) *RunnerEnvRepo {
+	return &RunnerEnvRepo{db: db}
+}
+
+// Get 获取环境，不存在时返回nil
+func (r *RunnerEnvRepo) Get(ctx context.Context, runnerID int64, name string) (*model.RunnerEnv, error) {
+	var env model.RunnerEnv
+	err := r.db.WithContext(ctx).Where("runner_id = ? AND name = ?", runnerID, name).First(&env).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		logger.Error(ctx, "获取Runner环境失败", err, zap.Int64("runner_id", runnerID), zap.String("env", name))
+		return nil, err
+	}
+	return &env, nil
+}
+
+// List 获取Runner的全部环境
+func (r *RunnerEnvRepo) List(ctx context.Context, runnerID int64) ([]*model.RunnerEnv, error) {
+	var envs []*model.RunnerEnv
+	err := r.db.WithContext(ctx).Where("runner_id = ?", runnerID).Find(&envs).Error
+	if err != nil {
+		logger.Error(ctx, "获取Runner环境列表失败", err, zap.Int64("runner_id", runnerID))
+		return nil, err
+	}
+	return envs, nil
+}
+
+// Save 保存环境，不存在时创建
+func (r *RunnerEnvRepo) Save(ctx context.Context, env *model.RunnerEnv) error {
+	return r.db.WithContext(ctx).Save(env).Error
+}
@@ -36,6 +36,25 @@ func (r *RunnerFuncRepo) GetByFullPath(ctx context.Context, method string, fullP
 	return runnerFunc, nil
 }
 
+// GetByRunnerPath 根据Runner、请求方法和路由路径获取函数，method为空时不限制请求方法
+func (r *RunnerFuncRepo) GetByRunnerPath(ctx context.Context, runnerID int64, method string, path string) (*model.RunnerFunc, error) {
+	logger.Debug(ctx, "根据路由获取函数", zap.Int64("runner_id", runnerID), zap.String("method", method), zap.String("path", path))
+	var runnerFunc model.RunnerFunc
+	query := r.db.WithContext(ctx).Where("runner_id = ? AND path = ?", runnerID, path)
+	if method != "" {
+		query = query.Where("method = ?", strings.ToUpper(method))
+	}
+	err := query.First(&runnerFunc).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		logger.Error(ctx, "根据路由获取函数失败", err, zap.Int64("runner_id", runnerID), zap.String("path", path))
+		return nil, err
+	}
+	return &runnerFunc, nil
+}
+
 // Get 获取函数详情
 func (r *RunnerFuncRepo) Get(ctx context.Context, id int64) (*model.RunnerFunc, error) {
 	logger.Debug(ctx, "开始获取函数", zap.Int64("id", id))
@@ -86,6 +105,53 @@ func (r *RunnerFuncRepo) SetDeletedBy(ctx context.Context, id int64, deletedBy s
 	return r.db.WithContext(ctx).Model(&model.RunnerFunc{}).Where("id = ?", id).Update("deleted_by", deletedBy).Error
 }
 
+// GetDeletedByRunnerPath 获取已删除的函数，同一路由删除过多次时返回最后删除的
+func (r *RunnerFuncRepo) GetDeletedByRunnerPath(ctx context.Context, runnerID int64, method string, path string) (*model.RunnerFunc, error) {
+	var runnerFunc model.RunnerFunc
+	err := r.db.WithContext(ctx).Unscoped().
+		Where("runner_id = ? AND method = ? AND path = ? AND deleted_at IS NOT NULL", runnerID, method, path).
+		Order("id DESC").First(&runnerFunc).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &runnerFunc, nil
+}
+
+// UpdateWithTx 使用事务更新函数
+func (r *RunnerFuncRepo) UpdateWithTx(ctx context.Context, tx *gorm.DB, id int64, updateData *model.RunnerFunc) error {
+	return tx.WithContext(ctx).Model(&model.RunnerFunc{}).Where("id = ?", id).Updates(updateData).Error
+}
+
+// DeleteWithTx 使用事务删除函数以及函数在服务树上的节点
+func (r *RunnerFuncRepo) DeleteWithTx(ctx context.Context, tx *gorm.DB, id int64, deletedBy string) error {
+	tx = tx.WithContext(ctx)
+	if err := tx.Model(&model.RunnerFunc{}).Where("id = ?", id).Update("deleted_by", deletedBy).Error; err != nil {
+		return err
+	}
+	if err := tx.Delete(&model.RunnerFunc{}, id).Error; err != nil {
+		return err
+	}
+	node := tx.Model(&model.ServiceTree{}).Where("ref_id = ? AND type = ?", id, model.ServiceTreeTypeFunction)
+	if err := node.Update("deleted_by", deletedBy).Error; err != nil {
+		return err
+	}
+	return tx.Where("ref_id = ? AND type = ?", id, model.ServiceTreeTypeFunction).Delete(&model.ServiceTree{}).Error
+}
+
+// RestoreWithTx 使用事务恢复已删除的函数以及函数在服务树上的节点
+func (r *RunnerFuncRepo) RestoreWithTx(ctx context.Context, tx *gorm.DB, id int64, operator string) error {
+	tx = tx.WithContext(ctx)
+	restore := map[string]interface{}{"deleted_at": nil, "deleted_by": "", "updated_by": operator}
+	if err := tx.Unscoped().Model(&model.RunnerFunc{}).Where("id = ?", id).Updates(restore).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Model(&model.ServiceTree{}).
+		Where("ref_id = ? AND type = ?", id, model.ServiceTreeTypeFunction).Updates(restore).Error
+}
+
 // List 获取函数列表
 func (r *RunnerFuncRepo) List(ctx context.Context, page, pageSize int, conditions map[string]interface{}) ([]model.RunnerFunc, int64, error) {
 	logger.Debug(ctx, "开始获取函数列表", zap.Int("page", page), zap.Int("pageSize", pageSize))
@@ -126,6 +192,26 @@ func (r *RunnerFuncRepo) GetByRunner(ctx context.Context, runnerID int64) ([]mod
 	return funcs, err
 }
 
+// GetForkedByRunner 获取Runner下从其他函数fork来的函数，包括已删除的，用于判断fork是否删除了上游的函数
+func (r *RunnerFuncRepo) GetForkedByRunner(ctx context.Context, runnerID int64) ([]model.RunnerFunc, error) {
+	var funcs []model.RunnerFunc
+	err := r.db.WithContext(ctx).Unscoped().Where("runner_id = ? AND fork_from_id IS NOT NULL", runnerID).Find(&funcs).Error
+	if err != nil {
+		logger.Error(ctx, "获取fork的函数失败", err, zap.Int64("runner_id", runnerID))
+	}
+	return funcs, err
+}
+
+// GetDeletedByRunner 获取Runner下已删除的函数
+func (r *RunnerFuncRepo) GetDeletedByRunner(ctx context.Context, runnerID int64) ([]model.RunnerFunc, error) {
+	var funcs []model.RunnerFunc
+	err := r.db.WithContext(ctx).Unscoped().Where("runner_id = ? AND deleted_at IS NOT NULL", runnerID).Find(&funcs).Error
+	if err != nil {
+		logger.Error(ctx, "获取已删除的函数失败", err, zap.Int64("runner_id", runnerID))
+	}
+	return funcs, err
+}
+
 // CheckRunnerExists 检查Runner是否存在
 func (r *RunnerFuncRepo) CheckRunnerExists(ctx context.Context, runnerID int64) (bool, error) {
 	var count int64
@@ -180,12 +266,19 @@ func (r *RunnerFuncRepo) Fork(ctx context.Context, sourceID int64, targetTreeID
 		return nil, err
 	}
 
-	// 创建版本记录
+	// 创建版本记录，复制源函数最新的源码
 	version := &model.FuncVersion{
 		FuncID:  newFunc.ID,
 		Version: "1.0.0",
 		Comment: "从 " + sourceFunc.Name + " Fork",
 	}
+	source, err := r.GetSourceVersion(ctx, sourceFunc.ID, "")
+	if err != nil {
+		logger.Warn(ctx, "获取源函数源码失败", zap.Error(err), zap.Int64("func_id", sourceFunc.ID))
+	} else if source != nil {
+		version.Source, version.SourceSize, version.Hash = source.Source, source.SourceSize, source.Hash
+		newFunc.Code, _ = source.GetSource()
+	}
 
 	// 保存版本记录
 	if err := r.SaveVersion(ctx, version); err != nil {
@@ -244,7 +337,7 @@ func (r *RunnerFuncRepo) SaveVersion(ctx context.Context, version *model.FuncVer
 func (r *RunnerFuncRepo) GetVersions(ctx context.Context, funcID int64) ([]model.FuncVersion, error) {
 	logger.Debug(ctx, "获取函数版本列表", zap.Int64("func_id", funcID))
 	var versions []model.FuncVersion
-	err := r.db.WithContext(ctx).Where("func_id = ?", funcID).Order("created_at DESC").Find(&versions).Error
+	err := r.db.WithContext(ctx).Omit("source").Where("func_id = ?", funcID).Order("created_at DESC").Find(&versions).Error
 	if err != nil {
 		logger.Error(ctx, "获取函数版本列表失败", err, zap.Int64("func_id", funcID))
 		return nil, err
@@ -252,6 +345,29 @@ func (r *RunnerFuncRepo) GetVersions(ctx context.Context, funcID int64) ([]model
 	return versions, nil
 }
 
+// GetSourceVersions 获取函数所有保存了源码的版本，按创建顺序排列
+func (r *RunnerFuncRepo) GetSourceVersions(ctx context.Context, funcID int64) ([]model.FuncVersion, error) {
+	var versions []model.FuncVersion
+	err := r.db.WithContext(ctx).Where("func_id = ? AND source_size > 0", funcID).Order("id ASC").Find(&versions).Error
+	return versions, err
+}
+
+// GetSourceVersion 获取保存了源码的函数版本，version为空时获取最新的，不存在时返回nil
+func (r *RunnerFuncRepo) GetSourceVersion(ctx context.Context, funcID int64, version string) (*model.FuncVersion, error) {
+	query := r.db.WithContext(ctx).Where("func_id = ? AND source_size > 0", funcID)
+	if version != "" {
+		query = query.Where("version = ?", version)
+	}
+	var v model.FuncVersion
+	if err := query.Order("id DESC").First(&v).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &v, nil
+}
+
 // GetUserRecentFuncRecords 获取用户最近执行过的函数记录（去重）
 func (r *RunnerFuncRepo) GetUserRecentFuncRecords(ctx context.Context, user string, page, pageSize int) ([]model.FuncRunRecord, int64, error) {
 	logger.Debug(ctx, "开始获取用户最近执行函数记录", zap.String("user", user), zap.Int("page", page), zap.Int("pageSize", pageSize))
@@ -352,3 +468,113 @@ func (r *RunnerFuncRepo) GetUserFuncRunCount(ctx context.Context, user string, f
 
 	return count, nil
 }
+
+// CountContractRecords 统计函数做过契约校验的执行次数和违规次数
+func (r *RunnerFuncRepo) CountContractRecords(ctx context.Context, funcID int64) (checked int64, violated int64, err error) {
+	logger.Debug(ctx, "开始统计函数契约校验记录", zap.Int64("func_id", funcID))
+
+	err = r.db.WithContext(ctx).
+		Model(&model.FuncRunRecord{}).
+		Where("func_id = ? AND contract_status <> ''", funcID).
+		Count(&checked).Error
+	if err != nil {
+		logger.Error(ctx, "统计函数契约校验次数失败", err, zap.Int64("func_id", funcID))
+		return 0, 0, err
+	}
+
+	err = r.db.WithContext(ctx).
+		Model(&model.FuncRunRecord{}).
+		Where("func_id = ? AND contract_status = ?", funcID, model.ContractStatusViolated).
+		Count(&violated).Error
+	if err != nil {
+		logger.Error(ctx, "统计函数契约违规次数失败", err, zap.Int64("func_id", funcID))
+		return 0, 0, err
+	}
+	return checked, violated, nil
+}
+
+// GetRecentContractViolations 获取函数最近的契约违规执行记录
+func (r *RunnerFuncRepo) GetRecentContractViolations(ctx context.Context, funcID int64, limit int) ([]model.FuncRunRecord, error) {
+	logger.Debug(ctx, "开始获取函数最近契约违规记录", zap.Int64("func_id", funcID), zap.Int("limit", limit))
+	var records []model.FuncRunRecord
+	err := r.db.WithContext(ctx).
+		Select("id, created_at, func_id, status, contract_status, contract_violations").
+		Where("func_id = ? AND contract_status = ?", funcID, model.ContractStatusViolated).
+		Order("id DESC").
+		Limit(limit).
+		Find(&records).Error
+	if err != nil {
+		logger.Error(ctx, "获取函数最近契约违规记录失败", err, zap.Int64("func_id", funcID))
+		return nil, err
+	}
+	return records, nil
+}
+
+// CreateRunRecord 保存函数执行记录
+func (r *RunnerFuncRepo) CreateRunRecord(ctx context.Context, record *model.FuncRunRecord) error {
+	logger.Debug(ctx, "保存函数执行记录", zap.Int64("func_id", record.FuncId), zap.String("status", record.Status))
+	return r.db.WithContext(ctx).Create(record).Error
+}
+
+// SaveRunRecord 保存函数执行记录，已经创建过的记录整体更新
+func (r *RunnerFuncRepo) SaveRunRecord(ctx context.Context, record *model.FuncRunRecord) error {
+	logger.Debug(ctx, "保存函数执行记录", zap.Int64("id", record.ID), zap.Int64("func_id", record.FuncId), zap.String("status", record.Status))
+	return r.db.WithContext(ctx).Save(record).Error
+}
+
+// MarkRunRecordCancelled 把执行中的记录标记为已取消，记录不是执行中时返回false
+func (r *RunnerFuncRepo) MarkRunRecordCancelled(ctx context.Context, runID string, reason string) (bool, error) {
+	logger.Debug(ctx, "标记函数执行记录为已取消", zap.String("run_id", runID))
+	result := r.db.WithContext(ctx).Model(&model.FuncRunRecord{}).
+		Where("run_id = ? AND status = ?", runID, "running").
+		Updates(map[string]interface{}{"status": "cancelled", "message": reason})
+	if result.Error != nil {
+		logger.Error(ctx, "标记函数执行记录为已取消失败", result.Error, zap.String("run_id", runID))
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetRunRecordByRunID 根据执行ID获取函数执行记录
+func (r *RunnerFuncRepo) GetRunRecordByRunID(ctx context.Context, runID string) (*model.FuncRunRecord, error) {
+	logger.Debug(ctx, "开始根据执行ID获取函数执行记录", zap.String("run_id", runID))
+	var record model.FuncRunRecord
+	if err := r.db.WithContext(ctx).Where("run_id = ?", runID).Order("id DESC").First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		logger.Error(ctx, "根据执行ID获取函数执行记录失败", err, zap.String("run_id", runID))
+		return nil, err
+	}
+	return &record, nil
+}
+
+// GetRunRecord 获取函数执行记录
+func (r *RunnerFuncRepo) GetRunRecord(ctx context.Context, recordID int64) (*model.FuncRunRecord, error) {
+	logger.Debug(ctx, "开始获取函数执行记录", zap.Int64("record_id", recordID))
+	var record model.FuncRunRecord
+	if err := r.db.WithContext(ctx).First(&record, recordID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		logger.Error(ctx, "获取函数执行记录失败", err, zap.Int64("record_id", recordID))
+		return nil, err
+	}
+	return &record, nil
+}
+
+// GetRunRecordsByTraceID 获取同一条链路上的函数执行记录
+func (r *RunnerFuncRepo) GetRunRecordsByTraceID(ctx context.Context, traceID string, limit int) ([]model.FuncRunRecord, error) {
+	logger.Debug(ctx, "开始根据链路ID获取函数执行记录", zap.String("trace_id", traceID))
+	var records []model.FuncRunRecord
+	err := r.db.WithContext(ctx).
+		Where("trace_id = ?", traceID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&records).Error
+	if err != nil {
+		logger.Error(ctx, "根据链路ID获取函数执行记录失败", err, zap.String("trace_id", traceID))
+		return nil, err
+	}
+	return records, nil
+}
@@ -143,6 +143,33 @@ func (r *ServiceTreeRepo) Children(ctx context.Context, parentId int64) ([]*mode
 	return trees, nil
 }
 
+// GetPackagesByRunner 获取Runner下除根目录以外的所有package，按层级排序，父目录在前
+func (r *ServiceTreeRepo) GetPackagesByRunner(ctx context.Context, runnerID int64) ([]*model.ServiceTree, error) {
+	var trees []*model.ServiceTree
+	err := r.db.WithContext(ctx).
+		Where("runner_id = ? AND type = ? AND parent_id <> 0", runnerID, model.ServiceTreeTypePackage).
+		Order("level, id").
+		Find(&trees).Error
+	if err != nil {
+		logger.Error(ctx, "获取Runner下的package失败", err, zap.Int64("runner_id", runnerID))
+		return nil, err
+	}
+	return trees, nil
+}
+
+// GetFunctionNodesByRunner 获取Runner下的所有函数节点
+func (r *ServiceTreeRepo) GetFunctionNodesByRunner(ctx context.Context, runnerID int64) ([]*model.ServiceTree, error) {
+	var trees []*model.ServiceTree
+	err := r.db.WithContext(ctx).
+		Where("runner_id = ? AND type = ?", runnerID, model.ServiceTreeTypeFunction).
+		Find(&trees).Error
+	if err != nil {
+		logger.Error(ctx, "获取Runner下的函数节点失败", err, zap.Int64("runner_id", runnerID))
+		return nil, err
+	}
+	return trees, nil
+}
+
 // Update 更新ServiceTree
 func (r *ServiceTreeRepo) Update(ctx context.Context, id int64, tree *model.ServiceTree) error {
 	logger.Debug(ctx, "开始更新ServiceTree", zap.Any("id", id))
@@ -6,7 +6,9 @@ import (
 	"github.com/yunhanshu-net/function-server/middleware"
 	"github.com/yunhanshu-net/function-server/pkg/config"
 	"github.com/yunhanshu-net/function-server/pkg/db"
+	"github.com/yunhanshu-net/function-server/pkg/metrics"
 	pkgmiddleware "github.com/yunhanshu-net/function-server/pkg/middleware"
+	"github.com/yunhanshu-net/function-server/pkg/tracing"
 )
 
 // Init 初始化路由
@@ -22,6 +24,14 @@ func Init() *gin.Engine {
 	//r.Use(pkgmiddleware.Logger())
 	r.Use(pkgmiddleware.Cors())
 	r.Use(middleware.WithTraceID()) // 添加跟踪ID中间件
+	r.Use(tracing.Middleware())     // OpenTelemetry HTTP span
+	r.Use(metrics.Middleware())     // Prometheus HTTP指标
+
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	healthAPI := v1.NewHealthAPI(db.GetDB())
+	r.GET("/healthz", healthAPI.Healthz) // 存活检查
+	r.GET("/readyz", healthAPI.Readyz)   // 就绪检查
 
 	// API版本v1
 	apiV1 := r.Group("/api/v1")
@@ -32,30 +42,63 @@ func Init() *gin.Engine {
 	{
 		functionApi := v1.NewFunctions(db.GetDB())
 		functionV1.Any("/run/:user/:runner/*router", functionApi.Run)
-		functionV1.POST("/callback/:user/:runner/*router", functionApi.Callback)
+		functionV1.POST("/callback/:user/:runner/*router", middleware.RequireRuncher(), functionApi.Callback)
+		functionV1.POST("/replay/:record_id", functionApi.Replay) // 重放函数执行记录
+		functionV1.GET("/open/:id", functionApi.Open)             // 打开函数页面，AutoRun函数同时返回执行结果
+		functionV1.GET("/runs", functionApi.Runs)                 // 获取执行中的函数
+		functionV1.POST("/cancel/:run_id", functionApi.Cancel)    // 取消执行中的函数
+	}
+	{
+		// runcher节点
+		runcherNodeAPI := v1.NewRuncherNodeAPI(db.GetDB())
+		runcherNode := apiV1.Group("/runcher")
+		{
+			runcherNode.POST("/heartbeat", runcherNodeAPI.Heartbeat)         // 节点心跳，不能使用NATS订阅时由节点调用
+			runcherNode.GET("/node", runcherNodeAPI.List)                    // 获取节点列表
+			runcherNode.PUT("/node/:id/status", runcherNodeAPI.UpdateStatus) // 修改节点状态
+		}
 	}
 	{
 		// Runner 相关路由
 		runnerAPI := v1.NewRunnerAPI(db.GetDB())
+		runnerEnvAPI := v1.NewRunnerEnvAPI(db.GetDB())
 		runner := apiV1.Group("/runner")
 		{
-			runner.POST("", runnerAPI.Create)                       // 创建Runner
-			runner.GET("", runnerAPI.List)                          // 获取Runner列表
-			runner.GET("/:id", runnerAPI.Get)                       // 获取Runner详情
-			runner.PUT("/:id", runnerAPI.Update)                    // 更新Runner
-			runner.DELETE("/:id", runnerAPI.Delete)                 // 删除Runner
-			runner.POST("/:id/fork", runnerAPI.Fork)                // Fork Runner
-			runner.GET("/:id/version", runnerAPI.Version)           // 获取Runner版本历史
-			runner.GET("/by-name/:user/:name", runnerAPI.GetByName) // 通过用户名和名称获取Runner
+			runner.POST("", middleware.RequireRuncher(), runnerAPI.Create)                         // 创建Runner
+			runner.GET("", runnerAPI.List)                                                         // 获取Runner列表
+			runner.GET("/:id", runnerAPI.Get)                                                      // 获取Runner详情
+			runner.PUT("/:id", runnerAPI.Update)                                                   // 更新Runner
+			runner.DELETE("/:id", middleware.RequireRuncher(), runnerAPI.Delete)                   // 删除Runner
+			runner.POST("/:id/fork", middleware.RequireRuncher(), runnerAPI.Fork)                  // Fork Runner，后台复制项目、服务树和函数
+			runner.GET("/:id/fork/jobs", runnerAPI.ForkJobs)                                       // 获取Runner的fork任务
+			runner.GET("/:id/upstream", runnerAPI.UpstreamStatus)                                  // 获取上游自上次同步以来的变化
+			runner.POST("/:id/upstream/sync", middleware.RequireRuncher(), runnerAPI.SyncUpstream) // 同步上游的变化
+			runner.POST("/:id/migrate", middleware.RequireRuncher(), runnerAPI.Migrate)            // 迁移Runner到另一个runcher节点
+			runner.POST("/:id/rollback", middleware.RequireRuncher(), runnerAPI.Rollback)          // 回滚Runner到之前的版本
+			runner.POST("/:id/start", middleware.RequireRuncher(), runnerAPI.Start)                // 启动Runner
+			runner.POST("/:id/stop", middleware.RequireRuncher(), runnerAPI.Stop)                  // 停止Runner
+			runner.POST("/:id/suspend", middleware.RequireRuncher(), runnerAPI.Suspend)            // 暂停Runner
+			runner.POST("/:id/archive", middleware.RequireRuncher(), runnerAPI.Archive)            // 归档Runner
+			runner.GET("/:id/status-history", runnerAPI.StatusHistory)                             // 获取Runner状态变更历史
+			runner.GET("/:id/version", runnerAPI.Version)                                          // 获取Runner版本历史
+			runner.GET("/:id/version/diff", runnerAPI.VersionDiff)                                 // 比较Runner两个版本的函数差异
+			runner.GET("/:id/export", runnerAPI.Export)                                            // 导出Runner的目录和函数源码
+			runner.GET("/by-name/:user/:name", runnerAPI.GetByName)                                // 通过用户名和名称获取Runner
+
+			// 发布环境
+			runner.GET("/:id/env", runnerEnvAPI.List)                  // 获取Runner的dev、staging、prod环境
+			runner.POST("/:id/env/promote", runnerEnvAPI.Promote)      // 把一个环境的版本发布到后面的环境
+			runner.PUT("/:id/env/:env", runnerEnvAPI.SetVersion)       // 把环境指向runner的某个版本
+			runner.PUT("/:id/env/:env/config", runnerEnvAPI.SetConfig) // 设置环境变量
 		}
 
 		// ServiceTree 相关路由
 		serviceTreeAPI := v1.NewServiceTreeAPI(db.GetDB())
 		serviceTree := apiV1.Group("/service-tree")
 		{
-			serviceTree.POST("", serviceTreeAPI.Create) // 创建目录
-			serviceTree.GET("", serviceTreeAPI.List)    // 获取目录列表
-			serviceTree.GET("/:id", serviceTreeAPI.Get) // 获取目录详情
+			serviceTree.POST("", middleware.RequireRuncher(), serviceTreeAPI.Create) // 创建目录
+			serviceTree.GET("", serviceTreeAPI.List)                                 // 获取目录列表
+			serviceTree.GET("/:id", serviceTreeAPI.Get)                              // 获取目录详情
 			//serviceTree.GET("/children/:id", serviceTreeAPI.Children)           // 获取目录详情
 			serviceTree.PUT("/:id", serviceTreeAPI.Update)                                // 更新目录
 			serviceTree.DELETE("/:id", serviceTreeAPI.Delete)                             // 删除目录
@@ -77,28 +120,47 @@ func Init() *gin.Engine {
 			serviceTreePath.GET("/by-id", serviceTreePathAPI.GetByID)     // 根据ID路径获取服务树
 		}
 
+		// 后台任务
+		jobAPI := v1.NewJobAPI(db.GetDB())
+		apiV1.GET("/job/:id", jobAPI.Get) // 获取任务状态和进度
+
 		// RunnerFunc 相关路由
 		runnerFuncAPI := v1.NewRunnerFuncAPI(db.GetDB())
 		runnerFunc := apiV1.Group("/runner-func")
 		{
-			runnerFunc.POST("", runnerFuncAPI.Create)                            // 创建函数
-			runnerFunc.GET("", runnerFuncAPI.List)                               // 获取函数列表
-			runnerFunc.GET("/:id", runnerFuncAPI.Get)                            // 获取函数详情
-			runnerFunc.GET("/:id/versions", runnerFuncAPI.Versions)              // 获取函数详情
-			runnerFunc.GET("/tree/:tree_id", runnerFuncAPI.GetByTreeId)          // 获取函数详情
-			runnerFunc.GET("/full-path/*full_path", runnerFuncAPI.GetByFullPath) // 获取函数详情
-
-			runnerFunc.PUT("/:id", runnerFuncAPI.Update)                    // 更新函数
-			runnerFunc.DELETE("/:id", runnerFuncAPI.Delete)                 // 删除函数
-			runnerFunc.DELETE("/delete_by_ids", runnerFuncAPI.DeleteByIds)  // 批量删除函数
-			runnerFunc.POST("/:id/fork", runnerFuncAPI.Fork)                // Fork函数
-			runnerFunc.GET("/runner/:runner_id", runnerFuncAPI.GetByRunner) // 获取Runner下的函数列表
+			runnerFunc.POST("", middleware.RequireRuncher(), runnerFuncAPI.Create) // 创建函数
+			runnerFunc.GET("", runnerFuncAPI.List)                                 // 获取函数列表
+			runnerFunc.GET("/:id", runnerFuncAPI.Get)                              // 获取函数详情
+			runnerFunc.GET("/:id/versions", runnerFuncAPI.Versions)                // 获取函数详情
+			runnerFunc.GET("/:id/source", runnerFuncAPI.Source)                    // 获取函数当前或历史版本的源码
+			runnerFunc.GET("/:id/contract", runnerFuncAPI.ContractStats)           // 获取函数返回值契约违规统计
+			runnerFunc.GET("/:id/callbacks", runnerFuncAPI.Callbacks)              // 获取函数支持的回调列表
+			runnerFunc.GET("/tree/:tree_id", runnerFuncAPI.GetByTreeId)            // 获取函数详情
+			runnerFunc.GET("/full-path/*full_path", runnerFuncAPI.GetByFullPath)   // 获取函数详情
+
+			runnerFunc.PUT("/:id/code", middleware.RequireRuncher(), runnerFuncAPI.UpdateCode)          // 修改函数代码并重新部署
+			runnerFunc.PUT("/:id", runnerFuncAPI.Update)                                                // 更新函数
+			runnerFunc.DELETE("/:id", middleware.RequireRuncher(), runnerFuncAPI.Delete)                // 删除函数
+			runnerFunc.DELETE("/delete_by_ids", middleware.RequireRuncher(), runnerFuncAPI.DeleteByIds) // 批量删除函数
+			runnerFunc.POST("/:id/fork", runnerFuncAPI.Fork)                                            // Fork函数
+			runnerFunc.GET("/runner/:runner_id", runnerFuncAPI.GetByRunner)                             // 获取Runner下的函数列表
 
 			runnerFunc.GET("/record/:func_id", runnerFuncAPI.GetFuncRecord)
-			runnerFunc.GET("/recent-records", runnerFuncAPI.GetUserRecentFuncRecords) // 获取用户最近执行函数记录（去重）
-			runnerFunc.POST("/gen", runnerFuncAPI.FunctionGen)                        // 获取用户最近执行函数记录（去重）
-			runnerFunc.GET("/generate/list", runnerFuncAPI.GeneratingList)            // 获取用户最近执行函数记录（去重）
-			runnerFunc.GET("/generating/count", runnerFuncAPI.GeneratingCount)        // 获取用户最近执行函数记录（去重）
+			runnerFunc.GET("/trace-records/:trace_id", runnerFuncAPI.GetTraceRecords)       // 根据链路ID获取函数执行记录
+			runnerFunc.GET("/recent-records", runnerFuncAPI.GetUserRecentFuncRecords)       // 获取用户最近执行函数记录（去重）
+			runnerFunc.POST("/gen", middleware.RequireRuncher(), runnerFuncAPI.FunctionGen) // 获取用户最近执行函数记录（去重）
+			runnerFunc.GET("/generate/list", runnerFuncAPI.GeneratingList)                  // 获取用户最近执行函数记录（去重）
+			runnerFunc.GET("/generating/count", runnerFuncAPI.GeneratingCount)              // 获取用户最近执行函数记录（去重）
+
+			// AI生成函数审核
+			genReviewAPI := v1.NewFunctionGenReviewAPI(db.GetDB())
+			runnerFunc.PUT("/gen/:id/reviewer", genReviewAPI.Assign)                 // 指定审核人
+			runnerFunc.POST("/gen/:id/approve", genReviewAPI.Approve)                // 审核通过并启用函数
+			runnerFunc.POST("/gen/:id/reject", genReviewAPI.Reject)                  // 拒绝
+			runnerFunc.POST("/gen/:id/request-changes", genReviewAPI.RequestChanges) // 要求修改
+			runnerFunc.POST("/gen/:id/submit", genReviewAPI.Submit)                  // 修改后重新提交审核
+			runnerFunc.POST("/gen/:id/comment", genReviewAPI.Comment)                // 添加评论
+			runnerFunc.GET("/gen/:id/reviews", genReviewAPI.Reviews)                 // 获取审核记录和评论
 		}
 	}
 
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/yunhanshu-net/function-server/pkg/config"
+	"github.com/yunhanshu-net/function-server/pkg/contract"
+	"github.com/yunhanshu-net/function-server/pkg/dto"
+	"github.com/yunhanshu-net/function-server/pkg/dto/runcher"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// autoRunCacheItem 自动运行结果的缓存
+type autoRunCacheItem struct {
+	result   interface{}
+	runAt    time.Time
+	expireAt time.Time
+}
+
+// autoRunCache 自动运行结果按用户、函数和版本缓存，进程内有效
+var autoRunCache = struct {
+	sync.Mutex
+	items map[string]*autoRunCacheItem
+}{items: make(map[string]*autoRunCacheItem)}
+
+func autoRunCacheKey(user string, funcID int64, version string) string {
+	return fmt.Sprintf("%s:%d:%s", user, funcID, version)
+}
+
+func getAutoRunCache(key string) *autoRunCacheItem {
+	autoRunCache.Lock()
+	defer autoRunCache.Unlock()
+	item, ok := autoRunCache.items[key]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(item.expireAt) {
+		delete(autoRunCache.items, key)
+		return nil
+	}
+	return item
+}
+
+func setAutoRunCache(key string, item *autoRunCacheItem) {
+	autoRunCache.Lock()
+	defer autoRunCache.Unlock()
+	// 顺便清理过期的缓存，避免一直增长
+	now := time.Now()
+	for k, v := range autoRunCache.items {
+		if now.After(v.expireAt) {
+			delete(autoRunCache.items, k)
+		}
+	}
+	autoRunCache.items[key] = item
+}
+
+// Open 打开函数页面，返回函数信息，AutoRun函数会使用默认输入执行一次并返回结果
+// refresh 为true时忽略缓存重新执行，自动运行失败不会返回错误，失败原因放在返回结果中
+func (s *FuncRun) Open(ctx context.Context, funcID int64, user string, refresh bool) (*dto.OpenFuncResp, error) {
+	runnerFunc, err := s.runnerFuncRepo.Get(ctx, funcID)
+	if err != nil {
+		return nil, fmt.Errorf("获取函数失败: %w", err)
+	}
+	if runnerFunc == nil {
+		return nil, errors.New("函数不存在")
+	}
+	openResp := &dto.OpenFuncResp{Func: runnerFunc, AutoRun: runnerFunc.AutoRun}
+	if !runnerFunc.AutoRun {
+		return openResp, nil
+	}
+
+	runner, err := s.runnerRepo.Get(ctx, runnerFunc.RunnerID)
+	if err != nil {
+		return nil, fmt.Errorf("获取Runner失败: %w", err)
+	}
+	if runner == nil {
+		return nil, errors.New("runner不存在")
+	}
+
+	ttl := time.Duration(config.Get().RunConfig.AutoRunCacheTTL) * time.Second
+	key := autoRunCacheKey(user, runnerFunc.ID, runner.Version)
+	if !refresh && ttl > 0 {
+		if item := getAutoRunCache(key); item != nil {
+			openResp.Result = item.result
+			openResp.RunAt = &item.runAt
+			openResp.Cached = true
+			return openResp, nil
+		}
+	}
+
+	inputs, err := contract.Defaults(runnerFunc.Request)
+	if err != nil {
+		logger.Warn(ctx, "解析函数默认输入失败，使用空输入自动运行", zap.Error(err), zap.Int64("func_id", runnerFunc.ID))
+		inputs = map[string]interface{}{}
+	}
+	openResp.Inputs = inputs
+	body, _ := json.Marshal(inputs)
+
+	req := &runcher.RunFunctionReq{
+		User:   runner.User,
+		Runner: runner.Name,
+		Method: runnerFunc.Method,
+		Router: FuncRouter(runner, runnerFunc),
+	}
+	if req.Method == http.MethodGet {
+		req.RawQuery = requestToQuery(body)
+	} else {
+		req.Body = string(body)
+	}
+
+	result, err := s.Execute(ctx, &RunOptions{
+		Runner:   runner,
+		Req:      req,
+		FuncID:   runnerFunc.ID,
+		Operator: user,
+	})
+	if result != nil {
+		if saveErr := s.runnerFunc.SaveRunRecord(ctx, result.Func, result.Record); saveErr != nil {
+			logger.Warn(ctx, "保存自动运行执行记录失败", zap.Error(saveErr), zap.Int64("func_id", runnerFunc.ID))
+		}
+	}
+	if err != nil {
+		logger.Warn(ctx, "自动运行函数失败", zap.Error(err), zap.Int64("func_id", runnerFunc.ID))
+		openResp.Error = err.Error()
+		return openResp, nil
+	}
+
+	runAt := time.Now()
+	openResp.Result = result.Resp
+	openResp.RunAt = &runAt
+	if ttl > 0 {
+		setAutoRunCache(key, &autoRunCacheItem{result: result.Resp, runAt: runAt, expireAt: runAt.Add(ttl)})
+	}
+	return openResp, nil
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/pkg/config"
+	"github.com/yunhanshu-net/function-server/pkg/dto"
+	"github.com/yunhanshu-net/function-server/pkg/dto/runcher"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// callbackRouter runner中处理回调的路由
+const callbackRouter = "_callback"
+
+// defaultCallbackTimeout 配置中没有设置回调超时时间时使用
+const defaultCallbackTimeout = 10 * time.Second
+
+// ErrCallbackInvalid 回调参数校验失败
+var ErrCallbackInvalid = errors.New("回调参数无效")
+
+// CallbackSpec 已知回调类型的说明
+type CallbackSpec struct {
+	Type    string
+	Desc    string
+	Timeout time.Duration // 为0时使用配置中的默认超时时间
+}
+
+// callbackRegistry 已知的回调类型，函数声明了但不在这里的回调同样允许调用，使用默认超时时间
+var callbackRegistry = map[string]CallbackSpec{
+	"OnPageLoad":        {Type: "OnPageLoad", Desc: "页面加载时回调，用于初始化表单默认值"},
+	"OnInputFuzzy":      {Type: "OnInputFuzzy", Desc: "输入框模糊搜索", Timeout: 5 * time.Second},
+	"OnInputValidate":   {Type: "OnInputValidate", Desc: "输入框校验", Timeout: 5 * time.Second},
+	"OnTableSearch":     {Type: "OnTableSearch", Desc: "表格搜索"},
+	"OnTableAddRow":     {Type: "OnTableAddRow", Desc: "表格新增行"},
+	"OnTableUpdateRow":  {Type: "OnTableUpdateRow", Desc: "表格更新行"},
+	"OnTableDeleteRows": {Type: "OnTableDeleteRows", Desc: "表格删除行", Timeout: 30 * time.Second},
+}
+
+// CallbackTimeout 获取回调的超时时间，优先级：配置中按类型设置 > 注册表 > 配置中的默认值
+func CallbackTimeout(callbackType string) time.Duration {
+	runConfig := config.Get().RunConfig
+	if seconds, ok := runConfig.CallbackTimeouts[callbackType]; ok && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if spec, ok := callbackRegistry[callbackType]; ok && spec.Timeout > 0 {
+		return spec.Timeout
+	}
+	if runConfig.CallbackTimeout > 0 {
+		return time.Duration(runConfig.CallbackTimeout) * time.Second
+	}
+	return defaultCallbackTimeout
+}
+
+// FuncCallbacks 解析函数声明的回调列表
+func FuncCallbacks(runnerFunc *model.RunnerFunc) []string {
+	var callbacks []string
+	for _, callback := range strings.Split(runnerFunc.Callbacks, ",") {
+		if callback = strings.TrimSpace(callback); callback != "" {
+			callbacks = append(callbacks, callback)
+		}
+	}
+	return callbacks
+}
+
+// CallbackPayload 回调请求体中用于校验的字段，其余字段原样转发给runner
+type CallbackPayload struct {
+	Type   string `json:"type"`
+	Method string `json:"method"`
+	Router string `json:"router"`
+}
+
+// ParseCallbackPayload 校验回调请求体必须是json对象，并解析其中的回调类型等字段
+func ParseCallbackPayload(body []byte) (*CallbackPayload, error) {
+	payload := &CallbackPayload{}
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return payload, nil
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return nil, fmt.Errorf("%w: 回调请求体必须是json对象: %s", ErrCallbackInvalid, err)
+	}
+	if err := json.Unmarshal(body, payload); err != nil {
+		return nil, fmt.Errorf("%w: 回调请求体字段类型错误: %s", ErrCallbackInvalid, err)
+	}
+	return payload, nil
+}
+
+// CallbackOptions 执行回调的参数
+type CallbackOptions struct {
+	Runner   *model.Runner
+	Router   string // 触发回调的函数路由
+	Method   string // 触发回调的函数请求方法，为空时使用请求体中的method
+	Type     string // 回调类型，为空时使用请求体中的type
+	Body     []byte
+	Operator string
+}
+
+// Callback 校验回调是否在函数声明的回调列表中，按回调类型的超时时间执行回调
+func (s *FuncRun) Callback(ctx context.Context, opts *CallbackOptions) (*RunResult, error) {
+	payload, err := ParseCallbackPayload(opts.Body)
+	if err != nil {
+		return nil, err
+	}
+	callbackType := opts.Type
+	if callbackType == "" {
+		callbackType = payload.Type
+	}
+	if callbackType == "" {
+		return nil, fmt.Errorf("%w: 缺少回调类型", ErrCallbackInvalid)
+	}
+	if isSysCallback(callbackType) {
+		return nil, fmt.Errorf("%w: 系统回调 %s 只能由服务端调用", ErrCallbackInvalid, callbackType)
+	}
+	if payload.Type != "" && payload.Type != callbackType {
+		return nil, fmt.Errorf("%w: 回调类型不一致: %s, %s", ErrCallbackInvalid, callbackType, payload.Type)
+	}
+	if payload.Router != "" && strings.Trim(payload.Router, "/") != strings.Trim(opts.Router, "/") {
+		return nil, fmt.Errorf("%w: 回调路由不一致: %s, %s", ErrCallbackInvalid, opts.Router, payload.Router)
+	}
+	method := opts.Method
+	if method == "" {
+		method = payload.Method
+	}
+
+	fn, err := s.runnerFunc.GetByRunnerRouter(ctx, opts.Runner, method, opts.Router)
+	if err != nil {
+		return nil, fmt.Errorf("获取函数失败: %w", err)
+	}
+	if fn == nil {
+		return nil, fmt.Errorf("%w: 函数不存在: %s", ErrCallbackInvalid, opts.Router)
+	}
+	declared := false
+	for _, callback := range FuncCallbacks(fn) {
+		if callback == callbackType {
+			declared = true
+			break
+		}
+	}
+	if !declared {
+		return nil, fmt.Errorf("%w: 函数 %s 未声明回调 %s", ErrCallbackInvalid, fn.Name, callbackType)
+	}
+
+	timeout := CallbackTimeout(callbackType)
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	logger.Debug(ctx, "开始执行函数回调",
+		zap.Int64("func_id", fn.ID),
+		zap.String("callback", callbackType),
+		zap.Duration("timeout", timeout))
+	result, err := s.Execute(timeoutCtx, &RunOptions{
+		Runner: opts.Runner,
+		Req: &runcher.RunFunctionReq{
+			User:   opts.Runner.User,
+			Runner: opts.Runner.Name,
+			Method: http.MethodPost,
+			Router: callbackRouter,
+			Body:   string(opts.Body),
+		},
+		FuncID:   fn.ID,
+		Operator: opts.Operator,
+		Callback: callbackType,
+	})
+	if err != nil && errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("回调 %s 执行超时(%s)", callbackType, timeout)
+		if result != nil {
+			result.Record.Status = "fail_timeout"
+			result.Record.Message = err.Error()
+		}
+	}
+	return result, err
+}
+
+// GetCallbacks 获取函数支持的回调列表
+func (s *RunnerFunc) GetCallbacks(ctx context.Context, funcID int64) ([]*dto.FuncCallbackInfo, error) {
+	runnerFunc, err := s.runnerFuncRepo.Get(ctx, funcID)
+	if err != nil {
+		return nil, fmt.Errorf("获取函数失败: %w", err)
+	}
+	if runnerFunc == nil {
+		return nil, errors.New("函数不存在")
+	}
+
+	callbacks := FuncCallbacks(runnerFunc)
+	list := make([]*dto.FuncCallbackInfo, 0, len(callbacks))
+	for _, callback := range callbacks {
+		spec, known := callbackRegistry[callback]
+		list = append(list, &dto.FuncCallbackInfo{
+			Type:    callback,
+			Desc:    spec.Desc,
+			Known:   known,
+			Timeout: int(CallbackTimeout(callback) / time.Second),
+		})
+	}
+	return list, nil
+}
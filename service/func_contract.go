@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/pkg/config"
+	"github.com/yunhanshu-net/function-server/pkg/contract"
+	"github.com/yunhanshu-net/function-server/pkg/dto"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// recentViolationLimit 契约统计接口返回的最近违规记录条数
+const recentViolationLimit = 10
+
+// ContractResult 一次执行的契约校验结果
+type ContractResult struct {
+	Mode       string
+	Violations []contract.Violation
+}
+
+// Violated 是否存在违规
+func (r *ContractResult) Violated() bool {
+	return r != nil && len(r.Violations) > 0
+}
+
+// Error 将违规信息拼成一条错误信息，strict模式下返回给调用方
+func (r *ContractResult) Error() string {
+	if !r.Violated() {
+		return ""
+	}
+	first := r.Violations[0]
+	msg := fmt.Sprintf("函数返回结果不符合契约: %s %s", first.Field, first.Message)
+	if len(r.Violations) > 1 {
+		msg += fmt.Sprintf(" 等%d处", len(r.Violations))
+	}
+	return msg
+}
+
+// Apply 将校验结果写入执行记录
+func (r *ContractResult) Apply(record *model.FuncRunRecord) {
+	if r == nil || r.Mode == contract.ModeOff {
+		return
+	}
+	if !r.Violated() {
+		record.ContractStatus = model.ContractStatusPass
+		return
+	}
+	record.ContractStatus = model.ContractStatusViolated
+	record.ContractViolations, _ = json.Marshal(r.Violations)
+}
+
+// ContractMode 获取函数生效的契约校验模式，函数未单独设置时使用全局配置
+func ContractMode(runnerFunc *model.RunnerFunc) string {
+	if runnerFunc != nil && runnerFunc.ContractMode != "" {
+		return contract.NormalizeMode(runnerFunc.ContractMode)
+	}
+	return contract.NormalizeMode(config.Get().RunConfig.ContractMode)
+}
+
+// CheckResponseContract 校验runner返回的结果是否符合函数声明的Response参数
+// respBody 为runner返回的原始body，返回nil表示没有做校验
+func (s *RunnerFunc) CheckResponseContract(ctx context.Context, runnerFunc *model.RunnerFunc, respBody []byte) *ContractResult {
+	mode := ContractMode(runnerFunc)
+	if runnerFunc == nil || mode == contract.ModeOff {
+		return nil
+	}
+
+	var body struct {
+		Data interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &body); err != nil {
+		logger.Warn(ctx, "解析runner返回结果失败，跳过契约校验", zap.Error(err), zap.Int64("func_id", runnerFunc.ID))
+		return nil
+	}
+
+	violations, err := contract.Check(runnerFunc.Response, body.Data)
+	if err != nil {
+		logger.Warn(ctx, "函数Response参数无效，跳过契约校验", zap.Error(err), zap.Int64("func_id", runnerFunc.ID))
+		return nil
+	}
+	if len(violations) > 0 {
+		logger.Warn(ctx, "函数返回结果不符合契约",
+			zap.Int64("func_id", runnerFunc.ID),
+			zap.String("mode", mode),
+			zap.Any("violations", violations))
+	}
+	return &ContractResult{Mode: mode, Violations: violations}
+}
+
+// GetContractStats 获取函数的契约违规统计
+func (s *RunnerFunc) GetContractStats(ctx context.Context, funcID int64) (*dto.GetContractStatsResp, error) {
+	logger.Debug(ctx, "开始获取函数契约违规统计", zap.Int64("func_id", funcID))
+
+	runnerFunc, err := s.runnerFuncRepo.Get(ctx, funcID)
+	if err != nil {
+		return nil, fmt.Errorf("获取函数失败: %w", err)
+	}
+	if runnerFunc == nil {
+		return nil, errors.New("函数不存在")
+	}
+
+	checked, violated, err := s.runnerFuncRepo.CountContractRecords(ctx, funcID)
+	if err != nil {
+		return nil, fmt.Errorf("统计契约校验记录失败: %w", err)
+	}
+	records, err := s.runnerFuncRepo.GetRecentContractViolations(ctx, funcID, recentViolationLimit)
+	if err != nil {
+		return nil, fmt.Errorf("获取最近契约违规记录失败: %w", err)
+	}
+
+	resp := &dto.GetContractStatsResp{
+		FuncID:         funcID,
+		Mode:           ContractMode(runnerFunc),
+		CheckedCount:   checked,
+		ViolationCount: violated,
+		Recent:         make([]*dto.ContractViolationRecord, 0, len(records)),
+	}
+	for _, record := range records {
+		item := &dto.ContractViolationRecord{
+			RecordID:  record.ID,
+			Status:    record.Status,
+			CreatedAt: time.Time(record.CreatedAt),
+		}
+		if len(record.ContractViolations) > 0 {
+			if err := json.Unmarshal(record.ContractViolations, &item.Violations); err != nil {
+				logger.Warn(ctx, "解析契约违规明细失败", zap.Error(err), zap.Int64("record_id", record.ID))
+			}
+		}
+		resp.Recent = append(resp.Recent, item)
+	}
+	return resp, nil
+}
@@ -0,0 +1,541 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	resp "github.com/yunhanshu-net/function-go/pkg/dto/response"
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/pkg/contract"
+	"github.com/yunhanshu-net/function-server/pkg/dto"
+	"github.com/yunhanshu-net/function-server/pkg/dto/runcher"
+	"github.com/yunhanshu-net/function-server/pkg/jsondiff"
+	"github.com/yunhanshu-net/function-server/pkg/metrics"
+	"github.com/yunhanshu-net/function-server/pkg/redact"
+	"github.com/yunhanshu-net/function-server/pkg/trace"
+	"github.com/yunhanshu-net/function-server/pkg/tracing"
+	"github.com/yunhanshu-net/function-server/repo"
+	"github.com/yunhanshu-net/pkg/logger"
+	"github.com/yunhanshu-net/pkg/x/urlx"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrFuncMismatch 指定的函数和执行的路由不一致
+var ErrFuncMismatch = errors.New("函数与执行路由不一致")
+
+// ErrFuncDisabled 函数未启用，不能执行，以路由对应的函数为准
+var ErrFuncDisabled = errors.New("函数未启用")
+
+// FuncRun 函数执行服务，负责调用runcher执行函数、校验返回结果并生成执行记录
+type FuncRun struct {
+	runnerRepo     *repo.RunnerRepo
+	runnerFuncRepo *repo.RunnerFuncRepo
+	runnerFunc     *RunnerFunc
+	envs           *RunnerEnv
+}
+
+// NewFuncRun 创建函数执行服务
+func NewFuncRun(db *gorm.DB) *FuncRun {
+	return &FuncRun{
+		runnerRepo:     repo.NewRunnerRepo(db),
+		runnerFuncRepo: repo.NewRunnerFuncRepo(db),
+		runnerFunc:     NewRunnerFunc(db),
+		envs:           NewRunnerEnv(db),
+	}
+}
+
+// RunOptions 执行函数的参数
+type RunOptions struct {
+	Runner   *model.Runner
+	Req      *runcher.RunFunctionReq // Version为空时使用环境的版本，没有指定环境时使用runner当前版本
+	Env      string                  // 执行的环境，对应执行地址中的 runner@env
+	FuncID   int64                   // 前端指定的函数ID，必须和路由对应的函数一致；回调执行时为回调的函数
+	Operator string
+	ReplayOf int64
+	Mock     bool   // 请求指定模拟执行，函数上开启了模拟模式时同样会模拟执行
+	Callback string // 回调类型，回调执行不做模拟和契约校验
+
+	ClientDone <-chan struct{} // 客户端断开连接时关闭，触发取消执行
+}
+
+// RunResult 一次函数执行的结果
+type RunResult struct {
+	Resp   *resp.RunFunctionResp
+	Func   *model.RunnerFunc // 找不到对应函数时为nil
+	Record *model.FuncRunRecord
+}
+
+// Execute 执行函数，返回的执行记录还没有保存，由调用方决定同步还是异步保存
+// 返回错误且RunResult不为nil时，说明函数已经执行，执行记录依然需要保存
+func (s *FuncRun) Execute(ctx context.Context, opts *RunOptions) (result *RunResult, err error) {
+	req := opts.Req
+	if opts.Env != "" {
+		version, vars, err := s.envs.Resolve(ctx, opts.Runner, opts.Env)
+		if err != nil {
+			return nil, err
+		}
+		if req.Version == "" {
+			req.Version = version
+		}
+		req.Env, req.EnvConfig = opts.Env, vars
+	}
+	if req.Version == "" {
+		req.Version = opts.Runner.Version
+	}
+	// 执行ID只由服务端生成，不使用客户端传入的ID，避免和其他执行冲突
+	req.RunID = uuid.New().String()
+
+	ctx, span := tracing.Start(ctx, "function.execute", oteltrace.WithAttributes(
+		attribute.String("function.user", req.User),
+		attribute.String("function.runner", req.Runner),
+		attribute.String("function.version", req.Version),
+		attribute.String("function.env", req.Env),
+		attribute.String("function.method", req.Method),
+		attribute.String("function.router", req.Router),
+		attribute.String("function.run_id", req.RunID),
+		attribute.Bool("function.mock", opts.Mock),
+	))
+	start := time.Now()
+	defer func() {
+		status, function := "error", "unknown"
+		if result != nil && result.Record != nil {
+			status = result.Record.Status
+			span.SetAttributes(attribute.String("function.status", status))
+		}
+		// 路由由客户端传入，只用解析到的函数名做指标标签，避免标签无限增长
+		if result != nil && result.Func != nil {
+			function = result.Func.Name
+		}
+		metrics.ObserveFunctionRun(req.User+"/"+req.Runner, function, status, time.Since(start))
+		tracing.End(span, err)
+	}()
+	if err := checkRunnerRunning(opts.Runner); err != nil {
+		return nil, err
+	}
+	return s.execute(ctx, opts)
+}
+
+// execute 执行函数，req的版本和run_id已经由Execute补全
+func (s *FuncRun) execute(ctx context.Context, opts *RunOptions) (*RunResult, error) {
+	req := opts.Req
+
+	record := &model.FuncRunRecord{
+		Base: model.Base{
+			CreatedBy: opts.Operator,
+			UpdatedBy: opts.Operator,
+		},
+		FuncId:   1,
+		StartTs:  time.Now().UnixMilli(),
+		RunnerID: opts.Runner.ID,
+		Version:  req.Version,
+		Env:      req.Env,
+		Method:   req.Method,
+		Router:   req.Router,
+		ReplayOf: opts.ReplayOf,
+		Callback: opts.Callback,
+		RunID:    req.RunID,
+		TraceID:  getTraceID(ctx),
+	}
+	if req.Method == http.MethodGet {
+		marshal, err := json.Marshal(urlx.QueryToMap(req.RawQuery))
+		if err != nil {
+			return nil, fmt.Errorf("json.Marshal 失败：%s", err.Error())
+		}
+		record.Request = marshal
+	} else {
+		record.Request = json.RawMessage(req.Body)
+	}
+
+	fn, err := s.getRunFunc(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if fn != nil {
+		record.FuncId = fn.ID
+		if fn.Disabled {
+			return nil, fmt.Errorf("%w: %s", ErrFuncDisabled, fn.Name)
+		}
+	}
+	result := &RunResult{Func: fn, Record: record}
+
+	if opts.Callback == "" && (opts.Mock || (fn != nil && fn.Mock == model.MockOn)) {
+		return s.mock(ctx, result, req)
+	}
+
+	nodeCtx, runcherService, err := runcherForRunner(ctx, opts.Runner)
+	if err != nil {
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithCancel(nodeCtx)
+	defer cancel()
+	run := &inflightRun{req: req, node: runcherNodeFromContext(nodeCtx), operator: opts.Operator, startAt: time.Now(), cancel: cancel}
+	if err := registerRun(req.RunID, run); err != nil {
+		return nil, err
+	}
+	defer unregisterRun(req.RunID)
+	s.startRunRecord(ctx, record)
+	if opts.ClientDone != nil {
+		// 客户端断开时请求已经结束，不能再使用请求的ctx
+		logCtx := trace.Detach(ctx)
+		go func() {
+			select {
+			case <-opts.ClientDone:
+				cancelRun(logCtx, req.RunID, run, "客户端断开连接")
+			case <-runCtx.Done():
+			}
+		}()
+	}
+
+	function2, err := runcherService.RunFunction2(runCtx, req)
+	record.EndTs = time.Now().UnixMilli()
+	record.Cost = record.EndTs - record.StartTs
+	if reason, cancelled := run.cancelledReason(); cancelled {
+		record.Status = "cancelled"
+		record.Message = reason
+		return result, ErrRunCancelled
+	}
+	if err != nil {
+		// 其他实例取消执行时已经把记录标记为cancelled，runcher中断执行后保持取消状态
+		if reason, cancelled := s.remoteCancelledReason(ctx, record); cancelled {
+			record.Status = "cancelled"
+			record.Message = reason
+			return result, ErrRunCancelled
+		}
+		record.Status = "fail_run"
+		record.Message = err.Error()
+		return result, err
+	}
+
+	record.Response = function2.Data
+	var res resp.RunFunctionResp
+	if err := json.Unmarshal(function2.Data, &res); err != nil {
+		record.Status = "fail_run"
+		record.Message = err.Error()
+		return result, err
+	}
+	result.Resp = &res
+
+	// 校验返回结果是否符合函数声明的Response参数
+	var contractResult *ContractResult
+	if opts.Callback == "" {
+		contractResult = s.runnerFunc.CheckResponseContract(ctx, fn, function2.Data)
+	}
+	contractResult.Apply(record)
+	if contractResult.Violated() && contractResult.Mode == contract.ModeStrict {
+		record.Status = "fail_contract"
+		record.Message = contractResult.Error()
+		return result, errors.New(contractResult.Error())
+	}
+
+	if res.MetaData == nil {
+		res.MetaData = make(map[string]interface{})
+	}
+	for k, v := range function2.Header {
+		if k != "code" {
+			if len(v) > 0 {
+				res.MetaData[k] = v[0]
+			}
+		}
+	}
+	res.MetaData["version"] = req.Version
+	res.MetaData["run_id"] = req.RunID
+	record.Status = "success"
+	if marshal, err := json.Marshal(res); err != nil {
+		logger.Warn(ctx, "序列化函数执行结果失败", zap.Error(err))
+	} else {
+		record.Response = marshal
+	}
+	return result, nil
+}
+
+// mock 模拟执行，不调用runner，根据函数的Response参数生成返回结果，meta_data中标记mock
+func (s *FuncRun) mock(ctx context.Context, result *RunResult, req *runcher.RunFunctionReq) (*RunResult, error) {
+	if result.Func == nil {
+		return nil, errors.New("未找到对应的函数，无法模拟执行")
+	}
+	data, renderType, err := contract.Mock(result.Func.Response)
+	if err != nil {
+		return nil, fmt.Errorf("生成模拟结果失败: %w", err)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"meta_data": map[string]interface{}{
+			"mock":    true,
+			"version": req.Version,
+		},
+		"code":        0,
+		"msg":         "mock",
+		"trace_id":    getTraceID(ctx),
+		"render_type": renderType,
+		"data":        data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var res resp.RunFunctionResp
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+
+	record := result.Record
+	record.EndTs = time.Now().UnixMilli()
+	record.Cost = record.EndTs - record.StartTs
+	record.Mock = true
+	record.Status = "success"
+	record.Response = body
+	result.Resp = &res
+	logger.Info(ctx, "模拟执行函数", zap.Int64("func_id", result.Func.ID), zap.String("router", req.Router))
+	return result, nil
+}
+
+// startRunRecord 执行开始时先创建执行中的记录，其他实例可以根据run_id找到执行并取消
+// 只保存定位执行需要的字段，请求和结果在执行结束后脱敏保存，创建失败不影响执行
+func (s *FuncRun) startRunRecord(ctx context.Context, record *model.FuncRunRecord) {
+	running := &model.FuncRunRecord{
+		Base:     model.Base{CreatedBy: record.CreatedBy, UpdatedBy: record.UpdatedBy},
+		FuncId:   record.FuncId,
+		Status:   "running",
+		StartTs:  record.StartTs,
+		RunnerID: record.RunnerID,
+		Version:  record.Version,
+		Env:      record.Env,
+		Method:   record.Method,
+		Router:   record.Router,
+		ReplayOf: record.ReplayOf,
+		Callback: record.Callback,
+		RunID:    record.RunID,
+		TraceID:  record.TraceID,
+	}
+	if err := s.runnerFuncRepo.CreateRunRecord(ctx, running); err != nil {
+		logger.Warn(ctx, "创建执行中的记录失败", zap.Error(err), zap.String("run_id", record.RunID))
+		return
+	}
+	record.ID = running.ID
+	record.CreatedAt = running.CreatedAt
+}
+
+// remoteCancelledReason 检查执行是否已经被其他实例取消，返回取消原因
+func (s *FuncRun) remoteCancelledReason(ctx context.Context, record *model.FuncRunRecord) (string, bool) {
+	if record.ID == 0 {
+		return "", false
+	}
+	got, err := s.runnerFuncRepo.GetRunRecord(ctx, record.ID)
+	if err != nil || got == nil || got.Status != "cancelled" {
+		return "", false
+	}
+	return got.Message, true
+}
+
+// getRunFunc 获取本次执行的函数，根据runner和请求路由查找
+// 前端指定的函数ID必须和路由对应的函数一致，避免使用其他函数的参数做契约校验、记录错误的函数ID
+// 回调执行的路由是runner中统一的回调路由，使用回调校验时已经确定的函数
+func (s *FuncRun) getRunFunc(ctx context.Context, opts *RunOptions) (*model.RunnerFunc, error) {
+	if opts.Callback != "" {
+		fn, err := s.runnerFuncRepo.Get(ctx, opts.FuncID)
+		if err != nil {
+			return nil, fmt.Errorf("获取函数失败: %w", err)
+		}
+		if fn == nil || fn.RunnerID != opts.Runner.ID {
+			return nil, fmt.Errorf("%w: 回调函数%d不属于runner %s", ErrFuncMismatch, opts.FuncID, opts.Runner.Name)
+		}
+		return fn, nil
+	}
+	fn, err := s.runnerFunc.GetByRunnerRouter(ctx, opts.Runner, opts.Req.Method, opts.Req.Router)
+	if err != nil {
+		logger.Errorf(ctx, "根据路由获取函数失败：%s", err.Error())
+		return nil, fmt.Errorf("获取函数失败: %w", err)
+	}
+	if opts.FuncID > 0 && (fn == nil || fn.ID != opts.FuncID) {
+		return nil, fmt.Errorf("%w: 函数%d不是 %s %s", ErrFuncMismatch, opts.FuncID, opts.Req.Method, opts.Req.Router)
+	}
+	return fn, nil
+}
+
+// Replay 使用执行记录中的请求重新执行函数，version为空时使用原记录的版本，返回新旧结果的差异
+func (s *FuncRun) Replay(ctx context.Context, recordID int64, version string, operator string) (*dto.ReplayRunResp, error) {
+	logger.Info(ctx, "开始重放函数执行记录", zap.Int64("record_id", recordID), zap.String("version", version))
+
+	record, err := s.runnerFuncRepo.GetRunRecord(ctx, recordID)
+	if err != nil {
+		return nil, fmt.Errorf("获取执行记录失败: %w", err)
+	}
+	if record == nil {
+		return nil, errors.New("执行记录不存在")
+	}
+	if record.CreatedBy != operator {
+		return nil, ErrRunForbidden
+	}
+	if record.PayloadHashed || record.RequestTruncated {
+		return nil, errors.New("执行记录的请求只保存了哈希或已被截断，无法重放")
+	}
+	if record.RequestRedacted {
+		return nil, errors.New("执行记录的请求中有字段已被脱敏，无法重放")
+	}
+
+	runner, method, router, err := s.replayTarget(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+
+	if version == "" {
+		version = record.Version
+	}
+	if version == "" {
+		version = runner.Version
+	}
+	if version != runner.Version {
+		if err := s.checkRunnerVersion(ctx, runner, version); err != nil {
+			return nil, err
+		}
+	}
+
+	req := &runcher.RunFunctionReq{
+		User:    runner.User,
+		Runner:  runner.Name,
+		Method:  method,
+		Router:  router,
+		Version: version,
+	}
+	if method == http.MethodGet {
+		req.RawQuery = requestToQuery(record.Request)
+	} else {
+		req.Body = string(record.Request)
+	}
+
+	result, err := s.Execute(ctx, &RunOptions{
+		Runner:   runner,
+		Req:      req,
+		Operator: operator,
+		ReplayOf: record.ID,
+	})
+	if result == nil {
+		return nil, fmt.Errorf("重放执行失败: %w", err)
+	}
+	// 新结果同样脱敏后再返回和比较，避免和原记录中已脱敏的字段产生差异
+	newResponse := redact.Fields(result.Record.Response, PayloadOptions(result.Func).Fields)
+	if saveErr := s.runnerFunc.SaveRunRecord(ctx, result.Func, result.Record); saveErr != nil {
+		return nil, fmt.Errorf("保存重放执行记录失败: %w", saveErr)
+	}
+
+	replayResp := &dto.ReplayRunResp{
+		RecordID:          result.Record.ID,
+		ReplayOf:          record.ID,
+		OldVersion:        record.Version,
+		NewVersion:        version,
+		OldStatus:         record.Status,
+		NewStatus:         result.Record.Status,
+		Old:               record.Response,
+		New:               newResponse,
+		OriginalTruncated: record.ResponseTruncated || record.PayloadHashed,
+	}
+	if err != nil {
+		replayResp.Message = err.Error()
+	}
+	replayResp.Changes, err = diffRunResponse(record.Response, newResponse)
+	if err != nil {
+		logger.Warn(ctx, "比较重放结果失败", zap.Error(err), zap.Int64("record_id", recordID))
+	}
+	replayResp.Same = err == nil && len(replayResp.Changes) == 0
+	return replayResp, nil
+}
+
+// replayTarget 获取重放的runner和路由，旧的执行记录没有保存路由时从函数上推导
+func (s *FuncRun) replayTarget(ctx context.Context, record *model.FuncRunRecord) (*model.Runner, string, string, error) {
+	runnerID, method, router := record.RunnerID, record.Method, record.Router
+	if runnerID == 0 || router == "" {
+		fn, err := s.runnerFuncRepo.Get(ctx, record.FuncId)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("获取函数失败: %w", err)
+		}
+		if fn == nil {
+			return nil, "", "", errors.New("执行记录对应的函数不存在")
+		}
+		runnerID, method = fn.RunnerID, fn.Method
+		runner, err := s.runnerRepo.Get(ctx, runnerID)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("获取Runner失败: %w", err)
+		}
+		if runner == nil {
+			return nil, "", "", errors.New("runner不存在")
+		}
+		return runner, strings.ToUpper(method), FuncRouter(runner, fn), nil
+	}
+
+	runner, err := s.runnerRepo.Get(ctx, runnerID)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("获取Runner失败: %w", err)
+	}
+	if runner == nil {
+		return nil, "", "", errors.New("runner不存在")
+	}
+	return runner, method, router, nil
+}
+
+// FuncRouter 从函数的path中还原执行时使用的路由，是FuncPath的逆过程
+func FuncRouter(runner *model.Runner, runnerFunc *model.RunnerFunc) string {
+	return "/" + strings.Trim(strings.TrimPrefix(runnerFunc.Path, "/"+runner.User+"/"+runner.Name), "/")
+}
+
+// checkRunnerVersion 检查runner是否存在指定版本
+func (s *FuncRun) checkRunnerVersion(ctx context.Context, runner *model.Runner, version string) error {
+	versions, err := s.runnerRepo.GetVersions(ctx, runner.ID)
+	if err != nil {
+		return fmt.Errorf("获取Runner版本失败: %w", err)
+	}
+	for _, v := range versions {
+		// 版本变更回调失败的版本已经回滚，不能再执行
+		if v.Version == version && v.CallbackStatus != SysCallbackFail {
+			return nil
+		}
+	}
+	return fmt.Errorf("runner %s 不存在版本 %s", runner.Name, version)
+}
+
+// requestToQuery 将执行记录中保存的GET参数还原为query
+func requestToQuery(request json.RawMessage) string {
+	var m map[string]interface{}
+	if err := json.Unmarshal(request, &m); err != nil {
+		return ""
+	}
+	values := url.Values{}
+	for k, v := range m {
+		switch val := v.(type) {
+		case []interface{}:
+			for _, item := range val {
+				values.Add(k, fmt.Sprint(item))
+			}
+		case nil:
+			values.Set(k, "")
+		default:
+			values.Set(k, fmt.Sprint(val))
+		}
+	}
+	return values.Encode()
+}
+
+// diffRunResponse 比较两次执行结果，trace_id、meta_data等每次执行都会变化的字段不参与比较
+func diffRunResponse(oldRaw, newRaw json.RawMessage) ([]jsondiff.Change, error) {
+	var oldBody, newBody map[string]interface{}
+	if err := json.Unmarshal(oldRaw, &oldBody); err != nil {
+		return nil, fmt.Errorf("解析原执行结果失败: %w", err)
+	}
+	if err := json.Unmarshal(newRaw, &newBody); err != nil {
+		return nil, fmt.Errorf("解析重放执行结果失败: %w", err)
+	}
+	for _, key := range []string{"trace_id", "meta_data"} {
+		delete(oldBody, key)
+		delete(newBody, key)
+	}
+	return jsondiff.Diff(oldBody, newBody), nil
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yunhanshu-net/function-server/pkg/dto"
+	"github.com/yunhanshu-net/function-server/pkg/dto/runcher"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// ErrRunCancelled 函数执行被取消
+var ErrRunCancelled = errors.New("函数执行已取消")
+
+// ErrRunNotFound 执行不存在或已经结束
+var ErrRunNotFound = errors.New("执行不存在或已结束")
+
+// ErrRunForbidden 只能取消、重放自己发起的执行
+var ErrRunForbidden = errors.New("无权操作他人发起的执行")
+
+// inflightRun 执行中的函数
+type inflightRun struct {
+	req      *runcher.RunFunctionReq
+	node     string // runner所在的runcher节点，取消消息发往同一个节点
+	operator string
+	startAt  time.Time
+	cancel   context.CancelFunc
+
+	mu        sync.Mutex
+	cancelled bool
+	reason    string
+}
+
+// markCancelled 标记为已取消，重复取消时返回false
+func (r *inflightRun) markCancelled(reason string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancelled {
+		return false
+	}
+	r.cancelled = true
+	r.reason = reason
+	return true
+}
+
+// cancelledReason 返回取消原因，没有被取消时ok为false
+func (r *inflightRun) cancelledReason() (reason string, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reason, r.cancelled
+}
+
+// inflightRuns 本实例上执行中的函数，key为run_id
+var inflightRuns = struct {
+	sync.Mutex
+	items map[string]*inflightRun
+}{items: make(map[string]*inflightRun)}
+
+func registerRun(runID string, run *inflightRun) error {
+	inflightRuns.Lock()
+	defer inflightRuns.Unlock()
+	if _, ok := inflightRuns.items[runID]; ok {
+		return fmt.Errorf("run_id %s 正在执行中", runID)
+	}
+	inflightRuns.items[runID] = run
+	return nil
+}
+
+func unregisterRun(runID string) {
+	inflightRuns.Lock()
+	defer inflightRuns.Unlock()
+	delete(inflightRuns.items, runID)
+}
+
+func getInflightRun(runID string) *inflightRun {
+	inflightRuns.Lock()
+	defer inflightRuns.Unlock()
+	return inflightRuns.items[runID]
+}
+
+// cancelRun 通知runcher取消执行，并让等待结果的请求立即返回
+func cancelRun(ctx context.Context, runID string, run *inflightRun, reason string) {
+	if !run.markCancelled(reason) {
+		return
+	}
+	logger.Info(ctx, "取消函数执行", zap.String("run_id", runID), zap.String("reason", reason))
+	if runcherService := GetRuncherService(); runcherService != nil {
+		err := runcherService.CancelRun(WithRuncherNode(ctx, run.node), &runcher.CancelRunReq{
+			User:    run.req.User,
+			Runner:  run.req.Runner,
+			Version: run.req.Version,
+			RunID:   runID,
+			Reason:  reason,
+		})
+		if err != nil {
+			logger.Warn(ctx, "通知runcher取消执行失败", zap.Error(err), zap.String("run_id", runID))
+		}
+	}
+	run.cancel()
+}
+
+// Cancel 取消执行中的函数，只能取消自己发起的执行
+// 执行不在本实例上时根据执行记录找到runner，通知runcher取消
+func (s *FuncRun) Cancel(ctx context.Context, runID string, operator string) error {
+	run := getInflightRun(runID)
+	if run == nil {
+		return s.cancelRemote(ctx, runID, operator)
+	}
+	if run.operator != operator {
+		return ErrRunForbidden
+	}
+	cancelRun(ctx, runID, run, "用户取消")
+	return nil
+}
+
+// cancelRemote 取消其他实例上的执行，先把执行中的记录标记为cancelled，再通知runcher中断执行
+// 发起执行的实例在runcher中断后保持取消状态，返回结果并保存记录
+func (s *FuncRun) cancelRemote(ctx context.Context, runID string, operator string) error {
+	record, err := s.runnerFuncRepo.GetRunRecordByRunID(ctx, runID)
+	if err != nil {
+		return fmt.Errorf("获取执行记录失败: %w", err)
+	}
+	if record == nil || record.Status != "running" {
+		return ErrRunNotFound
+	}
+	if record.CreatedBy != operator {
+		return ErrRunForbidden
+	}
+	runner, err := s.runnerRepo.Get(ctx, record.RunnerID)
+	if err != nil {
+		return fmt.Errorf("获取runner失败: %w", err)
+	}
+	if runner == nil {
+		return ErrRunNotFound
+	}
+	nodeCtx, runcherService, err := runcherForRunner(ctx, runner)
+	if err != nil {
+		return err
+	}
+	// 只更新还在执行中的记录，执行已经结束时不再取消
+	marked, err := s.runnerFuncRepo.MarkRunRecordCancelled(ctx, runID, "用户取消")
+	if err != nil {
+		return fmt.Errorf("更新执行记录失败: %w", err)
+	}
+	if !marked {
+		return ErrRunNotFound
+	}
+	logger.Info(ctx, "取消其他实例上的函数执行", zap.String("run_id", runID), zap.Int64("runner_id", runner.ID))
+	return runcherService.CancelRun(nodeCtx, &runcher.CancelRunReq{
+		User:    runner.User,
+		Runner:  runner.Name,
+		Version: record.Version,
+		RunID:   runID,
+		Reason:  "用户取消",
+	})
+}
+
+// ListInflightRuns 获取用户执行中的函数
+func (s *FuncRun) ListInflightRuns(ctx context.Context, operator string) []*dto.InflightRun {
+	inflightRuns.Lock()
+	defer inflightRuns.Unlock()
+	list := make([]*dto.InflightRun, 0)
+	for runID, run := range inflightRuns.items {
+		if run.operator != operator {
+			continue
+		}
+		list = append(list, &dto.InflightRun{
+			RunID:   runID,
+			User:    run.req.User,
+			Runner:  run.req.Runner,
+			Version: run.req.Version,
+			Method:  run.req.Method,
+			Router:  run.req.Router,
+			StartAt: run.startAt,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartAt.Before(list[j].StartAt) })
+	return list
+}
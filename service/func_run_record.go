@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/pkg/config"
+	"github.com/yunhanshu-net/function-server/pkg/redact"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// PayloadOptions 获取函数执行记录的脱敏、截断和保存方式，函数上的脱敏规则会和全局默认规则合并
+func PayloadOptions(runnerFunc *model.RunnerFunc) redact.Options {
+	runConfig := config.Get().RunConfig
+
+	fields := runConfig.RedactFields
+	if len(fields) == 0 {
+		fields = redact.DefaultFields
+	}
+	fields = append([]string{}, fields...)
+
+	store := runConfig.PayloadStore
+	if runnerFunc != nil {
+		for _, field := range strings.Split(runnerFunc.RedactFields, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
+		}
+		if runnerFunc.PayloadStore != "" {
+			store = runnerFunc.PayloadStore
+		}
+	}
+
+	return redact.Options{
+		Fields:   fields,
+		MaxSize:  runConfig.MaxPayloadSize,
+		HashOnly: store == model.PayloadStoreHash,
+	}
+}
+
+// SanitizeRunRecord 按函数的规则处理执行记录的request和response，保存前调用
+func SanitizeRunRecord(runnerFunc *model.RunnerFunc, record *model.FuncRunRecord) {
+	opts := PayloadOptions(runnerFunc)
+	// 先单独脱敏request并记录是否有字段被替换，被脱敏的请求不能直接重放
+	record.Request, record.RequestRedacted = redact.FieldsMasked(record.Request, opts.Fields)
+	record.Request, record.RequestTruncated = redact.Apply(record.Request, redact.Options{MaxSize: opts.MaxSize, HashOnly: opts.HashOnly})
+	record.Response, record.ResponseTruncated = redact.Apply(record.Response, opts)
+	record.PayloadHashed = opts.HashOnly
+}
+
+// SaveRunRecord 脱敏后保存函数执行记录，执行开始时已经创建了记录的更新为最终结果
+func (s *RunnerFunc) SaveRunRecord(ctx context.Context, runnerFunc *model.RunnerFunc, record *model.FuncRunRecord) error {
+	SanitizeRunRecord(runnerFunc, record)
+	if err := s.runnerFuncRepo.SaveRunRecord(ctx, record); err != nil {
+		logger.Error(ctx, "保存函数执行记录失败", err, zap.Int64("func_id", record.FuncId))
+		return err
+	}
+	return nil
+}
+
+// traceRecordLimit 按链路ID查询执行记录的最大条数
+const traceRecordLimit = 200
+
+// GetRunRecordsByTraceID 获取同一条链路上的函数执行记录
+func (s *RunnerFunc) GetRunRecordsByTraceID(ctx context.Context, traceID string) ([]model.FuncRunRecord, error) {
+	return s.runnerFuncRepo.GetRunRecordsByTraceID(ctx, traceID, traceRecordLimit)
+}
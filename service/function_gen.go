@@ -9,11 +9,14 @@ import (
 	"github.com/yunhanshu-net/function-server/model"
 	"github.com/yunhanshu-net/function-server/pkg/db"
 	"github.com/yunhanshu-net/function-server/pkg/dto"
+	"github.com/yunhanshu-net/function-server/pkg/metrics"
+	"github.com/yunhanshu-net/function-server/pkg/tracing"
 	"github.com/yunhanshu-net/pkg/llm"
 	_ "github.com/yunhanshu-net/pkg/llm/deepseek"
 	_ "github.com/yunhanshu-net/pkg/llm/qwen"
 	"github.com/yunhanshu-net/pkg/logger"
 	"github.com/yunhanshu-net/pkg/x/httpx"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type RagReq struct {
@@ -126,7 +129,7 @@ func (s *RunnerFunc) FunctionGen(ctx context.Context, req *dto.FunctionGenReq) (
 		Message:    req.Message,
 		RenderType: req.RenderType,
 		Enable:     -1,
-		Status:     "生成中",
+		Status:     model.FunctionGenStatusGenerating,
 		Classify:   "代码示例"}
 	mysqlDb.Create(fg)
 	var funcs []model.ServiceTree
@@ -139,11 +142,14 @@ func (s *RunnerFunc) FunctionGen(ctx context.Context, req *dto.FunctionGenReq) (
 
 	rf := &model.RunnerFunc{}
 	task := func() error {
+		defer metrics.TrackGeneration("qwen")()
 		now := time.Now()
 		ss := "\n所属服务目录：" + pkgPath + "\n" + "生成函数类型：" + req.RenderType + "\n" + "该服务目录已经存在的函数逗号分隔多个函数（请勿生成重复函数）：" + strings.Join(existNames, ",")
 		messages := ragResp.DecodeData()
 		messages = append(messages, llm.Message{Role: "user", Content: fmt.Sprintf("<message>%s</message>", req.Message+ss)})
-		err = llm.ChatWithStructMessages(ctx, llm.ProviderQwen, messages, &aiResp)
+		llmCtx, endSpan := traceLLM(ctx, "qwen", "generate")
+		err = llm.ChatWithStructMessages(llmCtx, llm.ProviderQwen, messages, &aiResp)
+		endSpan(err)
 		cost := time.Since(now)
 		if err != nil {
 			logger.Infof(ctx, "函数生成失败 req：%s： err:%s cost：%s", req.Message, err.Error(), cost)
@@ -159,6 +165,7 @@ func (s *RunnerFunc) FunctionGen(ctx context.Context, req *dto.FunctionGenReq) (
 			RunnerID: req.RunnerID,
 			TreeID:   req.TreeID,
 			User:     req.User,
+			Disabled: true, // 审核通过后启用
 		}
 
 		// 实现编译失败重试逻辑，最多重试4次
@@ -214,7 +221,7 @@ func (s *RunnerFunc) FunctionGen(ctx context.Context, req *dto.FunctionGenReq) (
 			Level:      aiResp.Level,
 			Length:     len(aiResp.Code),
 			Thinking:   aiResp.Think,
-			Status:     "待审核"}
+			Status:     model.FunctionGenStatusPending}
 
 		mysqlDb.Where("id = ?", fg.ID).Updates(up)
 		return nil
@@ -269,7 +276,9 @@ func (s *RunnerFunc) fixCodeWithDeepSeek(ctx context.Context, originalCode, erro
 
 	// 调用DeepSeek进行代码修正
 	var fixResp AICodeResponse
-	err := llm.ChatWithStructMessages(ctx, llm.ProviderDeepSeek, messages, &fixResp)
+	llmCtx, endSpan := traceLLM(ctx, "deepseek", "fix")
+	err := llm.ChatWithStructMessages(llmCtx, llm.ProviderDeepSeek, messages, &fixResp)
+	endSpan(err)
 	if err != nil {
 		return "", fmt.Errorf("代码修正调用失败: %w", err)
 	}
@@ -281,3 +290,11 @@ func (s *RunnerFunc) fixCodeWithDeepSeek(ctx context.Context, originalCode, erro
 
 	return fixedCode, nil
 }
+
+// traceLLM 为大模型调用创建span，operation为generate（生成）或fix（修正）
+func traceLLM(ctx context.Context, provider string, operation string) (context.Context, func(err error)) {
+	return tracing.Track(ctx, "llm.chat",
+		attribute.String("llm.provider", provider),
+		attribute.String("llm.operation", operation),
+	)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/yunhanshu-net/function-server/pkg/db"
 	"github.com/yunhanshu-net/function-server/pkg/dto"
 	"github.com/yunhanshu-net/function-server/pkg/llm"
+	"github.com/yunhanshu-net/function-server/pkg/metrics"
 	"github.com/yunhanshu-net/pkg/logger"
 	"github.com/yunhanshu-net/pkg/x/httpx"
 )
@@ -124,7 +125,7 @@ func (s *FunctionGenDoubao) FunctionGenWithDoubao(ctx context.Context, req *dto.
 		Message:    req.Message,
 		RenderType: req.RenderType,
 		Enable:     -1,
-		Status:     "生成中",
+		Status:     model.FunctionGenStatusGenerating,
 		Classify:   "代码示例",
 	}
 	mysqlDb.Create(fg)
@@ -139,6 +140,7 @@ func (s *FunctionGenDoubao) FunctionGenWithDoubao(ctx context.Context, req *dto.
 
 	rf := &model.RunnerFunc{}
 	task := func() error {
+		defer metrics.TrackGeneration("doubao")()
 		now := time.Now()
 		ss := "\n所属服务目录：" + pkgPath + "\n" + "生成函数类型：" + req.RenderType + "\n" + "该服务目录已经存在的函数逗号分隔多个函数（请勿生成重复函数）：" + strings.Join(existNames, ",")
 
@@ -167,7 +169,9 @@ func (s *FunctionGenDoubao) FunctionGenWithDoubao(ctx context.Context, req *dto.
 		messages = append(messages, llm.DoubaoMessage{Role: "user", Content: fmt.Sprintf("<message>%s</message>", req.Message+ss)})
 
 		// 使用简化的调用方式，避免复杂的JSON解析
-		resp, err := doubaoClient.Chat(ctx, messages)
+		llmCtx, endSpan := traceLLM(ctx, "doubao", "generate")
+		resp, err := doubaoClient.Chat(llmCtx, messages)
+		endSpan(err)
 		if err == nil && len(resp.Choices) > 0 {
 			content := resp.Choices[0].Message.Content
 			// 简单解析，提取基本信息
@@ -194,6 +198,7 @@ func (s *FunctionGenDoubao) FunctionGenWithDoubao(ctx context.Context, req *dto.
 			RunnerID: req.RunnerID,
 			TreeID:   req.TreeID,
 			User:     req.User,
+			Disabled: true, // 审核通过后启用
 		}
 
 		// 实现编译失败重试逻辑，最多重试4次
@@ -252,7 +257,7 @@ func (s *FunctionGenDoubao) FunctionGenWithDoubao(ctx context.Context, req *dto.
 			Level:      aiResp.Level,
 			Length:     len(aiResp.Code),
 			Thinking:   aiResp.Think,
-			Status:     "待审核",
+			Status:     model.FunctionGenStatusPending,
 		}
 
 		mysqlDb.Where("id = ?", fg.ID).Updates(up)
@@ -306,7 +311,9 @@ func (s *FunctionGenDoubao) fixCodeWithDoubao(ctx context.Context, originalCode,
 	})
 
 	// 调用豆包进行代码修正
-	resp, err := doubaoClient.Chat(ctx, messages)
+	llmCtx, endSpan := traceLLM(ctx, "doubao", "fix")
+	resp, err := doubaoClient.Chat(llmCtx, messages)
+	endSpan(err)
 	if err != nil {
 		return "", fmt.Errorf("豆包代码修正调用失败: %w", err)
 	}
@@ -11,6 +11,7 @@ import (
 	"github.com/yunhanshu-net/function-server/pkg/db"
 	"github.com/yunhanshu-net/function-server/pkg/dto"
 	"github.com/yunhanshu-net/function-server/pkg/llm"
+	"github.com/yunhanshu-net/function-server/pkg/metrics"
 	"github.com/yunhanshu-net/pkg/logger"
 	"github.com/yunhanshu-net/pkg/x/httpx"
 )
@@ -88,7 +89,7 @@ func (s *FunctionGenQwen) FunctionGenWithQwen(ctx context.Context, req *dto.Func
 		Message:    req.Message,
 		RenderType: req.RenderType,
 		Enable:     -1,
-		Status:     "生成中",
+		Status:     model.FunctionGenStatusGenerating,
 		Classify:   "代码示例",
 	}
 	mysqlDb.Create(fg)
@@ -104,6 +105,7 @@ func (s *FunctionGenQwen) FunctionGenWithQwen(ctx context.Context, req *dto.Func
 
 	rf := &model.RunnerFunc{}
 	task := func() error {
+		defer metrics.TrackGeneration("qwen")()
 		now := time.Now()
 
 		// 构建提示信息
@@ -146,6 +148,7 @@ func (s *FunctionGenQwen) FunctionGenWithQwen(ctx context.Context, req *dto.Func
 			RunnerID: req.RunnerID,
 			TreeID:   req.TreeID,
 			User:     req.User,
+			Disabled: true, // 审核通过后启用
 		}
 
 		// 实现编译失败重试逻辑，最多重试4次
@@ -204,7 +207,7 @@ func (s *FunctionGenQwen) FunctionGenWithQwen(ctx context.Context, req *dto.Func
 			Level:      aiResp.Level,
 			Length:     len(aiResp.Code),
 			Thinking:   aiResp.Think,
-			Status:     "待审核",
+			Status:     model.FunctionGenStatusPending,
 		}
 
 		mysqlDb.Where("id = ?", fg.ID).Updates(up)
@@ -261,7 +264,9 @@ func (s *FunctionGenQwen) generateCodeWithQwen(ctx context.Context, messages []l
 	allMessages := append([]llm.QwenMessage{systemPrompt}, messages...)
 
 	// 调用千问API
-	jsonContent, err := s.qwenClient.ChatWithJSON(ctx, allMessages)
+	llmCtx, endSpan := traceLLM(ctx, "qwen", "generate")
+	jsonContent, err := s.qwenClient.ChatWithJSON(llmCtx, allMessages)
+	endSpan(err)
 	if err != nil {
 		return fmt.Errorf("千问API调用失败: %w", err)
 	}
@@ -326,7 +331,9 @@ func (s *FunctionGenQwen) fixCodeWithQwen(ctx context.Context, originalCode, err
 	})
 
 	// 调用千问进行代码修正
-	jsonContent, err := s.qwenClient.ChatWithJSON(ctx, messages)
+	llmCtx, endSpan := traceLLM(ctx, "qwen", "fix")
+	jsonContent, err := s.qwenClient.ChatWithJSON(llmCtx, messages)
+	endSpan(err)
 	if err != nil {
 		return "", fmt.Errorf("千问代码修正调用失败: %w", err)
 	}
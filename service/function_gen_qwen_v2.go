@@ -10,6 +10,7 @@ import (
 	"github.com/yunhanshu-net/function-server/model"
 	"github.com/yunhanshu-net/function-server/pkg/db"
 	"github.com/yunhanshu-net/function-server/pkg/dto"
+	"github.com/yunhanshu-net/function-server/pkg/metrics"
 	"github.com/yunhanshu-net/function-server/pkg/qwen"
 	"github.com/yunhanshu-net/pkg/logger"
 	"github.com/yunhanshu-net/pkg/x/httpx"
@@ -88,7 +89,7 @@ func (s *FunctionGenQwenV2) FunctionGenWithQwenV2(ctx context.Context, req *dto.
 		Message:    req.Message,
 		RenderType: req.RenderType,
 		Enable:     -1,
-		Status:     "生成中",
+		Status:     model.FunctionGenStatusGenerating,
 		Classify:   "代码示例",
 	}
 	mysqlDb.Create(fg)
@@ -104,6 +105,7 @@ func (s *FunctionGenQwenV2) FunctionGenWithQwenV2(ctx context.Context, req *dto.
 
 	rf := &model.RunnerFunc{}
 	task := func() error {
+		defer metrics.TrackGeneration("qwen")()
 		now := time.Now()
 
 		// 构建提示信息
@@ -138,6 +140,7 @@ func (s *FunctionGenQwenV2) FunctionGenWithQwenV2(ctx context.Context, req *dto.
 			RunnerID: req.RunnerID,
 			TreeID:   req.TreeID,
 			User:     req.User,
+			Disabled: true, // 审核通过后启用
 		}
 
 		// 实现编译失败重试逻辑，最多重试4次
@@ -196,7 +199,7 @@ func (s *FunctionGenQwenV2) FunctionGenWithQwenV2(ctx context.Context, req *dto.
 			Level:      aiResp.Level,
 			Length:     len(aiResp.Code),
 			Thinking:   aiResp.Think,
-			Status:     "待审核",
+			Status:     model.FunctionGenStatusPending,
 		}
 
 		mysqlDb.Where("id = ?", fg.ID).Updates(up)
@@ -286,7 +289,9 @@ func (s *FunctionGenQwenV2) generateCodeWithQwenV2(ctx context.Context, messages
 	allMessages := append([]qwen.Message{systemPrompt}, messages...)
 
 	// 调用千问API
-	jsonContent, err := s.qwenClient.ChatWithJSON(ctx, allMessages)
+	llmCtx, endSpan := traceLLM(ctx, "qwen", "generate")
+	jsonContent, err := s.qwenClient.ChatWithJSON(llmCtx, allMessages)
+	endSpan(err)
 	if err != nil {
 		return fmt.Errorf("千问V2 API调用失败: %w", err)
 	}
@@ -351,7 +356,9 @@ func (s *FunctionGenQwenV2) fixCodeWithQwenV2(ctx context.Context, originalCode,
 	})
 
 	// 调用千问进行代码修正
-	jsonContent, err := s.qwenClient.ChatWithJSON(ctx, messages)
+	llmCtx, endSpan := traceLLM(ctx, "qwen", "fix")
+	jsonContent, err := s.qwenClient.ChatWithJSON(llmCtx, messages)
+	endSpan(err)
 	if err != nil {
 		return "", fmt.Errorf("千问V2代码修正调用失败: %w", err)
 	}
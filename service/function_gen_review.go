@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/repo"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 审核动作
+const (
+	ReviewActionAssign         = "assign"
+	ReviewActionComment        = "comment"
+	ReviewActionApprove        = "approve"
+	ReviewActionReject         = "reject"
+	ReviewActionRequestChanges = "request_changes"
+	ReviewActionSubmit         = "submit"
+)
+
+// ErrFunctionGenReview 当前状态不允许该审核动作
+var ErrFunctionGenReview = errors.New("当前状态不允许该审核操作")
+
+// ErrNotReviewer 指定了审核人时只有审核人可以审核
+var ErrNotReviewer = errors.New("不是指定的审核人")
+
+// reviewTransition 审核动作的目标状态、允许执行的状态，以及审核后函数是否启用
+type reviewTransition struct {
+	to         string
+	from       []string
+	enable     int
+	byReviewer bool // 由审核人执行，指定了审核人时只能由审核人执行
+}
+
+var reviewTransitions = map[string]reviewTransition{
+	ReviewActionApprove:        {to: model.FunctionGenStatusApproved, from: []string{model.FunctionGenStatusPending}, enable: 1, byReviewer: true},
+	ReviewActionReject:         {to: model.FunctionGenStatusRejected, from: []string{model.FunctionGenStatusPending, model.FunctionGenStatusChangesRequested}, enable: -1, byReviewer: true},
+	ReviewActionRequestChanges: {to: model.FunctionGenStatusChangesRequested, from: []string{model.FunctionGenStatusPending}, enable: -1, byReviewer: true},
+	ReviewActionSubmit:         {to: model.FunctionGenStatusPending, from: []string{model.FunctionGenStatusChangesRequested}, enable: -1},
+}
+
+// FunctionGenReview AI生成函数的审核服务
+// 生成的函数创建后是未启用状态，审核通过后才启用
+type FunctionGenReview struct {
+	repo *repo.FunctionGenRepo
+}
+
+// NewFunctionGenReview 创建生成函数审核服务
+func NewFunctionGenReview(db *gorm.DB) *FunctionGenReview {
+	return &FunctionGenReview{repo: repo.NewFunctionGenRepo(db)}
+}
+
+func (s *FunctionGenReview) get(ctx context.Context, id int64) (*model.FunctionGen, error) {
+	gen, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if gen == nil {
+		return nil, errors.New("生成记录不存在")
+	}
+	return gen, nil
+}
+
+// Assign 指定审核人
+func (s *FunctionGenReview) Assign(ctx context.Context, id int64, reviewer string, operator string) (*model.FunctionGen, error) {
+	gen, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if gen.Status == model.FunctionGenStatusApproved || gen.Status == model.FunctionGenStatusRejected {
+		return nil, fmt.Errorf("%w: 已经审核结束", ErrFunctionGenReview)
+	}
+	err = s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.WithContext(ctx).Model(&model.FunctionGen{}).Where("id = ?", id).
+			Updates(map[string]interface{}{"reviewer": reviewer, "updated_by": operator}).Error; err != nil {
+			return err
+		}
+		return s.repo.CreateReviewWithTx(ctx, tx, &model.FunctionGenReview{
+			GenID:   id,
+			Action:  ReviewActionAssign,
+			Comment: "指定审核人：" + reviewer,
+			Base:    model.Base{CreatedBy: operator},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	gen.Reviewer = reviewer
+	return gen, nil
+}
+
+// Comment 添加评论
+func (s *FunctionGenReview) Comment(ctx context.Context, id int64, comment string, operator string) (*model.FunctionGenReview, error) {
+	if _, err := s.get(ctx, id); err != nil {
+		return nil, err
+	}
+	review := &model.FunctionGenReview{
+		GenID:   id,
+		Action:  ReviewActionComment,
+		Comment: comment,
+		Base:    model.Base{CreatedBy: operator},
+	}
+	if err := s.repo.CreateReviewWithTx(ctx, s.repo.GetDB(), review); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// Reviews 获取审核记录
+func (s *FunctionGenReview) Reviews(ctx context.Context, id int64) ([]model.FunctionGenReview, error) {
+	return s.repo.GetReviews(ctx, id)
+}
+
+// Review 执行审核动作：通过、拒绝、要求修改，或修改后重新提交
+// 通过后启用生成的函数，其他动作函数保持未启用
+func (s *FunctionGenReview) Review(ctx context.Context, id int64, action string, comment string, operator string) (*model.FunctionGen, error) {
+	transition, ok := reviewTransitions[action]
+	if !ok {
+		return nil, fmt.Errorf("不支持的审核操作: %s", action)
+	}
+	gen, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if transition.byReviewer && gen.Reviewer != "" && gen.Reviewer != operator {
+		return nil, fmt.Errorf("%w: 审核人为 %s", ErrNotReviewer, gen.Reviewer)
+	}
+	if action == ReviewActionApprove && gen.FunctionID == 0 {
+		return nil, fmt.Errorf("%w: 函数还没有生成成功", ErrFunctionGenReview)
+	}
+
+	updates := map[string]interface{}{
+		"status":     transition.to,
+		"enable":     transition.enable,
+		"updated_by": operator,
+	}
+	if comment != "" && transition.byReviewer {
+		updates["comment"] = comment
+	}
+	if transition.byReviewer {
+		now := model.Time(time.Now())
+		updates["reviewed_by"] = operator
+		updates["reviewed_at"] = &now
+	}
+	err = s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		updated, err := s.repo.UpdateStatusFrom(ctx, tx, id, transition.from, updates)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return fmt.Errorf("%w: %s 状态不能执行 %s", ErrFunctionGenReview, gen.Status, action)
+		}
+		if gen.FunctionID > 0 {
+			if err := s.repo.SetFuncDisabledWithTx(ctx, tx, gen.FunctionID, transition.enable != 1); err != nil {
+				return fmt.Errorf("更新函数启用状态失败: %w", err)
+			}
+		}
+		return s.repo.CreateReviewWithTx(ctx, tx, &model.FunctionGenReview{
+			GenID:   id,
+			Action:  action,
+			Comment: comment,
+			Base:    model.Base{CreatedBy: operator},
+		})
+	})
+	if err != nil {
+		logger.Error(ctx, "审核生成函数失败", err, zap.Int64("id", id), zap.String("action", action))
+		return nil, err
+	}
+	logger.Info(ctx, "审核生成函数", zap.Int64("id", id), zap.String("action", action), zap.String("operator", operator))
+	return s.get(ctx, id)
+}
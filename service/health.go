@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/yunhanshu-net/function-server/pkg/dto"
+	"gorm.io/gorm"
+)
+
+// healthCheckTimeout 单个依赖检查的超时时间
+const healthCheckTimeout = 2 * time.Second
+
+// Health 健康检查服务
+type Health struct {
+	db *gorm.DB
+}
+
+// NewHealth 创建健康检查服务
+func NewHealth(db *gorm.DB) *Health {
+	return &Health{db: db}
+}
+
+// Check 检查数据库和runcher，数据库不可用为down，runcher不可用为degraded
+func (s *Health) Check(ctx context.Context) *dto.HealthResp {
+	resp := &dto.HealthResp{
+		Status: dto.HealthStatusOK,
+		Checks: map[string]*dto.HealthCheck{
+			"mysql":   s.check(ctx, s.pingDB),
+			"runcher": s.check(ctx, pingRuncher),
+		},
+	}
+	if resp.Checks["runcher"].Error != "" {
+		resp.Status = dto.HealthStatusDegraded
+	}
+	if resp.Checks["mysql"].Error != "" {
+		resp.Status = dto.HealthStatusDown
+	}
+	return resp
+}
+
+func (s *Health) check(ctx context.Context, fn func(ctx context.Context) error) *dto.HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	start := time.Now()
+	result := &dto.HealthCheck{Status: "ok"}
+	if err := fn(ctx); err != nil {
+		result.Status = "fail"
+		result.Error = err.Error()
+	}
+	result.Latency = time.Since(start).Milliseconds()
+	return result
+}
+
+func (s *Health) pingDB(ctx context.Context) error {
+	if s.db == nil {
+		return errors.New("数据库未初始化")
+	}
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func pingRuncher(ctx context.Context) error {
+	runcherService, err := requireRuncher()
+	if err != nil {
+		return err
+	}
+	return runcherService.Ping(ctx)
+}
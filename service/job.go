@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/pkg/trace"
+	"github.com/yunhanshu-net/function-server/repo"
+	"github.com/yunhanshu-net/pkg/logger"
+	"github.com/yunhanshu-net/pkg/x/jsonx"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Job 后台任务服务，任务在后台goroutine中执行，执行过程中把进度写入数据库
+type Job struct {
+	repo *repo.JobRepo
+}
+
+// NewJob 创建后台任务服务
+func NewJob(db *gorm.DB) *Job {
+	return &Job{repo: repo.NewJobRepo(db)}
+}
+
+// Get 获取任务
+func (s *Job) Get(ctx context.Context, id int64) (*model.Job, error) {
+	return s.repo.Get(ctx, id)
+}
+
+// ListBySource 获取对象上的任务
+func (s *Job) ListBySource(ctx context.Context, jobType string, sourceID int64) ([]model.Job, error) {
+	return s.repo.ListBySource(ctx, jobType, sourceID)
+}
+
+// JobRunFunc 任务的执行函数，返回的结果保存在任务上
+type JobRunFunc func(ctx context.Context, progress *JobProgress) (interface{}, error)
+
+// Start 保存任务后在后台执行，立即返回任务
+// 后台执行使用脱离请求的ctx，保留链路信息
+func (s *Job) Start(ctx context.Context, job *model.Job, run JobRunFunc) (*model.Job, error) {
+	job.Status = model.JobStatusPending
+	if err := s.repo.Create(ctx, job); err != nil {
+		return nil, fmt.Errorf("创建任务失败: %w", err)
+	}
+	jobCtx := trace.Detach(ctx)
+	go s.run(jobCtx, job, run)
+	return job, nil
+}
+
+func (s *Job) run(ctx context.Context, job *model.Job, run JobRunFunc) {
+	progress := &JobProgress{repo: s.repo, ctx: ctx, job: job}
+	now := model.Time(time.Now())
+	progress.update(map[string]interface{}{"status": model.JobStatusRunning, "started_at": &now})
+
+	var (
+		result interface{}
+		err    error
+	)
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("任务执行异常: %v", r)
+			}
+		}()
+		result, err = run(ctx, progress)
+	}()
+
+	finished := model.Time(time.Now())
+	updates := map[string]interface{}{"finished_at": &finished, "status": model.JobStatusSuccess}
+	if err == nil && result != nil {
+		// 失败时执行函数可能返回带类型的nil，只保存成功的结果
+		updates["result"] = json.RawMessage(jsonx.String(result))
+	}
+	if err != nil {
+		updates["status"] = model.JobStatusFail
+		updates["message"] = err.Error()
+		logger.Error(ctx, "后台任务失败", err, zap.Int64("job_id", job.ID), zap.String("type", job.Type), zap.String("step", job.Step))
+	} else {
+		logger.Info(ctx, "后台任务完成", zap.Int64("job_id", job.ID), zap.String("type", job.Type))
+	}
+	progress.update(updates)
+}
+
+// JobProgress 任务执行过程中更新进度，写入失败只记录日志，不影响任务执行
+type JobProgress struct {
+	repo *repo.JobRepo
+	ctx  context.Context
+	job  *model.Job
+}
+
+func (p *JobProgress) update(updates map[string]interface{}) {
+	if err := p.repo.Update(p.ctx, p.job.ID, updates); err != nil {
+		logger.Warn(p.ctx, "更新任务进度失败", zap.Error(err), zap.Int64("job_id", p.job.ID))
+	}
+}
+
+// SetTotal 设置总步数
+func (p *JobProgress) SetTotal(total int) {
+	p.job.Total = total
+	p.update(map[string]interface{}{"total": total})
+}
+
+// SetTarget 记录任务产生的对象
+func (p *JobProgress) SetTarget(id int64) {
+	p.job.TargetID = id
+	p.update(map[string]interface{}{"target_id": id})
+}
+
+// Step 开始新的步骤
+func (p *JobProgress) Step(step string) {
+	p.job.Step = step
+	p.update(map[string]interface{}{"step": step})
+}
+
+// Advance 完成一步
+func (p *JobProgress) Advance() {
+	p.job.Done++
+	p.update(map[string]interface{}{"done": p.job.Done})
+}
@@ -3,11 +3,15 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/yunhanshu-net/function-runtime/pkg/dto/coder"
 	"github.com/yunhanshu-net/function-server/pkg/dto/runcher"
+	"github.com/yunhanshu-net/function-server/pkg/trace"
+	"github.com/yunhanshu-net/function-server/pkg/tracing"
 	"github.com/yunhanshu-net/pkg/x/jsonx"
-	"sync"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/nats-io/nats.go"
@@ -16,15 +20,44 @@ import (
 	"go.uber.org/zap"
 )
 
+// runcher请求方式
+const (
+	RuncherTransportNATS = "nats"
+	RuncherTransportHTTP = "http"
+	// RuncherTransportFake 进程内模拟的runcher，本地开发和测试时使用
+	RuncherTransportFake = "fake"
+)
+
 // RuncherOptions Runcher服务配置选项
 type RuncherOptions struct {
-	NatsURL string        // NATS服务器URL
-	Timeout time.Duration // 超时时间
+	Transport string        // 请求方式：nats, http, fake，默认nats
+	NatsURL   string        // NATS服务器URL
+	BaseURL   string        // http方式时runcher的地址
+	Timeout   time.Duration // 超时时间
+
+	Token        string // 认证token
+	User         string // 认证用户名
+	Password     string // 认证密码
+	NkeySeedFile string // nkey种子文件
+
+	TLS     bool   // 是否使用TLS
+	TLSCert string // 客户端证书
+	TLSKey  string // 客户端私钥
+	TLSCA   string // 服务端CA证书
+
+	MaxReconnects  int           // 断线后最大重连次数，0表示一直重连
+	ReconnectWait  time.Duration // 重连间隔
+	ConnectTimeout time.Duration // 建立连接超时时间
+
+	// onClosed 连接被关闭（重连次数用完）时回调，主动Close时不会回调
+	onClosed func(service RuncherService)
 }
 
 // RuncherService Runcher服务接口
 type RuncherService interface {
 	RunFunction2(ctx context.Context, req *runcher.RunFunctionReq) (*nats.Msg, error)
+	// CancelRun 通知runcher取消执行中的函数
+	CancelRun(ctx context.Context, req *runcher.CancelRunReq) error
 
 	AddAPI2(ctx context.Context, req *coder.AddApisReq) (rsp *coder.AddApisResp, err error)
 	DeleteAPIs(ctx context.Context, req *coder.DeleteAPIsReq) (rsp *coder.DeleteAPIsResp, err error)
@@ -32,51 +65,186 @@ type RuncherService interface {
 	CreateProject(ctx context.Context, runner *model.Runner) (string, error)
 	DeleteProject(ctx context.Context, req *coder.DeleteProjectReq) (rsp *coder.DeleteProjectResp, err error)
 	AddBizPackage2(ctx context.Context, bizPackage *coder.BizPackage) (*coder.BizPackageResp, error)
-
+	// ActivateVersion 把runner的代码切换到之前的版本
+	ActivateVersion(ctx context.Context, req *runcher.ActivateVersionReq) (*runcher.ActivateVersionResp, error)
+	// UpdateRunnerStatus 通知runcher runner的状态变化
+	UpdateRunnerStatus(ctx context.Context, req *runcher.RunnerStatusReq) error
+
+	// Ping 检查NATS连接状态以及runcher是否可以响应
+	Ping(ctx context.Context) error
+	// Subscribe 订阅runcher发布的消息，如节点心跳，只有NATS支持
+	Subscribe(subject string, handler nats.MsgHandler) error
 	// Close 关闭服务
 	Close() error
 }
 
+// runcherTransport runcher请求的传输方式，请求和响应都使用nats.Msg表示，subject、header和data的含义与传输方式无关
+type runcherTransport interface {
+	// Request 发送请求并等待响应，超时由ctx控制
+	Request(ctx context.Context, msg *nats.Msg) (*nats.Msg, error)
+	// Publish 发送消息，不等待响应
+	Publish(ctx context.Context, msg *nats.Msg) error
+	// Status 连接状态，不可用时返回错误
+	Status() error
+	Close()
+}
+
+// runcherSubscriber 支持订阅的传输方式
+type runcherSubscriber interface {
+	Subscribe(subject string, handler nats.MsgHandler) error
+}
+
 // runcherService Runcher服务实现
 type runcherService struct {
-	nc      *nats.Conn
-	timeout time.Duration
+	transport runcherTransport
+	timeout   time.Duration
+	closing   atomic.Bool
 }
 
-var (
-	globalRuncherService RuncherService
-	runcherMutex         sync.Mutex
-)
+// natsTransport 通过NATS请求runcher
+type natsTransport struct {
+	nc *nats.Conn
+}
+
+func (t *natsTransport) Request(ctx context.Context, msg *nats.Msg) (*nats.Msg, error) {
+	return t.nc.RequestMsgWithContext(ctx, msg)
+}
+
+func (t *natsTransport) Publish(ctx context.Context, msg *nats.Msg) error {
+	return t.nc.PublishMsg(msg)
+}
+
+func (t *natsTransport) Status() error {
+	if status := t.nc.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("NATS连接状态异常: %s", status)
+	}
+	return nil
+}
+
+func (t *natsTransport) Subscribe(subject string, handler nats.MsgHandler) error {
+	_, err := t.nc.Subscribe(subject, handler)
+	return err
+}
+
+func (t *natsTransport) Close() {
+	t.nc.Close()
+}
+
+// runcherHolder atomic.Pointer不能直接存接口，包一层
+type runcherHolder struct {
+	RuncherService
+}
+
+// globalRuncher 全局RuncherService实例，连接成功后原子替换，请求中不需要加锁
+var globalRuncher atomic.Pointer[runcherHolder]
 
 // SetGlobalRuncherService 设置全局RuncherService实例
 func SetGlobalRuncherService(service RuncherService) {
-	runcherMutex.Lock()
-	defer runcherMutex.Unlock()
-	globalRuncherService = service
+	if service == nil {
+		globalRuncher.Store(nil)
+		return
+	}
+	globalRuncher.Store(&runcherHolder{RuncherService: service})
 }
 
 // GetRuncherService 获取全局RuncherService实例
+// 还没有连接上时（如启动时NATS不可用），会立即尝试连接一次，失败返回nil
 func GetRuncherService() RuncherService {
-	runcherMutex.Lock()
-	defer runcherMutex.Unlock()
-	return globalRuncherService
+	if service := loadRuncher(); service != nil {
+		return service
+	}
+	if c := connector.Load(); c != nil {
+		return c.tryConnect()
+	}
+	return nil
+}
+
+func loadRuncher() RuncherService {
+	holder := globalRuncher.Load()
+	if holder == nil {
+		return nil
+	}
+	return holder.RuncherService
+}
+
+// clearRuncher 全局实例是service时清空，返回是否清空
+func clearRuncher(service RuncherService) bool {
+	holder := globalRuncher.Load()
+	if holder == nil || holder.RuncherService != service {
+		return false
+	}
+	return globalRuncher.CompareAndSwap(holder, nil)
+}
+
+// ErrRuncherUnavailable runcher服务未初始化或连接失败，函数执行和部署不可用
+var ErrRuncherUnavailable = errors.New("runcher服务不可用，请稍后重试")
+
+// requireRuncher 获取全局RuncherService，未初始化时返回ErrRuncherUnavailable
+func requireRuncher() (RuncherService, error) {
+	runcherService := GetRuncherService()
+	if runcherService == nil {
+		return nil, ErrRuncherUnavailable
+	}
+	return runcherService, nil
 }
 
-// NewRuncherService 创建Runcher服务
+// NewRuncherService 创建Runcher服务，按opts.Transport选择NATS、HTTP或进程内模拟
 func NewRuncherService(opts RuncherOptions) (RuncherService, error) {
+	switch opts.Transport {
+	case "", RuncherTransportNATS:
+		return newNatsRuncherService(opts)
+	case RuncherTransportHTTP:
+		return newHTTPRuncherService(opts)
+	case RuncherTransportFake:
+		return newFakeRuncherService(opts)
+	default:
+		return nil, fmt.Errorf("不支持的runcher请求方式: %s", opts.Transport)
+	}
+}
+
+// newNatsRuncherService 创建通过NATS请求runcher的服务
+func newNatsRuncherService(opts RuncherOptions) (RuncherService, error) {
 	if opts.NatsURL == "" {
 		opts.NatsURL = nats.DefaultURL
 	}
+	natsOpts, err := opts.natsOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	svc := &runcherService{timeout: opts.Timeout}
+	service := withInstrument(svc)
+	ctx := context.Background()
+	natsOpts = append(natsOpts,
+		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
+			logger.Warn(ctx, "NATS连接断开", zap.Error(err), zap.String("url", nc.ConnectedUrlRedacted()))
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			logger.Info(ctx, "NATS重连成功", zap.String("url", nc.ConnectedUrlRedacted()), zap.Uint64("reconnects", nc.Stats().Reconnects))
+		}),
+		nats.ErrorHandler(func(nc *nats.Conn, sub *nats.Subscription, err error) {
+			logger.Error(ctx, "NATS异步错误", err)
+		}),
+		nats.ClosedHandler(func(nc *nats.Conn) {
+			if svc.closing.Load() {
+				logger.Info(ctx, "NATS连接已关闭")
+				return
+			}
+			logger.Warn(ctx, "NATS连接已关闭，重连次数已用完", zap.Error(nc.LastError()))
+			if opts.onClosed != nil {
+				opts.onClosed(service)
+			}
+		}),
+	)
+
 	// 连接NATS服务器
-	nc, err := nats.Connect(opts.NatsURL)
+	nc, err := nats.Connect(opts.NatsURL, natsOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("连接NATS服务器失败: %w", err)
 	}
-
-	return &runcherService{
-		nc:      nc,
-		timeout: opts.Timeout,
-	}, nil
+	svc.transport = &natsTransport{nc: nc}
+	logger.Info(ctx, "NATS连接成功", zap.String("url", nc.ConnectedUrlRedacted()))
+	return service, nil
 }
 
 func (s *runcherService) RunFunction2(ctx context.Context, req *runcher.RunFunctionReq) (*nats.Msg, error) {
@@ -97,20 +265,30 @@ func (s *runcherService) RunFunction2(ctx context.Context, req *runcher.RunFunct
 		return nil, fmt.Errorf("version 不能为空")
 	}
 	// 发送请求并等待响应
-	msg := nats.NewMsg(fmt.Sprintf("function.run.%s.%s.%s", req.User, req.Runner, req.Version))
+	msg := nats.NewMsg(runcherSubject(ctx, fmt.Sprintf("function.run.%s.%s.%s", req.User, req.Runner, req.Version)))
 	msg.Data = []byte(req.Body)
 	header := nats.Header{}
-	header.Set("trace_id", getTraceID(ctx))
+	setTraceHeader(ctx, header)
 	header.Set("user", req.User)
 	header.Set("runner", req.Runner)
 	header.Set("version", req.Version)
 	header.Set("method", req.Method)
 	header.Set("router", req.Router)
 	header.Set("url_query", req.RawQuery)
+	header.Set("run_id", req.RunID)
+	if req.Env != "" {
+		header.Set("env", req.Env)
+		header.Set("env_config", jsonx.String(req.EnvConfig))
+	}
 	msg.Header = header
 
-	// 发送请求并等待响应
-	resp, err := s.nc.RequestMsg(msg, time.Second*1000)
+	// 发送请求并等待响应，ctx带有超时时间时（如回调）按ctx超时，ctx被取消时立即返回
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Second*1000)
+		defer cancel()
+	}
+	resp, err := s.transport.Request(ctx, msg)
 	if err != nil {
 		logger.Error(ctx, "执行Runner函数失败", err)
 		return nil, fmt.Errorf("执行Runner函数失败: %w", err)
@@ -126,6 +304,29 @@ func (s *runcherService) RunFunction2(ctx context.Context, req *runcher.RunFunct
 
 	return resp, nil
 }
+
+// CancelRun 发布取消消息，runcher收到后停止对应run_id的执行
+func (s *runcherService) CancelRun(ctx context.Context, req *runcher.CancelRunReq) error {
+	if req == nil || req.RunID == "" {
+		return fmt.Errorf("run_id 不能为空")
+	}
+	msg := nats.NewMsg(runcherSubject(ctx, fmt.Sprintf("function.cancel.%s.%s.%s", req.User, req.Runner, req.Version)))
+	msg.Data = []byte(jsonx.String(req))
+	header := nats.Header{}
+	setTraceHeader(ctx, header)
+	header.Set("user", req.User)
+	header.Set("runner", req.Runner)
+	header.Set("version", req.Version)
+	header.Set("run_id", req.RunID)
+	msg.Header = header
+
+	if err := s.transport.Publish(ctx, msg); err != nil {
+		logger.Error(ctx, "发布取消执行消息失败", err, zap.String("run_id", req.RunID))
+		return fmt.Errorf("发布取消执行消息失败: %w", err)
+	}
+	return nil
+}
+
 func (s *runcherService) DeleteProject(ctx context.Context, req *coder.DeleteProjectReq) (rsp *coder.DeleteProjectResp, err error) {
 
 	if req == nil {
@@ -133,10 +334,10 @@ func (s *runcherService) DeleteProject(ctx context.Context, req *coder.DeletePro
 	}
 
 	// 发送请求并等待响应
-	msg := nats.NewMsg(fmt.Sprintf("coder.deleteProject"))
+	msg := nats.NewMsg(runcherSubject(ctx, "coder.deleteProject"))
 	msg.Data = []byte(jsonx.String(req))
 	header := nats.Header{}
-	header.Set("trace_id", getTraceID(ctx))
+	setTraceHeader(ctx, header)
 	header.Set("user", req.User)
 	header.Set("runner", req.Runner)
 	header.Set("version", req.Version)
@@ -145,7 +346,7 @@ func (s *runcherService) DeleteProject(ctx context.Context, req *coder.DeletePro
 	msg.Header = header
 
 	// 发送请求并等待响应
-	resp, err := s.nc.RequestMsg(msg, s.timeout)
+	resp, err := s.request(ctx, msg)
 	if err != nil {
 		logger.Error(ctx, "执行Runner函数失败", err)
 		return nil, fmt.Errorf("执行Runner函数失败: %w", err)
@@ -177,13 +378,13 @@ func (s *runcherService) AddAPI2(ctx context.Context, req *coder.AddApisReq) (rs
 	}
 
 	// 创建请求消息
-	msg := nats.NewMsg("coder.addApis")
+	msg := nats.NewMsg(runcherSubject(ctx, "coder.addApis"))
 	msg.Data = reqBytes
 	msg.Header = nats.Header{}
-	msg.Header.Set("trace_id", getTraceID(ctx))
+	setTraceHeader(ctx, msg.Header)
 
 	// 发送请求并等待响应
-	resp, err := s.nc.RequestMsg(msg, s.timeout)
+	resp, err := s.request(ctx, msg)
 	if err != nil {
 		logger.Error(ctx, "添加API失败", err)
 		return nil, fmt.Errorf("添加API失败: %w", err)
@@ -217,13 +418,13 @@ func (s *runcherService) DeleteAPIs(ctx context.Context, req *coder.DeleteAPIsRe
 	}
 
 	// 创建请求消息
-	msg := nats.NewMsg("coder.deleteApis")
+	msg := nats.NewMsg(runcherSubject(ctx, "coder.deleteApis"))
 	msg.Data = reqBytes
 	msg.Header = nats.Header{}
-	msg.Header.Set("trace_id", getTraceID(ctx))
+	setTraceHeader(ctx, msg.Header)
 
 	// 发送请求并等待响应
-	resp, err := s.nc.RequestMsg(msg, s.timeout)
+	resp, err := s.request(ctx, msg)
 	if err != nil {
 		logger.Error(ctx, "删除api失败", err)
 		return nil, fmt.Errorf("添加API失败: %w", err)
@@ -266,13 +467,13 @@ func (s *runcherService) CreateProject(ctx context.Context, runner *model.Runner
 	}
 
 	// 创建请求消息
-	msg := nats.NewMsg("coder.createProject")
+	msg := nats.NewMsg(runcherSubject(ctx, "coder.createProject"))
 	msg.Data = reqBytes
 	msg.Header = nats.Header{}
-	msg.Header.Set("trace_id", getTraceID(ctx))
+	setTraceHeader(ctx, msg.Header)
 
 	// 发送请求并等待响应
-	resp, err := s.nc.RequestMsg(msg, s.timeout)
+	resp, err := s.request(ctx, msg)
 	if err != nil {
 		logger.Error(ctx, "创建项目失败", err)
 		return "", fmt.Errorf("创建项目失败: %w", err)
@@ -310,13 +511,13 @@ func (s *runcherService) AddBizPackage2(ctx context.Context, bizPackage *coder.B
 	}
 
 	// 创建请求消息
-	msg := nats.NewMsg("coder.addBizPackage")
+	msg := nats.NewMsg(runcherSubject(ctx, "coder.addBizPackage"))
 	msg.Data = reqBytes
 	msg.Header = nats.Header{}
-	msg.Header.Set("trace_id", getTraceID(ctx))
+	setTraceHeader(ctx, msg.Header)
 
 	// 发送请求并等待响应
-	resp, err := s.nc.RequestMsg(msg, s.timeout)
+	resp, err := s.request(ctx, msg)
 	if err != nil {
 		logger.Error(ctx, "添加业务包失败", err)
 		return nil, fmt.Errorf("添加业务包失败: %w", err)
@@ -340,21 +541,155 @@ func (s *runcherService) AddBizPackage2(ctx context.Context, bizPackage *coder.B
 	return &result, nil
 }
 
+func (s *runcherService) ActivateVersion(ctx context.Context, req *runcher.ActivateVersionReq) (*runcher.ActivateVersionResp, error) {
+	if req == nil || req.User == "" || req.Runner == "" || req.Version == "" {
+		return nil, fmt.Errorf("user、runner、version 不能为空")
+	}
+	msg := nats.NewMsg(runcherSubject(ctx, "coder.activateVersion"))
+	msg.Data = []byte(jsonx.String(req))
+	msg.Header = nats.Header{}
+	setTraceHeader(ctx, msg.Header)
+
+	resp, err := s.request(ctx, msg)
+	if err != nil {
+		logger.Error(ctx, "切换版本失败", err)
+		return nil, fmt.Errorf("切换版本失败: %w", err)
+	}
+	if code := resp.Header.Get("code"); code != "0" {
+		errMsg := resp.Header.Get("msg")
+		logger.Error(ctx, "切换版本返回错误", nil, zap.String("errMsg", errMsg))
+		return nil, fmt.Errorf("切换版本错误: %s", errMsg)
+	}
+	var result runcher.ActivateVersionResp
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, fmt.Errorf("解析响应数据失败: %w", err)
+	}
+	return &result, nil
+}
+
+// UpdateRunnerStatus 请求 runner.{action}.{user}.{runner}，等待runcher处理完成
+func (s *runcherService) UpdateRunnerStatus(ctx context.Context, req *runcher.RunnerStatusReq) error {
+	if req == nil || req.User == "" || req.Runner == "" || req.Action == "" {
+		return fmt.Errorf("user、runner、action 不能为空")
+	}
+	msg := nats.NewMsg(runcherSubject(ctx, fmt.Sprintf("runner.%s.%s.%s", req.Action, req.User, req.Runner)))
+	msg.Data = []byte(jsonx.String(req))
+	msg.Header = nats.Header{}
+	setTraceHeader(ctx, msg.Header)
+	msg.Header.Set("user", req.User)
+	msg.Header.Set("runner", req.Runner)
+	msg.Header.Set("version", req.Version)
+
+	resp, err := s.request(ctx, msg)
+	if err != nil {
+		logger.Error(ctx, "通知runcher状态变化失败", err, zap.String("action", req.Action))
+		return fmt.Errorf("通知runcher状态变化失败: %w", err)
+	}
+	if code := resp.Header.Get("code"); code != "0" {
+		errMsg := resp.Header.Get("msg")
+		logger.Error(ctx, "runcher处理状态变化返回错误", nil, zap.String("errMsg", errMsg))
+		return fmt.Errorf("runcher处理状态变化错误: %s", errMsg)
+	}
+	return nil
+}
+
+// runcherPingSubject runcher健康检查的subject
+const runcherPingSubject = "runcher.ping"
+
+func (s *runcherService) Ping(ctx context.Context) error {
+	if err := s.transport.Status(); err != nil {
+		return err
+	}
+	msg := nats.NewMsg(runcherSubject(ctx, runcherPingSubject))
+	msg.Header = nats.Header{}
+	setTraceHeader(ctx, msg.Header)
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	if _, err := s.transport.Request(ctx, msg); err != nil {
+		return fmt.Errorf("runcher无响应: %w", err)
+	}
+	return nil
+}
+
+func (s *runcherService) Subscribe(subject string, handler nats.MsgHandler) error {
+	subscriber, ok := s.transport.(runcherSubscriber)
+	if !ok {
+		return fmt.Errorf("当前runcher请求方式不支持订阅: %s", subject)
+	}
+	return subscriber.Subscribe(subject, handler)
+}
+
 // Close 关闭服务
 func (s *runcherService) Close() error {
-	if s.nc != nil {
-		s.nc.Close()
+	s.closing.Store(true)
+	if s.transport != nil {
+		s.transport.Close()
 	}
 	return nil
 }
 
+// request 发送请求并等待响应，使用服务配置的超时时间
+func (s *runcherService) request(ctx context.Context, msg *nats.Msg) (*nats.Msg, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.transport.Request(ctx, msg)
+}
+
+type runcherNodeKey struct{}
+
+// WithRuncherNode 指定请求发往的runcher节点，node为空时使用默认subject
+func WithRuncherNode(ctx context.Context, node string) context.Context {
+	if node == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, runcherNodeKey{}, node)
+}
+
+func runcherNodeFromContext(ctx context.Context) string {
+	node, _ := ctx.Value(runcherNodeKey{}).(string)
+	return node
+}
+
+// runcherSubject ctx指定了节点时使用节点专属的subject：runcher.{node}.{subject}
+func runcherSubject(ctx context.Context, subject string) string {
+	if node := runcherNodeFromContext(ctx); node != "" {
+		return "runcher." + node + "." + subject
+	}
+	return subject
+}
+
+// splitRuncherNode 取节点专属subject中的节点名称，不是节点专属subject时返回空
+func splitRuncherNode(subject string) string {
+	rest, ok := strings.CutPrefix(subject, "runcher.")
+	if !ok {
+		return ""
+	}
+	node, _, ok := strings.Cut(rest, ".")
+	if !ok {
+		return ""
+	}
+	return node
+}
+
 // 获取追踪ID
 func getTraceID(ctx context.Context) string {
-	if ctx == nil {
-		return ""
+	return trace.FromContext(ctx)
+}
+
+// setTraceHeader 在NATS请求头中传递trace_id和W3C链路信息
+func setTraceHeader(ctx context.Context, header nats.Header) {
+	header.Set("trace_id", getTraceID(ctx))
+	// 有OpenTelemetry span时使用span的traceparent，runcher侧的span能挂到本次请求下
+	if tracing.Inject(ctx, header.Set) {
+		return
+	}
+	if traceparent := trace.TraceparentFromContext(ctx); traceparent != "" {
+		header.Set(trace.HeaderTraceparent, traceparent)
 	}
-	if traceID, ok := ctx.Value("trace_id").(string); ok {
-		return traceID
+	if tracestate := trace.TracestateFromContext(ctx); tracestate != "" {
+		header.Set(trace.HeaderTracestate, tracestate)
 	}
-	return ""
 }
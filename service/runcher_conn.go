@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/yunhanshu-net/function-server/pkg/config"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// lazyConnectInterval 使用时发现runcher不可用，两次立即连接之间的最小间隔，避免每个请求都去连接
+	lazyConnectInterval = time.Second
+	// maxConnectRetryWait 启动时连接失败后台重试的最大间隔
+	maxConnectRetryWait = 30 * time.Second
+)
+
+// RuncherOptionsFromConfig 根据配置生成Runcher服务配置选项
+func RuncherOptionsFromConfig(cfg config.RuncherConfig) RuncherOptions {
+	return RuncherOptions{
+		Transport:      cfg.Transport,
+		NatsURL:        cfg.NatsURL,
+		BaseURL:        cfg.BaseURL,
+		Timeout:        time.Duration(cfg.Timeout) * time.Second,
+		Token:          cfg.Token,
+		User:           cfg.User,
+		Password:       cfg.Password,
+		NkeySeedFile:   cfg.NkeySeedFile,
+		TLS:            cfg.TLS,
+		TLSCert:        cfg.TLSCert,
+		TLSKey:         cfg.TLSKey,
+		TLSCA:          cfg.TLSCA,
+		MaxReconnects:  cfg.MaxReconnects,
+		ReconnectWait:  time.Duration(cfg.ReconnectWait) * time.Second,
+		ConnectTimeout: time.Duration(cfg.ConnectTimeout) * time.Second,
+	}
+}
+
+// natsOptions 生成nats连接参数，不包含连接事件回调
+func (opts RuncherOptions) natsOptions() ([]nats.Option, error) {
+	natsOpts := []nats.Option{nats.Name("function-server")}
+
+	switch {
+	case opts.Token != "":
+		natsOpts = append(natsOpts, nats.Token(opts.Token))
+	case opts.User != "":
+		natsOpts = append(natsOpts, nats.UserInfo(opts.User, opts.Password))
+	case opts.NkeySeedFile != "":
+		nkey, err := nats.NkeyOptionFromSeed(opts.NkeySeedFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取nkey种子文件失败: %w", err)
+		}
+		natsOpts = append(natsOpts, nkey)
+	}
+
+	if opts.TLS || opts.TLSCert != "" || opts.TLSCA != "" {
+		natsOpts = append(natsOpts, nats.Secure())
+	}
+	if opts.TLSCert != "" || opts.TLSKey != "" {
+		natsOpts = append(natsOpts, nats.ClientCert(opts.TLSCert, opts.TLSKey))
+	}
+	if opts.TLSCA != "" {
+		natsOpts = append(natsOpts, nats.RootCAs(opts.TLSCA))
+	}
+
+	maxReconnects := opts.MaxReconnects
+	if maxReconnects == 0 {
+		maxReconnects = -1
+	}
+	natsOpts = append(natsOpts, nats.MaxReconnects(maxReconnects))
+	if opts.ReconnectWait > 0 {
+		natsOpts = append(natsOpts, nats.ReconnectWait(opts.ReconnectWait))
+	}
+	if opts.ConnectTimeout > 0 {
+		natsOpts = append(natsOpts, nats.Timeout(opts.ConnectTimeout))
+	}
+	return natsOpts, nil
+}
+
+// runcherConnector 负责建立runcher连接：启动时连接失败后台重试，使用时发现不可用立即连接，连接被关闭后重新连接
+type runcherConnector struct {
+	opts RuncherOptions
+
+	mu          sync.Mutex // 同一时间只有一个连接尝试
+	lastAttempt time.Time
+	retrying    atomic.Bool
+	stopped     atomic.Bool
+	stop        chan struct{}
+	stopOnce    sync.Once
+}
+
+// connector 当前的连接器，StartRuncherService之后才有
+var connector atomic.Pointer[runcherConnector]
+
+// StartRuncherService 连接runcher并设置为全局RuncherService
+// 连接失败时返回错误并在后台重试，连接成功后原子替换全局实例，期间函数执行和部署接口返回503
+func StartRuncherService(opts RuncherOptions) error {
+	c := &runcherConnector{opts: opts, stop: make(chan struct{})}
+	c.opts.onClosed = c.onClosed
+	if old := connector.Swap(c); old != nil {
+		old.shutdown()
+	}
+	if err := c.connect(); err != nil {
+		c.retry()
+		return err
+	}
+	return nil
+}
+
+// StopRuncherService 停止后台重连并关闭全局RuncherService
+func StopRuncherService() error {
+	if c := connector.Swap(nil); c != nil {
+		c.shutdown()
+	}
+	service := loadRuncher()
+	if service == nil || !clearRuncher(service) {
+		return nil
+	}
+	return service.Close()
+}
+
+func (c *runcherConnector) shutdown() {
+	c.stopOnce.Do(func() {
+		c.stopped.Store(true)
+		close(c.stop)
+	})
+}
+
+func (c *runcherConnector) connect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.connectLocked()
+	return err
+}
+
+// connectLocked 调用方需要持有c.mu
+func (c *runcherConnector) connectLocked() (RuncherService, error) {
+	if c.stopped.Load() {
+		return nil, errors.New("runcher连接已停止")
+	}
+	if service := loadRuncher(); service != nil {
+		return service, nil
+	}
+	c.lastAttempt = time.Now()
+	service, err := NewRuncherService(c.opts)
+	if err != nil {
+		return nil, err
+	}
+	SetGlobalRuncherService(service)
+	logger.Info(context.Background(), "Runcher服务已可用")
+	runConnectHooks(service)
+	return service, nil
+}
+
+// connectHooks runcher连接成功后的回调，连接被关闭重新连接后同样会回调
+var connectHooks struct {
+	sync.Mutex
+	fns []func(RuncherService)
+}
+
+// OnRuncherConnected 注册runcher连接成功后的回调，如订阅节点心跳，已经连接时立即回调一次
+func OnRuncherConnected(fn func(RuncherService)) {
+	connectHooks.Lock()
+	connectHooks.fns = append(connectHooks.fns, fn)
+	connectHooks.Unlock()
+	if service := loadRuncher(); service != nil {
+		fn(service)
+	}
+}
+
+func runConnectHooks(service RuncherService) {
+	connectHooks.Lock()
+	fns := append([]func(RuncherService){}, connectHooks.fns...)
+	connectHooks.Unlock()
+	for _, fn := range fns {
+		fn(service)
+	}
+}
+
+// tryConnect 使用时发现runcher不可用，立即尝试连接一次
+// 已有连接在进行或距离上次尝试太近时直接返回nil，不阻塞请求
+func (c *runcherConnector) tryConnect() RuncherService {
+	if !c.mu.TryLock() {
+		return nil
+	}
+	defer c.mu.Unlock()
+	if time.Since(c.lastAttempt) < lazyConnectInterval {
+		return loadRuncher()
+	}
+	service, err := c.connectLocked()
+	if err != nil {
+		logger.Warn(context.Background(), "连接Runcher服务失败", zap.Error(err))
+		return nil
+	}
+	return service
+}
+
+// retry 后台重试连接，间隔从ReconnectWait开始翻倍，连接成功或停止后退出
+func (c *runcherConnector) retry() {
+	if !c.retrying.CompareAndSwap(false, true) {
+		return
+	}
+	wait := c.opts.ReconnectWait
+	if wait <= 0 {
+		wait = nats.DefaultReconnectWait
+	}
+	go func() {
+		defer c.retrying.Store(false)
+		ctx := context.Background()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-time.After(wait):
+			}
+			err := c.connect()
+			if err == nil {
+				return
+			}
+			wait = min(wait*2, maxConnectRetryWait)
+			logger.Warn(ctx, "连接Runcher服务失败，稍后重试", zap.Error(err), zap.Duration("wait", wait))
+		}
+	}()
+}
+
+// onClosed NATS重连次数用完后连接被关闭，清空全局实例并重新连接
+func (c *runcherConnector) onClosed(service RuncherService) {
+	if c.stopped.Load() {
+		return
+	}
+	if clearRuncher(service) {
+		logger.Warn(context.Background(), "Runcher服务不可用，后台重新连接")
+	}
+	c.retry()
+}
@@ -0,0 +1,591 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	goapi "github.com/yunhanshu-net/function-go/pkg/dto/api"
+	resp "github.com/yunhanshu-net/function-go/pkg/dto/response"
+	"github.com/yunhanshu-net/function-runtime/pkg/dto/coder"
+	"github.com/yunhanshu-net/function-server/pkg/dto/api"
+	"github.com/yunhanshu-net/function-server/pkg/dto/runcher"
+)
+
+// fakeTransport 进程内模拟的runcher，用于本地开发和测试，不需要NATS和真实的runcher
+// 每个runner维护一个模拟项目，添加、删除api和业务包时版本号递增，执行函数时原样返回请求内容
+type fakeTransport struct {
+	mu       sync.Mutex
+	projects map[string]*fakeProject // key: user/runner
+}
+
+// fakeProject 模拟的runner项目
+type fakeProject struct {
+	version  int
+	apis     map[string]*fakeAPI // key: 路由
+	packages map[string]bool     // 业务包路径
+	history  map[int]map[string]*fakeAPI
+}
+
+type fakeAPI struct {
+	info *goapi.Info
+	code string
+}
+
+// newFakeRuncherService 创建进程内模拟的runcher服务
+func newFakeRuncherService(opts RuncherOptions) (RuncherService, error) {
+	return withInstrument(&runcherService{
+		transport: &fakeTransport{projects: make(map[string]*fakeProject)},
+		timeout:   opts.Timeout,
+	}), nil
+}
+
+func (t *fakeTransport) Request(ctx context.Context, msg *nats.Msg) (*nats.Msg, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var (
+		data interface{}
+		err  error
+	)
+	// 模拟的runcher不区分节点，节点专属subject按默认subject处理
+	subject := msg.Subject
+	if node := splitRuncherNode(subject); node != "" {
+		subject = strings.TrimPrefix(subject, "runcher."+node+".")
+	}
+	switch {
+	case subject == runcherPingSubject:
+		data = map[string]string{"status": "ok"}
+	case subject == "coder.createProject":
+		data, err = t.createProject(msg.Data)
+	case subject == "coder.addApis":
+		data, err = t.addAPIs(msg.Data)
+	case subject == "coder.deleteApis":
+		data, err = t.deleteAPIs(msg.Data)
+	case subject == "coder.deleteProject":
+		data, err = t.deleteProject(msg.Header)
+	case subject == "coder.addBizPackage":
+		data, err = t.addBizPackage(msg.Data)
+	case subject == "coder.activateVersion":
+		data, err = t.activateVersion(msg.Data)
+	case strings.HasPrefix(subject, "function.run."):
+		data = fakeRunResp(msg)
+	case strings.HasPrefix(subject, "runner."):
+		// 模拟的runcher没有常驻进程，状态变化直接确认
+		data = map[string]string{}
+	case strings.HasPrefix(subject, "function.cancel."):
+		// 模拟执行是同步完成的，没有需要取消的函数
+		data = map[string]string{}
+	default:
+		err = fmt.Errorf("不支持的subject: %s", msg.Subject)
+	}
+
+	reply := &nats.Msg{Subject: msg.Subject, Header: nats.Header{}}
+	if err != nil {
+		reply.Header.Set("code", "-1")
+		reply.Header.Set("msg", err.Error())
+		return reply, nil
+	}
+	reply.Data, err = json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	reply.Header.Set("code", "0")
+	return reply, nil
+}
+
+func (t *fakeTransport) Publish(ctx context.Context, msg *nats.Msg) error {
+	_, err := t.Request(ctx, msg)
+	return err
+}
+
+func (t *fakeTransport) Status() error {
+	return nil
+}
+
+func (t *fakeTransport) Close() {}
+
+// project 获取runner的模拟项目，create为true时不存在则创建，调用方需要持有t.mu
+func (t *fakeTransport) project(user, runner string, create bool) (*fakeProject, error) {
+	if user == "" || runner == "" {
+		return nil, errors.New("user和runner不能为空")
+	}
+	key := user + "/" + runner
+	p := t.projects[key]
+	if p == nil && create {
+		p = &fakeProject{version: 1, apis: make(map[string]*fakeAPI), packages: make(map[string]bool)}
+		p.history = map[int]map[string]*fakeAPI{1: {}}
+		t.projects[key] = p
+	}
+	if p == nil {
+		return nil, fmt.Errorf("项目不存在: %s", key)
+	}
+	return p, nil
+}
+
+func (t *fakeTransport) createProject(data []byte) (interface{}, error) {
+	var req struct {
+		Name string `json:"name"`
+		User string `json:"user"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("解析请求失败: %w", err)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, err := t.project(req.User, req.Name, true)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"version": p.versionName()}, nil
+}
+
+func (t *fakeTransport) addAPIs(data []byte) (interface{}, error) {
+	var req coder.AddApisReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("解析请求失败: %w", err)
+	}
+	if req.Runner == nil {
+		return nil, errors.New("runner不能为空")
+	}
+
+	// 先全部解析，有一个编译不通过就整体失败，和真实runcher一致
+	infos := make([]*goapi.Info, 0, len(req.CodeApis))
+	for _, codeAPI := range req.CodeApis {
+		info, err := parseFakeAPI(codeAPI)
+		if err != nil {
+			return nil, fmt.Errorf("编译失败 %s: %w", codeAPI.EnName, err)
+		}
+		info.User = req.Runner.User
+		info.Runner = req.Runner.Name
+		infos = append(infos, info)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, err := t.project(req.Runner.User, req.Runner.Name, true)
+	if err != nil {
+		return nil, err
+	}
+	change := &coder.ApiChangeInfo{}
+	for i, info := range infos {
+		if _, ok := p.apis[info.Router]; ok {
+			change.UpdateApi = append(change.UpdateApi, info)
+		} else {
+			change.AddApi = append(change.AddApi, info)
+		}
+		p.apis[info.Router] = &fakeAPI{info: info, code: req.CodeApis[i].Code}
+	}
+	p.bump()
+	change.CurrentVersion = p.versionName()
+	return &coder.AddApisResp{Version: p.versionName(), Hash: p.hash(), ApiChangeInfo: change}, nil
+}
+
+func (t *fakeTransport) deleteAPIs(data []byte) (interface{}, error) {
+	var req coder.DeleteAPIsReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("解析请求失败: %w", err)
+	}
+	if req.Runner == nil {
+		return nil, errors.New("runner不能为空")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, err := t.project(req.Runner.User, req.Runner.Name, false)
+	if err != nil {
+		return nil, err
+	}
+	rsp := &coder.DeleteAPIsResp{}
+	for _, codeAPI := range req.CodeApis {
+		router := fakeRouter(codeAPI)
+		if a, ok := p.apis[router]; ok {
+			rsp.DelApis = append(rsp.DelApis, a.info)
+			delete(p.apis, router)
+		}
+	}
+	p.bump()
+	rsp.Version = p.versionName()
+	rsp.Hash = p.hash()
+	return rsp, nil
+}
+
+func (t *fakeTransport) deleteProject(header nats.Header) (interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := t.project(header.Get("user"), header.Get("runner"), false); err != nil {
+		return nil, err
+	}
+	delete(t.projects, header.Get("user")+"/"+header.Get("runner"))
+	return &coder.DeleteProjectResp{}, nil
+}
+
+func (t *fakeTransport) addBizPackage(data []byte) (interface{}, error) {
+	var req coder.BizPackage
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("解析请求失败: %w", err)
+	}
+	if req.Runner == nil {
+		return nil, errors.New("runner不能为空")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, err := t.project(req.Runner.User, req.Runner.Name, true)
+	if err != nil {
+		return nil, err
+	}
+	p.packages[req.AbsPackagePath] = true
+	p.bump()
+	return &coder.BizPackageResp{Version: p.versionName()}, nil
+}
+
+// activateVersion 恢复目标版本的api，生成一个新版本
+func (t *fakeTransport) activateVersion(data []byte) (interface{}, error) {
+	var req runcher.ActivateVersionReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("解析请求失败: %w", err)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, err := t.project(req.User, req.Runner, false)
+	if err != nil {
+		return nil, err
+	}
+	target, err := strconv.Atoi(strings.TrimPrefix(req.Version, "v"))
+	if err != nil {
+		return nil, fmt.Errorf("版本不存在: %s", req.Version)
+	}
+	apis, ok := p.history[target]
+	if !ok {
+		return nil, fmt.Errorf("版本不存在: %s", req.Version)
+	}
+	p.apis = make(map[string]*fakeAPI, len(apis))
+	for router, a := range apis {
+		p.apis[router] = a
+	}
+	p.bump()
+	return &runcher.ActivateVersionResp{Version: p.versionName(), Hash: p.hash()}, nil
+}
+
+// bump 生成新版本并保存当前api的快照
+func (p *fakeProject) bump() {
+	p.version++
+	snapshot := make(map[string]*fakeAPI, len(p.apis))
+	for router, a := range p.apis {
+		snapshot[router] = a
+	}
+	p.history[p.version] = snapshot
+}
+
+func (p *fakeProject) versionName() string {
+	return "v" + strconv.Itoa(p.version)
+}
+
+// hash 项目内容的摘要，由所有api的路由和代码计算
+func (p *fakeProject) hash() string {
+	routers := make([]string, 0, len(p.apis))
+	for router := range p.apis {
+		routers = append(routers, router)
+	}
+	sort.Strings(routers)
+	h := sha256.New()
+	for _, router := range routers {
+		h.Write([]byte(router))
+		h.Write([]byte{0})
+		h.Write([]byte(p.apis[router].code))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// fakeRunResp 模拟函数执行结果，data中原样返回请求的方法、路由、参数
+func fakeRunResp(msg *nats.Msg) *resp.RunFunctionResp {
+	data := map[string]interface{}{
+		"method": msg.Header.Get("method"),
+		"router": msg.Header.Get("router"),
+	}
+	if env := msg.Header.Get("env"); env != "" {
+		var config map[string]string
+		_ = json.Unmarshal([]byte(msg.Header.Get("env_config")), &config)
+		data["env"] = env
+		data["env_config"] = config
+	}
+	if query := msg.Header.Get("url_query"); query != "" {
+		values, _ := url.ParseQuery(query)
+		data["query"] = values
+	}
+	if len(msg.Data) > 0 {
+		var body interface{}
+		if err := json.Unmarshal(msg.Data, &body); err != nil {
+			body = string(msg.Data)
+		}
+		data["body"] = body
+	}
+	return &resp.RunFunctionResp{
+		Code:    0,
+		Msg:     "ok",
+		TraceID: msg.Header.Get("trace_id"),
+		Data:    data,
+	}
+}
+
+// fakeRouter 函数路由：业务包路径/英文名，与服务树的路径一致
+func fakeRouter(codeAPI *coder.CodeApi) string {
+	return "/" + strings.Trim(codeAPI.AbsPackagePath+"/"+codeAPI.EnName, "/")
+}
+
+// parseFakeAPI 简单解析函数代码生成api信息
+// 只识别FunctionInfo字面量中的常用字段、Get/Post等调用声明的请求方法，以及Request/Response结构体的字段
+func parseFakeAPI(codeAPI *coder.CodeApi) (*goapi.Info, error) {
+	info := &goapi.Info{
+		Router:      fakeRouter(codeAPI),
+		Method:      "POST",
+		EnglishName: codeAPI.EnName,
+		ChineseName: codeAPI.CnName,
+		ApiDesc:     codeAPI.Desc,
+		RenderType:  "form",
+	}
+	if strings.TrimSpace(codeAPI.Code) == "" {
+		return info, nil
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, codeAPI.EnName+".go", codeAPI.Code, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	structs := make(map[string]*ast.StructType)
+	var reqType, rspType string
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.TypeSpec:
+			if st, ok := node.Type.(*ast.StructType); ok {
+				structs[node.Name.Name] = st
+			}
+		case *ast.CallExpr:
+			if method, ok := fakeHTTPMethod(node); ok {
+				info.Method = method
+			}
+		case *ast.CompositeLit:
+			if !strings.HasSuffix(fakeTypeName(node.Type), "FunctionInfo") {
+				return true
+			}
+			for _, elt := range node.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				key, ok := kv.Key.(*ast.Ident)
+				if !ok {
+					continue
+				}
+				switch key.Name {
+				case "ChineseName":
+					info.ChineseName = fakeStringValue(kv.Value, info.ChineseName)
+				case "EnglishName":
+					info.EnglishName = fakeStringValue(kv.Value, info.EnglishName)
+				case "ApiDesc":
+					info.ApiDesc = fakeStringValue(kv.Value, info.ApiDesc)
+				case "RenderType":
+					info.RenderType = fakeStringValue(kv.Value, info.RenderType)
+				case "Tags":
+					info.Tags = fakeStringsValue(kv.Value)
+				case "Async":
+					info.Async = fakeIdentValue(kv.Value) == "true"
+				case "Timeout":
+					info.Timeout, _ = strconv.Atoi(fakeLiteralValue(kv.Value))
+				case "Request":
+					reqType = fakeTypeName(kv.Value)
+				case "Response":
+					rspType = fakeTypeName(kv.Value)
+				}
+			}
+		}
+		return true
+	})
+
+	if reqType == "" {
+		reqType = fakeFindStruct(structs, "Req", "Request")
+	}
+	if rspType == "" {
+		rspType = fakeFindStruct(structs, "Resp", "Response")
+	}
+	if err := fakeSetParams(&info.ParamsIn, structs[reqType], info.RenderType); err != nil {
+		return nil, err
+	}
+	if err := fakeSetParams(&info.ParamsOut, structs[rspType], info.RenderType); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// fakeHTTPMethod 识别 xxx.Get("/path", ...) 这类路由声明，返回请求方法
+func fakeHTTPMethod(call *ast.CallExpr) (string, bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || len(call.Args) == 0 {
+		return "", false
+	}
+	method := strings.ToUpper(sel.Sel.Name)
+	switch method {
+	case "GET", "POST", "PUT", "DELETE", "PATCH":
+	default:
+		return "", false
+	}
+	if !strings.HasPrefix(fakeStringValue(call.Args[0], ""), "/") {
+		return "", false
+	}
+	return method, true
+}
+
+// fakeFindStruct 按后缀查找结构体，有多个时取名称排序后的第一个，保证结果稳定
+func fakeFindStruct(structs map[string]*ast.StructType, suffixes ...string) string {
+	var names []string
+	for name := range structs {
+		for _, suffix := range suffixes {
+			if strings.HasSuffix(name, suffix) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return names[0]
+}
+
+// fakeSetParams 由结构体字段生成参数信息，通过json写入target，target为api.Info的ParamsIn或ParamsOut
+func fakeSetParams(target interface{}, st *ast.StructType, renderType string) error {
+	if st == nil {
+		return nil
+	}
+	params := &api.Params{RenderType: renderType}
+	for _, field := range st.Fields.List {
+		tag := reflect.StructTag("")
+		if field.Tag != nil {
+			tag = reflect.StructTag(strings.Trim(field.Tag.Value, "`"))
+		}
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			code := name.Name
+			if jsonName := strings.Split(tag.Get("json"), ",")[0]; jsonName == "-" {
+				continue
+			} else if jsonName != "" {
+				code = jsonName
+			}
+			param := &api.ParamInfo{
+				Code:      code,
+				Name:      code,
+				Validates: tag.Get("validate"),
+				ValueType: fakeValueType(field.Type),
+			}
+			param.Required = strings.Contains(param.Validates, "required")
+			if field.Comment != nil {
+				param.Desc = strings.TrimSpace(field.Comment.Text())
+			}
+			params.Children = append(params.Children, param)
+		}
+	}
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, target)
+}
+
+// fakeValueType Go类型对应的参数类型
+func fakeValueType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return fakeValueType(t.X)
+	case *ast.ArrayType:
+		return "array"
+	case *ast.MapType, *ast.StructType:
+		return "object"
+	case *ast.Ident:
+		switch {
+		case t.Name == "string":
+			return "string"
+		case t.Name == "bool":
+			return "boolean"
+		case strings.HasPrefix(t.Name, "int"), strings.HasPrefix(t.Name, "uint"), strings.HasPrefix(t.Name, "float"):
+			return "number"
+		}
+	}
+	return "object"
+}
+
+// fakeTypeName 取类型表达式的类型名，如 &pkg.Xxx{} 返回 Xxx
+func fakeTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.StarExpr:
+		return fakeTypeName(t.X)
+	case *ast.UnaryExpr:
+		return fakeTypeName(t.X)
+	case *ast.CompositeLit:
+		return fakeTypeName(t.Type)
+	}
+	return ""
+}
+
+func fakeLiteralValue(expr ast.Expr) string {
+	if lit, ok := expr.(*ast.BasicLit); ok {
+		return lit.Value
+	}
+	return ""
+}
+
+func fakeIdentValue(expr ast.Expr) string {
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// fakeStringValue 字符串字面量的值，不是字面量时返回def
+func fakeStringValue(expr ast.Expr, def string) string {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return def
+	}
+	s, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return def
+	}
+	return s
+}
+
+func fakeStringsValue(expr ast.Expr) []string {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil
+	}
+	var values []string
+	for _, elt := range lit.Elts {
+		if s := fakeStringValue(elt, ""); s != "" {
+			values = append(values, s)
+		}
+	}
+	return values
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/yunhanshu-net/function-runtime/pkg/dto/coder"
+	"github.com/yunhanshu-net/function-server/pkg/dto/api"
+	"github.com/yunhanshu-net/function-server/pkg/dto/runcher"
+	"github.com/yunhanshu-net/pkg/dto/runnerproject"
+)
+
+const fakeTestCode = `package demo
+
+//go:generate runner
+
+type AddReq struct {
+	A int ` + "`json:\"a\" validate:\"required\"`" + ` // 第一个数
+	B int ` + "`json:\"b\"`" + `
+}
+
+type AddResp struct {
+	Sum int ` + "`json:\"sum\"`" + `
+}
+
+var AddInfo = &runner.FunctionInfo{
+	ChineseName: "加法",
+	Tags:        []string{"数学"},
+	Request:     &AddReq{},
+	Response:    &AddResp{},
+}
+
+func init() {
+	runner.Get("/demo/add", Add, AddInfo)
+}
+`
+
+func fakeRequest(t *testing.T, ft *fakeTransport, subject string, req interface{}, out interface{}) nats.Header {
+	t.Helper()
+	msg := nats.NewMsg(subject)
+	msg.Header = nats.Header{}
+	if req != nil {
+		msg.Data, _ = json.Marshal(req)
+	}
+	reply, err := ft.Request(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("%s: %v", subject, err)
+	}
+	if out != nil && reply.Header.Get("code") == "0" {
+		if err := json.Unmarshal(reply.Data, out); err != nil {
+			t.Fatalf("%s: 解析响应失败: %v", subject, err)
+		}
+	}
+	return reply.Header
+}
+
+func TestFakeTransport(t *testing.T) {
+	ft := &fakeTransport{projects: make(map[string]*fakeProject)}
+	runner := &runnerproject.Runner{User: "u", Name: "r", Language: "go"}
+
+	var created struct {
+		Version string `json:"version"`
+	}
+	fakeRequest(t, ft, "coder.createProject", map[string]string{"user": "u", "name": "r"}, &created)
+	if created.Version != "v1" {
+		t.Fatalf("创建项目版本 = %q, 期望 v1", created.Version)
+	}
+	// 节点专属subject按默认subject处理
+	header := fakeRequest(t, ft, runcherSubject(WithRuncherNode(context.Background(), "n1"), "coder.createProject"), map[string]string{"user": "u", "name": "r2"}, nil)
+	if header.Get("code") != "0" || ft.projects["u/r2"] == nil {
+		t.Fatalf("节点专属subject处理失败: %v", header)
+	}
+
+	codeAPI := &coder.CodeApi{EnName: "add", AbsPackagePath: "demo", Code: fakeTestCode}
+	var added coder.AddApisResp
+	fakeRequest(t, ft, "coder.addApis", &coder.AddApisReq{Runner: runner, CodeApis: []*coder.CodeApi{codeAPI}}, &added)
+	if added.Version != "v2" || len(added.ApiChangeInfo.AddApi) != 1 {
+		t.Fatalf("添加api响应异常: %+v", added)
+	}
+	info := added.ApiChangeInfo.AddApi[0]
+	if info.Router != "/demo/add" || info.Method != "GET" || info.ChineseName != "加法" || len(info.Tags) != 1 {
+		t.Fatalf("api信息异常: %+v", info)
+	}
+	var params api.Params
+	b, _ := json.Marshal(info.ParamsIn)
+	if err := json.Unmarshal(b, &params); err != nil || len(params.Children) != 2 {
+		t.Fatalf("请求参数异常: %s", b)
+	}
+	if p := params.Children[0]; p.Code != "a" || !p.Required || p.ValueType != "number" || p.Desc != "第一个数" {
+		t.Fatalf("参数a异常: %+v", p)
+	}
+
+	// 编译不通过的代码返回错误，版本不变
+	bad := &coder.CodeApi{EnName: "bad", AbsPackagePath: "demo", Code: "package demo\nfunc {"}
+	header = fakeRequest(t, ft, "coder.addApis", &coder.AddApisReq{Runner: runner, CodeApis: []*coder.CodeApi{bad}}, nil)
+	if header.Get("code") == "0" || header.Get("msg") == "" {
+		t.Fatalf("编译失败应返回错误: %v", header)
+	}
+
+	var run map[string]interface{}
+	msg := nats.NewMsg("function.run.u.r.v2")
+	msg.Header = nats.Header{}
+	msg.Header.Set("router", "/demo/add")
+	msg.Data = []byte(`{"a":1,"b":2}`)
+	reply, err := ft.Request(context.Background(), msg)
+	if err != nil || reply.Header.Get("code") != "0" {
+		t.Fatalf("执行函数失败: %v %v", err, reply)
+	}
+	if err := json.Unmarshal(reply.Data, &run); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := run["data"].(map[string]interface{})
+	if body, _ := data["body"].(map[string]interface{}); body["a"] != float64(1) {
+		t.Fatalf("执行结果没有原样返回请求: %s", reply.Data)
+	}
+
+	var deleted coder.DeleteAPIsResp
+	fakeRequest(t, ft, "coder.deleteApis", &coder.DeleteAPIsReq{Runner: runner, CodeApis: []*coder.CodeApi{codeAPI}}, &deleted)
+	if deleted.Version != "v3" || len(deleted.DelApis) != 1 {
+		t.Fatalf("删除api响应异常: %+v", deleted)
+	}
+
+	// 切换回v2后api恢复，生成新版本
+	var activated runcher.ActivateVersionResp
+	fakeRequest(t, ft, "coder.activateVersion", &runcher.ActivateVersionReq{User: "u", Runner: "r", Version: "v2"}, &activated)
+	if activated.Version != "v4" || ft.projects["u/r"].apis["/demo/add"] == nil {
+		t.Fatalf("切换版本响应异常: %+v", activated)
+	}
+}
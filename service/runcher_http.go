@@ -0,0 +1,148 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// httpTransport 通过HTTP请求runcher，用于不能部署NATS的环境
+// subject中的.转换为路径，如 function.run.user.runner.v1 请求 POST {base_url}/function/run/user/runner/v1，coder.addApis 请求 POST {base_url}/coder/addApis
+// 请求头、请求体与NATS消息一致，runcher同样在响应头中返回code和msg
+type httpTransport struct {
+	baseURL  string
+	client   *http.Client
+	token    string
+	user     string
+	password string
+}
+
+// httpProtocolHeaders HTTP协议本身的响应头
+var httpProtocolHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Content-Type":      true,
+	"Content-Encoding":  true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+	"Date":              true,
+	"Server":            true,
+	"Vary":              true,
+}
+
+// newHTTPRuncherService 创建通过HTTP请求runcher的服务
+func newHTTPRuncherService(opts RuncherOptions) (RuncherService, error) {
+	if opts.BaseURL == "" {
+		return nil, errors.New("runcher base_url 不能为空")
+	}
+	tlsConfig, err := opts.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if opts.ConnectTimeout > 0 {
+		transport.TLSHandshakeTimeout = opts.ConnectTimeout
+	}
+
+	return withInstrument(&runcherService{
+		transport: &httpTransport{
+			baseURL:  strings.TrimRight(opts.BaseURL, "/"),
+			client:   &http.Client{Transport: transport},
+			token:    opts.Token,
+			user:     opts.User,
+			password: opts.Password,
+		},
+		timeout: opts.Timeout,
+	}), nil
+}
+
+// tlsConfig 根据证书配置生成TLS配置，没有配置证书时返回nil使用默认配置
+func (opts RuncherOptions) tlsConfig() (*tls.Config, error) {
+	if opts.TLSCert == "" && opts.TLSCA == "" {
+		return nil, nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(opts.TLSCert, opts.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if opts.TLSCA != "" {
+		ca, err := os.ReadFile(opts.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("解析CA证书失败")
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+func (t *httpTransport) Request(ctx context.Context, msg *nats.Msg) (*nats.Msg, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.baseURL+"/"+strings.ReplaceAll(msg.Subject, ".", "/"), bytes.NewReader(msg.Data))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range msg.Header {
+		httpReq.Header[k] = v
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	switch {
+	case t.token != "":
+		httpReq.Header.Set("Authorization", "Bearer "+t.token)
+	case t.user != "":
+		httpReq.SetBasicAuth(t.user, t.password)
+	}
+
+	httpResp, err := t.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// HTTP头会被规范化大小写，转换为NATS消息中使用的小写，HTTP协议本身的头不返回，避免混进函数结果的meta_data
+	header := nats.Header{}
+	for k, v := range httpResp.Header {
+		if httpProtocolHeaders[k] {
+			continue
+		}
+		header[strings.ToLower(k)] = v
+	}
+	// 没有返回code说明请求没有到达runcher（如网关错误），当作传输失败
+	if header.Get("code") == "" && httpResp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("runcher HTTP错误 (状态码: %d): %s", httpResp.StatusCode, string(body))
+	}
+	return &nats.Msg{Subject: msg.Subject, Header: header, Data: body}, nil
+}
+
+func (t *httpTransport) Publish(ctx context.Context, msg *nats.Msg) error {
+	_, err := t.Request(ctx, msg)
+	return err
+}
+
+// Status HTTP没有长连接，是否可用由请求结果决定
+func (t *httpTransport) Status() error {
+	return nil
+}
+
+func (t *httpTransport) Close() {
+	t.client.CloseIdleConnections()
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/yunhanshu-net/function-runtime/pkg/dto/coder"
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/pkg/dto/runcher"
+	"github.com/yunhanshu-net/function-server/pkg/metrics"
+	"github.com/yunhanshu-net/function-server/pkg/tracing"
+	"github.com/yunhanshu-net/pkg/dto/runnerproject"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentedRuncher 为每次runcher请求创建client span并记录耗时和失败次数，NATS请求头中的traceparent使用该span
+type instrumentedRuncher struct {
+	next RuncherService
+}
+
+// withInstrument 包装RuncherService，记录OpenTelemetry span和Prometheus指标
+func withInstrument(next RuncherService) RuncherService {
+	return &instrumentedRuncher{next: next}
+}
+
+// instrument 开始一次runcher请求，subject中的user、runner、version用*代替，返回的end在请求结束时调用
+func instrument(ctx context.Context, subject string, attrs ...attribute.KeyValue) (context.Context, func(err error)) {
+	attrs = append(attrs,
+		attribute.String("messaging.system", "nats"),
+		attribute.String("messaging.destination.name", subject),
+	)
+	if node := runcherNodeFromContext(ctx); node != "" {
+		attrs = append(attrs, attribute.String("runcher.node", node))
+	}
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "runcher "+subject, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx, func(err error) {
+		metrics.ObserveRuncherRequest(subject, time.Since(start), err)
+		tracing.End(span, err)
+	}
+}
+
+func runnerAttrs(user, runner, version string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("function.user", user),
+		attribute.String("function.runner", runner),
+		attribute.String("function.version", version),
+	}
+}
+
+func projectAttrs(rp *runnerproject.Runner) []attribute.KeyValue {
+	if rp == nil {
+		return nil
+	}
+	return runnerAttrs(rp.User, rp.Name, rp.Version)
+}
+
+func (t *instrumentedRuncher) RunFunction2(ctx context.Context, req *runcher.RunFunctionReq) (msg *nats.Msg, err error) {
+	attrs := append(runnerAttrs(req.User, req.Runner, req.Version),
+		attribute.String("function.method", req.Method),
+		attribute.String("function.router", req.Router),
+		attribute.String("function.run_id", req.RunID),
+	)
+	ctx, end := instrument(ctx, "function.run.*", attrs...)
+	defer func() { end(err) }()
+	return t.next.RunFunction2(ctx, req)
+}
+
+func (t *instrumentedRuncher) CancelRun(ctx context.Context, req *runcher.CancelRunReq) (err error) {
+	attrs := append(runnerAttrs(req.User, req.Runner, req.Version), attribute.String("function.run_id", req.RunID))
+	ctx, end := instrument(ctx, "function.cancel.*", attrs...)
+	defer func() { end(err) }()
+	return t.next.CancelRun(ctx, req)
+}
+
+func (t *instrumentedRuncher) AddAPI2(ctx context.Context, req *coder.AddApisReq) (rsp *coder.AddApisResp, err error) {
+	ctx, end := instrument(ctx, "coder.addApis", projectAttrs(req.Runner)...)
+	defer func() { end(err) }()
+	return t.next.AddAPI2(ctx, req)
+}
+
+func (t *instrumentedRuncher) DeleteAPIs(ctx context.Context, req *coder.DeleteAPIsReq) (rsp *coder.DeleteAPIsResp, err error) {
+	ctx, end := instrument(ctx, "coder.deleteApis", projectAttrs(req.Runner)...)
+	defer func() { end(err) }()
+	return t.next.DeleteAPIs(ctx, req)
+}
+
+func (t *instrumentedRuncher) CreateProject(ctx context.Context, runner *model.Runner) (version string, err error) {
+	ctx, end := instrument(ctx, "coder.createProject", runnerAttrs(runner.User, runner.Name, runner.Version)...)
+	defer func() { end(err) }()
+	return t.next.CreateProject(ctx, runner)
+}
+
+func (t *instrumentedRuncher) DeleteProject(ctx context.Context, req *coder.DeleteProjectReq) (rsp *coder.DeleteProjectResp, err error) {
+	ctx, end := instrument(ctx, "coder.deleteProject", runnerAttrs(req.User, req.Runner, req.Version)...)
+	defer func() { end(err) }()
+	return t.next.DeleteProject(ctx, req)
+}
+
+func (t *instrumentedRuncher) AddBizPackage2(ctx context.Context, bizPackage *coder.BizPackage) (rsp *coder.BizPackageResp, err error) {
+	ctx, end := instrument(ctx, "coder.addBizPackage", projectAttrs(bizPackage.Runner)...)
+	defer func() { end(err) }()
+	return t.next.AddBizPackage2(ctx, bizPackage)
+}
+
+func (t *instrumentedRuncher) ActivateVersion(ctx context.Context, req *runcher.ActivateVersionReq) (rsp *runcher.ActivateVersionResp, err error) {
+	attrs := append(runnerAttrs(req.User, req.Runner, req.CurrentVersion), attribute.String("function.target_version", req.Version))
+	ctx, end := instrument(ctx, "coder.activateVersion", attrs...)
+	defer func() { end(err) }()
+	return t.next.ActivateVersion(ctx, req)
+}
+
+func (t *instrumentedRuncher) UpdateRunnerStatus(ctx context.Context, req *runcher.RunnerStatusReq) (err error) {
+	attrs := append(runnerAttrs(req.User, req.Runner, req.Version), attribute.String("runner.action", req.Action))
+	ctx, end := instrument(ctx, "runner."+req.Action+".*", attrs...)
+	defer func() { end(err) }()
+	return t.next.UpdateRunnerStatus(ctx, req)
+}
+
+// Ping 健康检查调用频繁，不记录span和指标
+func (t *instrumentedRuncher) Ping(ctx context.Context) error {
+	return t.next.Ping(ctx)
+}
+
+func (t *instrumentedRuncher) Subscribe(subject string, handler nats.MsgHandler) error {
+	return t.next.Subscribe(subject, handler)
+}
+
+func (t *instrumentedRuncher) Close() error {
+	return t.next.Close()
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/pkg/config"
+	"github.com/yunhanshu-net/function-server/pkg/db"
+	"github.com/yunhanshu-net/function-server/pkg/dto/runcher"
+	"github.com/yunhanshu-net/function-server/repo"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// RuncherHeartbeatSubject runcher节点发布心跳的subject
+const RuncherHeartbeatSubject = "runcher.heartbeat"
+
+// ErrNoRuncherNode 注册了runcher节点，但没有可以分配runner的节点
+var ErrNoRuncherNode = errors.New("没有可用的runcher节点")
+
+// ErrRuncherNodeStatus 不支持的节点状态
+var ErrRuncherNodeStatus = errors.New("不支持的runcher节点状态")
+
+// runcherNodeNamePattern 节点名称会拼到subject中，不能包含.和通配符
+var runcherNodeNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// nodeNames 节点ID到名称的缓存，节点名称注册后不会变化，执行函数时不需要每次查库
+var nodeNames sync.Map
+
+// RuncherNode runcher节点服务
+type RuncherNode struct {
+	repo *repo.RuncherNodeRepo
+}
+
+// NewRuncherNode 创建runcher节点服务
+func NewRuncherNode(db *gorm.DB) *RuncherNode {
+	return &RuncherNode{repo: repo.NewRuncherNodeRepo(db)}
+}
+
+func heartbeatTimeout() time.Duration {
+	timeout := config.Get().RuncherConfig.HeartbeatTimeout
+	if timeout <= 0 {
+		timeout = 30
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// Heartbeat 处理节点心跳，节点不存在时注册
+func (s *RuncherNode) Heartbeat(ctx context.Context, hb *runcher.Heartbeat) error {
+	if !runcherNodeNamePattern.MatchString(hb.Name) {
+		return fmt.Errorf("节点名称不合法: %s", hb.Name)
+	}
+	now := model.Time(time.Now())
+	node, err := s.repo.GetByName(ctx, hb.Name)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		node = &model.RuncherNode{
+			Name:          hb.Name,
+			Address:       hb.Address,
+			Labels:        strings.Join(hb.Labels, ","),
+			Capacity:      hb.Capacity,
+			Version:       hb.Version,
+			Status:        model.RuncherNodeStatusActive,
+			LastHeartbeat: &now,
+		}
+		if err := s.repo.Create(ctx, node); err != nil {
+			return fmt.Errorf("注册runcher节点失败: %w", err)
+		}
+		logger.Info(ctx, "runcher节点已注册", zap.String("node", hb.Name), zap.String("address", hb.Address))
+	} else {
+		err = s.repo.Update(ctx, node.ID, map[string]interface{}{
+			"address":        hb.Address,
+			"labels":         strings.Join(hb.Labels, ","),
+			"capacity":       hb.Capacity,
+			"version":        hb.Version,
+			"last_heartbeat": now,
+		})
+		if err != nil {
+			return fmt.Errorf("更新runcher节点心跳失败: %w", err)
+		}
+	}
+	nodeNames.Store(node.ID, node.Name)
+	return nil
+}
+
+// Watch runcher连接成功后订阅节点心跳，HTTP和模拟方式不支持订阅，节点需要调用心跳接口
+func (s *RuncherNode) Watch() {
+	OnRuncherConnected(func(service RuncherService) {
+		ctx := context.Background()
+		err := service.Subscribe(RuncherHeartbeatSubject, func(msg *nats.Msg) {
+			var hb runcher.Heartbeat
+			if err := json.Unmarshal(msg.Data, &hb); err != nil {
+				logger.Warn(ctx, "解析runcher节点心跳失败", zap.Error(err))
+				return
+			}
+			if err := s.Heartbeat(ctx, &hb); err != nil {
+				logger.Warn(ctx, "处理runcher节点心跳失败", zap.Error(err), zap.String("node", hb.Name))
+			}
+		})
+		if err != nil {
+			logger.Warn(ctx, "订阅runcher节点心跳失败", zap.Error(err))
+		}
+	})
+}
+
+// List 获取全部节点，包含节点上的runner数量和心跳状态
+func (s *RuncherNode) List(ctx context.Context) ([]*model.RuncherNode, error) {
+	nodes, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取runcher节点列表失败: %w", err)
+	}
+	counts, err := s.repo.CountRunners(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("统计runcher节点runner数量失败: %w", err)
+	}
+	now, timeout := time.Now(), heartbeatTimeout()
+	for _, node := range nodes {
+		node.RunnerCount = counts[node.ID]
+		node.Healthy = node.IsHealthy(now, timeout)
+	}
+	return nodes, nil
+}
+
+// Get 获取节点，包含节点上的runner数量和心跳状态，不存在时返回nil
+func (s *RuncherNode) Get(ctx context.Context, id int64) (*model.RuncherNode, error) {
+	nodes, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		if node.ID == id {
+			return node, nil
+		}
+	}
+	return nil, nil
+}
+
+// UpdateStatus 修改节点状态
+func (s *RuncherNode) UpdateStatus(ctx context.Context, id int64, status string) error {
+	if !model.IsRuncherNodeStatus(status) {
+		return fmt.Errorf("%w: %s", ErrRuncherNodeStatus, status)
+	}
+	node, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return errors.New("runcher节点不存在")
+	}
+	return s.repo.Update(ctx, id, map[string]interface{}{"status": status})
+}
+
+// Place 为新的runner选择节点：心跳正常、状态为active、包含要求的标签且没有满的节点中，runner最少的一个
+// 选中的节点会原子地占用一个容量，并发创建时不会超过容量，runner没有创建成功时调用方需要Release
+// 没有注册任何节点时返回nil，使用默认subject（单runcher部署）
+func (s *RuncherNode) Place(ctx context.Context, labels []string) (*model.RuncherNode, error) {
+	nodes, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+	var candidates []*model.RuncherNode
+	for _, node := range nodes {
+		if s.available(node) && node.HasLabels(labels) {
+			candidates = append(candidates, node)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w，要求的标签: %s", ErrNoRuncherNode, strings.Join(labels, ","))
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].RunnerCount < candidates[j].RunnerCount
+	})
+	for _, node := range candidates {
+		ok, err := s.Reserve(ctx, node)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return node, nil
+		}
+	}
+	return nil, fmt.Errorf("%w，节点容量已被占满", ErrNoRuncherNode)
+}
+
+// Reserve 占用节点的一个容量，节点已满时返回false
+func (s *RuncherNode) Reserve(ctx context.Context, node *model.RuncherNode) (bool, error) {
+	ok, err := s.repo.Reserve(ctx, node.ID, node.RunnerCount)
+	if err != nil {
+		return false, fmt.Errorf("占用runcher节点容量失败: %w", err)
+	}
+	return ok, nil
+}
+
+// Release 释放runner占用的节点容量，runner没有分配节点时不处理，失败只记录日志
+func (s *RuncherNode) Release(ctx context.Context, runcherID *int64) {
+	if runcherID == nil {
+		return
+	}
+	if err := s.repo.Release(ctx, *runcherID); err != nil {
+		logger.Warn(ctx, "释放runcher节点容量失败", zap.Error(err), zap.Int64("node_id", *runcherID))
+	}
+}
+
+// available 节点可以分配新的runner
+func (s *RuncherNode) available(node *model.RuncherNode) bool {
+	return node.Healthy && node.Status == model.RuncherNodeStatusActive && !node.Full()
+}
+
+// runcherNodeName 根据节点ID获取节点名称
+func runcherNodeName(ctx context.Context, id int64) (string, error) {
+	if name, ok := nodeNames.Load(id); ok {
+		return name.(string), nil
+	}
+	node, err := repo.NewRuncherNodeRepo(db.GetDB()).Get(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if node == nil {
+		return "", fmt.Errorf("runcher节点不存在: %d", id)
+	}
+	nodeNames.Store(id, node.Name)
+	return node.Name, nil
+}
+
+// withRunnerNode runner分配了节点时，返回的ctx中带有节点名称，请求会发往节点专属的subject
+func withRunnerNode(ctx context.Context, runner *model.Runner) (context.Context, error) {
+	if runner == nil || runner.RuncherID == nil {
+		return ctx, nil
+	}
+	name, err := runcherNodeName(ctx, *runner.RuncherID)
+	if err != nil {
+		return ctx, err
+	}
+	return WithRuncherNode(ctx, name), nil
+}
+
+// runcherForRunner 获取RuncherService以及发往runner所在节点的ctx
+func runcherForRunner(ctx context.Context, runner *model.Runner) (context.Context, RuncherService, error) {
+	service, err := requireRuncher()
+	if err != nil {
+		return ctx, nil, err
+	}
+	ctx, err = withRunnerNode(ctx, runner)
+	if err != nil {
+		return ctx, nil, err
+	}
+	return ctx, service, nil
+}
+
+// splitLabels 逗号分隔的标签
+func splitLabels(labels string) []string {
+	var result []string
+	for _, label := range strings.Split(labels, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			result = append(result, label)
+		}
+	}
+	return result
+}
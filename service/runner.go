@@ -21,15 +21,15 @@ func timeToModelTime(t time.Time) model.Time {
 
 // Runner Runner服务实现
 type Runner struct {
-	repo           *repo.RunnerRepo
-	runcherService RuncherService
+	repo  *repo.RunnerRepo
+	nodes *RuncherNode
 }
 
 // NewRunner 创建Runner服务
 func NewRunner(db *gorm.DB) *Runner {
 	return &Runner{
-		runcherService: GetRuncherService(),
-		repo:           repo.NewRunnerRepo(db),
+		repo:  repo.NewRunnerRepo(db),
+		nodes: NewRuncherNode(db),
 	}
 }
 
@@ -61,8 +61,28 @@ func (s *Runner) Create(ctx context.Context, runner *model.Runner) error {
 		runner.Status = 1 // 默认启用
 	}
 
+	// 集群部署时为runner分配节点，之后的请求都发往该节点
+	node, err := s.nodes.Place(ctx, splitLabels(runner.RuncherLabels))
+	if err != nil {
+		return err
+	}
+	created := false
+	if node != nil {
+		runner.RuncherID = &node.ID
+		logger.Info(ctx, "Runner分配到runcher节点", zap.String("name", runner.Name), zap.String("node", node.Name))
+		// 创建失败时释放占用的节点容量
+		defer func() {
+			if !created {
+				s.nodes.Release(ctx, &node.ID)
+			}
+		}()
+	}
+	nodeCtx, runcherService, err := runcherForRunner(ctx, runner)
+	if err != nil {
+		return err
+	}
 	//todo 这里先忽略错误
-	versionString, _ := s.runcherService.CreateProject(ctx, runner)
+	versionString, _ := runcherService.CreateProject(nodeCtx, runner)
 	//if err != nil {
 	//	return err
 	//}
@@ -139,6 +159,7 @@ func (s *Runner) Create(ctx context.Context, runner *model.Runner) error {
 		return fmt.Errorf("提交事务失败: %w", err)
 	}
 
+	created = true
 	logger.Info(ctx, "创建Runner成功", zap.Int64("id", runner.ID), zap.String("title", runner.Title))
 	return nil
 }
@@ -204,6 +225,9 @@ func (s *Runner) Update(ctx context.Context, id int64, runner *model.Runner) err
 		}
 	}
 
+	// 状态只能通过启动、停止等操作修改，这里忽略
+	runner.Status = 0
+
 	// 设置更新时间
 	runner.UpdatedAt = timeToModelTime(time.Now())
 
@@ -230,7 +254,11 @@ func (s *Runner) Delete(ctx context.Context, id int64, operator string) error {
 		logger.Info(ctx, "Runner不存在", zap.Int64("id", id))
 		return errors.New("runner不存在")
 	}
-	_, err = s.runcherService.DeleteProject(ctx, &coder.DeleteProjectReq{
+	nodeCtx, runcherService, err := runcherForRunner(ctx, existingRunner)
+	if err != nil {
+		return err
+	}
+	_, err = runcherService.DeleteProject(nodeCtx, &coder.DeleteProjectReq{
 		User:    existingRunner.User,
 		Runner:  existingRunner.Name,
 		Version: existingRunner.Version,
@@ -249,6 +277,7 @@ func (s *Runner) Delete(ctx context.Context, id int64, operator string) error {
 		logger.Error(ctx, "删除Runner失败", err, zap.Int64("id", id))
 		return fmt.Errorf("删除Runner失败: %w", err)
 	}
+	s.nodes.Release(ctx, existingRunner.RuncherID)
 
 	logger.Info(ctx, "删除Runner成功", zap.Int64("id", id))
 	return nil
@@ -268,73 +297,6 @@ func (s *Runner) List(ctx context.Context, page, pageSize int, conditions map[st
 	return runners, total, nil
 }
 
-// Fork 复制Runner
-func (s *Runner) Fork(ctx context.Context, id int64, operator string) (*model.Runner, error) {
-	logger.Debug(ctx, "开始复制Runner", zap.Int64("id", id))
-	// 先检查是否存在
-	sourceRunner, err := s.repo.Get(ctx, id)
-	if err != nil {
-		logger.Error(ctx, "获取源Runner失败", err, zap.Int64("id", id))
-		return nil, fmt.Errorf("获取源Runner失败: %w", err)
-	}
-	if sourceRunner == nil {
-		logger.Info(ctx, "源Runner不存在", zap.Int64("id", id))
-		return nil, errors.New("源Runner不存在")
-	}
-
-	// 创建一个新的Runner
-	newRunner := *sourceRunner
-	newRunner.ID = 0 // 重置ID，让数据库自动生成
-
-	// 修改标题，添加"Copy of"前缀
-	newRunner.Title = fmt.Sprintf("Copy of %s", sourceRunner.Title)
-
-	// 生成唯一名称
-	newRunner.Name = fmt.Sprintf("%s_copy_%d", sourceRunner.Name, time.Now().UnixNano())
-
-	// 设置创建者和更新者信息
-	newRunner.CreatedBy = operator
-	newRunner.UpdatedBy = operator
-
-	// 设置fork源信息
-	newRunner.ForkFromID = &sourceRunner.ID
-	newRunner.ForkFromUser = sourceRunner.User
-
-	// 设置当前用户
-	newRunner.User = operator
-
-	// 设置创建时间和更新时间
-	now := time.Now()
-	newRunner.CreatedAt = timeToModelTime(now)
-	newRunner.UpdatedAt = timeToModelTime(now)
-
-	// 保存新Runner
-	if err := s.repo.Create(ctx, &newRunner); err != nil {
-		logger.Error(ctx, "创建Fork Runner失败", err)
-		return nil, fmt.Errorf("创建Fork Runner失败: %w", err)
-	}
-
-	// 创建新Runner的版本记录
-	version := &model.RunnerVersion{
-		RunnerID: newRunner.ID,
-		Version:  "1.0.0",
-		Comment:  fmt.Sprintf("从 %s(%d) Fork", sourceRunner.Name, sourceRunner.ID),
-	}
-
-	err = s.repo.SaveVersion(ctx, version)
-	if err != nil {
-		logger.Error(ctx, "保存Fork Runner版本失败", err, zap.Int64("runner_id", newRunner.ID))
-		// 不返回错误，因为Runner已经Fork成功
-	}
-
-	logger.Info(ctx, "Fork Runner成功",
-		zap.Int64("source_id", sourceRunner.ID),
-		zap.Int64("new_id", newRunner.ID),
-		zap.String("title", newRunner.Title))
-
-	return &newRunner, nil
-}
-
 // GetVersionHistory 获取Runner版本历史
 func (s *Runner) GetVersionHistory(ctx context.Context, id int64) ([]model.RunnerVersion, error) {
 	logger.Debug(ctx, "开始获取Runner版本历史", zap.Int64("id", id))
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	goapi "github.com/yunhanshu-net/function-go/pkg/dto/api"
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/pkg/dto"
+	"github.com/yunhanshu-net/function-server/pkg/dto/api"
+	"github.com/yunhanshu-net/function-server/pkg/jsondiff"
+	"github.com/yunhanshu-net/function-server/pkg/textdiff"
+	"github.com/yunhanshu-net/function-server/repo"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// DiffVersions 比较runner两个版本的函数：新增、删除的函数，以及函数信息和参数的变化
+// to为空时和当前版本比较
+func (s *Runner) DiffVersions(ctx context.Context, id int64, from, to string) (*dto.DiffRunnerVersionResp, error) {
+	runner, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("获取Runner失败: %w", err)
+	}
+	if runner == nil {
+		return nil, errors.New("runner不存在")
+	}
+	if to == "" {
+		to = runner.Version
+	}
+	versions, err := s.repo.ListVersions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	oldAPIs, err := apisAtVersion(versions, from)
+	if err != nil {
+		return nil, err
+	}
+	newAPIs, err := apisAtVersion(versions, to)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dto.DiffRunnerVersionResp{From: from, To: to, Added: []*dto.FuncDiff{}, Removed: []*dto.FuncDiff{}, Changed: []*dto.FuncDiff{}}
+	for key, info := range newAPIs {
+		old, ok := oldAPIs[key]
+		if !ok {
+			resp.Added = append(resp.Added, newFuncDiff(info))
+			continue
+		}
+		d := diffFunc(old, info)
+		if err := s.diffCode(ctx, runner, versions, from, to, info, d); err != nil {
+			logger.Warn(ctx, "比较函数源码失败", zap.Error(err), zap.String("router", info.Router))
+		}
+		if len(d.Fields) > 0 || len(d.ParamsIn) > 0 || len(d.ParamsOut) > 0 || d.CodeDiff != "" {
+			resp.Changed = append(resp.Changed, d)
+		}
+	}
+	for key, info := range oldAPIs {
+		if _, ok := newAPIs[key]; !ok {
+			resp.Removed = append(resp.Removed, newFuncDiff(info))
+		}
+	}
+	for _, list := range [][]*dto.FuncDiff{resp.Added, resp.Removed, resp.Changed} {
+		sort.Slice(list, func(i, j int) bool {
+			return apiKey(list[i].Method, list[i].Router) < apiKey(list[j].Method, list[j].Router)
+		})
+	}
+	return resp, nil
+}
+
+func newFuncDiff(info *goapi.Info) *dto.FuncDiff {
+	return &dto.FuncDiff{Router: info.Router, Method: info.Method, Name: info.EnglishName, Title: info.ChineseName}
+}
+
+// diffFunc 比较同一个函数两个版本的函数信息和参数
+func diffFunc(old, cur *goapi.Info) *dto.FuncDiff {
+	d := newFuncDiff(cur)
+	d.Fields = jsondiff.Diff(funcFields(old), funcFields(cur))
+	d.ParamsIn = diffParams(old.ParamsIn, cur.ParamsIn)
+	d.ParamsOut = diffParams(old.ParamsOut, cur.ParamsOut)
+	return d
+}
+
+// diffCode 两个版本都能找到函数源码时比较源码
+func (s *Runner) diffCode(ctx context.Context, runner *model.Runner, versions []model.RunnerVersion, from, to string, info *goapi.Info, d *dto.FuncDiff) error {
+	funcRepo := repo.NewRunnerFuncRepo(s.repo.GetDB())
+	path := FuncPath(runner, info.Router)
+	fn, err := funcRepo.GetByRunnerPath(ctx, runner.ID, info.Method, path)
+	if err != nil {
+		return err
+	}
+	if fn == nil {
+		if fn, err = funcRepo.GetDeletedByRunnerPath(ctx, runner.ID, info.Method, path); err != nil || fn == nil {
+			return err
+		}
+	}
+	sources, err := funcRepo.GetSourceVersions(ctx, fn.ID)
+	if err != nil || len(sources) == 0 {
+		return err
+	}
+	oldCode, err := sourceAtVersion(sources, versions, from)
+	if err != nil {
+		return err
+	}
+	newCode, err := sourceAtVersion(sources, versions, to)
+	if err != nil {
+		return err
+	}
+	if oldCode == "" || newCode == "" {
+		return nil
+	}
+	d.CodeDiff = textdiff.Unified(from, to, oldCode, newCode, 3)
+	return nil
+}
+
+// sourceAtVersion runner处于target版本时函数的源码，和apisAtVersion一样按顺序重放到target最后一次出现的位置
+// 回滚到之前的版本时版本号会重复出现，源码恢复为该版本第一次出现时的源码
+func sourceAtVersion(sources []model.FuncVersion, versions []model.RunnerVersion, target string) (string, error) {
+	last := -1
+	for i, v := range versions {
+		if v.Version == target {
+			last = i
+		}
+	}
+	if last < 0 {
+		return "", fmt.Errorf("版本不存在: %s", target)
+	}
+	saved := make(map[string]*model.FuncVersion, len(sources))
+	for i := range sources {
+		saved[sources[i].Version] = &sources[i]
+	}
+	var current *model.FuncVersion
+	seen := make(map[string]*model.FuncVersion)
+	for _, v := range versions[:last+1] {
+		if prev, ok := seen[v.Version]; ok {
+			current = prev
+			continue
+		}
+		if source, ok := saved[v.Version]; ok {
+			current = source
+		}
+		seen[v.Version] = current
+	}
+	if current == nil {
+		return "", nil
+	}
+	return current.GetSource()
+}
+
+// funcFields 参与比较的函数信息，转换成json解析后的结构方便jsondiff比较
+func funcFields(info *goapi.Info) interface{} {
+	var fields interface{}
+	b, _ := json.Marshal(map[string]interface{}{
+		"chinese_name":  info.ChineseName,
+		"english_name":  info.EnglishName,
+		"api_desc":      info.ApiDesc,
+		"tags":          info.Tags,
+		"async":         info.Async,
+		"timeout":       info.Timeout,
+		"render_type":   info.RenderType,
+		"function_type": info.FunctionType,
+		"callbacks":     info.Callbacks,
+		"use_tables":    info.UseTables,
+	})
+	_ = json.Unmarshal(b, &fields)
+	return fields
+}
+
+// diffParams 按参数code比较参数的增删以及类型、必填的变化
+func diffParams(oldRaw, newRaw interface{}) []*dto.ParamDiff {
+	oldParams, newParams := paramsByCode(oldRaw), paramsByCode(newRaw)
+	var changes []*dto.ParamDiff
+	for code, p := range newParams {
+		old, ok := oldParams[code]
+		if !ok {
+			changes = append(changes, &dto.ParamDiff{Code: code, Type: jsondiff.TypeAdded, NewType: p.ValueType, NewRequired: p.Required})
+			continue
+		}
+		if old.ValueType != p.ValueType || old.Required != p.Required {
+			changes = append(changes, &dto.ParamDiff{Code: code, Type: jsondiff.TypeChanged,
+				OldType: old.ValueType, NewType: p.ValueType, OldRequired: old.Required, NewRequired: p.Required})
+		}
+	}
+	for code, p := range oldParams {
+		if _, ok := newParams[code]; !ok {
+			changes = append(changes, &dto.ParamDiff{Code: code, Type: jsondiff.TypeRemoved, OldType: p.ValueType, OldRequired: p.Required})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Code < changes[j].Code })
+	return changes
+}
+
+// paramsByCode runcher返回的参数是interface{}，按api.Params解析
+func paramsByCode(raw interface{}) map[string]*api.ParamInfo {
+	result := make(map[string]*api.ParamInfo)
+	if raw == nil {
+		return result
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return result
+	}
+	var params api.Params
+	if err := json.Unmarshal(b, &params); err != nil {
+		return result
+	}
+	for _, p := range params.Children {
+		if p != nil {
+			result[p.Code] = p
+		}
+	}
+	return result
+}
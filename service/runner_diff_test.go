@@ -0,0 +1,80 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	goapi "github.com/yunhanshu-net/function-go/pkg/dto/api"
+	"github.com/yunhanshu-net/function-runtime/pkg/dto/coder"
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/pkg/dto/api"
+)
+
+func versionWith(version string, change *coder.ApiChangeInfo) model.RunnerVersion {
+	b, _ := json.Marshal(coder.AddApisResp{Version: version, ApiChangeInfo: change})
+	return model.RunnerVersion{Version: version, MetaData: b}
+}
+
+func TestApisAtVersionAndDiff(t *testing.T) {
+	add := &goapi.Info{Router: "/demo/add", Method: "GET", EnglishName: "add",
+		ParamsIn: &api.Params{Children: []*api.ParamInfo{{Code: "a", ValueType: "number"}}}}
+	addV3 := &goapi.Info{Router: "/demo/add", Method: "GET", EnglishName: "add", Timeout: 10,
+		ParamsIn: &api.Params{Children: []*api.ParamInfo{{Code: "a", ValueType: "string", Required: true}, {Code: "b", ValueType: "number"}}}}
+	sub := &goapi.Info{Router: "/demo/sub", Method: "POST", EnglishName: "sub"}
+	versions := []model.RunnerVersion{
+		{Version: "v1"},
+		versionWith("v2", &coder.ApiChangeInfo{AddApi: []*goapi.Info{add, sub}}),
+		versionWith("v3", &coder.ApiChangeInfo{UpdateApi: []*goapi.Info{addV3}, DelApi: []*goapi.Info{{Router: "/demo/sub"}}}),
+	}
+
+	v2, err := apisAtVersion(versions, "v2")
+	if err != nil || len(v2) != 2 {
+		t.Fatalf("v2 api = %v, %v", v2, err)
+	}
+	v3, _ := apisAtVersion(versions, "v3")
+	if len(v3) != 1 || v3[apiKey("GET", "/demo/add")].Timeout != 10 {
+		t.Fatalf("v3 api异常: %v", v3)
+	}
+	if _, err := apisAtVersion(versions, "v9"); err == nil {
+		t.Fatal("不存在的版本应返回错误")
+	}
+
+	// json回放后参数是map，和runcher返回的数据一致
+	d := diffFunc(v2[apiKey("GET", "/demo/add")], v3[apiKey("GET", "/demo/add")])
+	if d == nil || len(d.Fields) != 1 || d.Fields[0].Path != "timeout" {
+		t.Fatalf("函数信息差异异常: %+v", d)
+	}
+	if len(d.ParamsIn) != 2 || d.ParamsIn[0].Code != "a" || d.ParamsIn[0].NewType != "string" || !d.ParamsIn[0].NewRequired ||
+		d.ParamsIn[1].Code != "b" || d.ParamsIn[1].Type != "added" {
+		t.Fatalf("参数差异异常: %+v", d.ParamsIn)
+	}
+	if d := diffFunc(add, add); len(d.Fields) > 0 || len(d.ParamsIn) > 0 {
+		t.Fatal("相同的函数不应有差异")
+	}
+}
+
+func TestSourceAtVersion(t *testing.T) {
+	v2, v4 := model.FuncVersion{Version: "v2"}, model.FuncVersion{Version: "v4"}
+	if err := v2.SetSource("package demo // v2"); err != nil {
+		t.Fatal(err)
+	}
+	_ = v4.SetSource("package demo // v4")
+	versions := []model.RunnerVersion{{Version: "v1"}, {Version: "v2"}, {Version: "v3"}, {Version: "v4"}}
+	sources := []model.FuncVersion{v2, v4}
+	for target, want := range map[string]string{"v1": "", "v3": "package demo // v2", "v4": "package demo // v4"} {
+		if got, err := sourceAtVersion(sources, versions, target); err != nil || got != want {
+			t.Fatalf("%s 源码 = %q, %v, 期望 %q", target, got, err, want)
+		}
+	}
+
+	// 回滚到v2后v2再次出现，按最后一次出现的位置取源码
+	versions = append(versions, model.RunnerVersion{Version: "v2"}, model.RunnerVersion{Version: "v5"})
+	for target, want := range map[string]string{"v2": "package demo // v2", "v4": "package demo // v4", "v5": "package demo // v2"} {
+		if got, err := sourceAtVersion(sources, versions, target); err != nil || got != want {
+			t.Fatalf("回滚后%s 源码 = %q, %v, 期望 %q", target, got, err, want)
+		}
+	}
+	if _, err := sourceAtVersion(sources, versions, "v9"); err == nil {
+		t.Fatal("不存在的版本应返回错误")
+	}
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/repo"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrRunnerEnv 不支持的环境或者不允许的发布
+var ErrRunnerEnv = errors.New("环境参数错误")
+
+// RunnerEnv Runner发布环境服务
+// 没有设置过版本的环境使用runner当前版本，设置或发布后固定在指定的版本上
+type RunnerEnv struct {
+	repo       *repo.RunnerEnvRepo
+	runnerRepo *repo.RunnerRepo
+}
+
+// NewRunnerEnv 创建Runner发布环境服务
+func NewRunnerEnv(db *gorm.DB) *RunnerEnv {
+	return &RunnerEnv{repo: repo.NewRunnerEnvRepo(db), runnerRepo: repo.NewRunnerRepo(db)}
+}
+
+// SplitRunnerEnv 拆分执行地址中的 runner@env
+func SplitRunnerEnv(runner string) (name string, env string) {
+	if i := strings.LastIndex(runner, "@"); i >= 0 {
+		return runner[:i], runner[i+1:]
+	}
+	return runner, ""
+}
+
+// List 获取Runner的全部环境，按发布顺序排列
+func (s *RunnerEnv) List(ctx context.Context, runnerID int64) ([]*model.RunnerEnv, error) {
+	runner, err := s.getRunner(ctx, runnerID)
+	if err != nil {
+		return nil, err
+	}
+	saved, err := s.repo.List(ctx, runnerID)
+	if err != nil {
+		return nil, err
+	}
+	envs := make([]*model.RunnerEnv, 0, len(model.RunnerEnvs))
+	for _, name := range model.RunnerEnvs {
+		env := &model.RunnerEnv{RunnerID: runnerID, Name: name}
+		for _, e := range saved {
+			if e.Name == name {
+				env = e
+			}
+		}
+		env.CurrentVersion = env.Version
+		if env.CurrentVersion == "" {
+			env.CurrentVersion = runner.Version
+		}
+		envs = append(envs, env)
+	}
+	return envs, nil
+}
+
+// SetVersion 把环境指向runner的某个版本
+func (s *RunnerEnv) SetVersion(ctx context.Context, runnerID int64, name, version, operator string) (*model.RunnerEnv, error) {
+	if _, err := s.getRunner(ctx, runnerID); err != nil {
+		return nil, err
+	}
+	if err := s.checkVersion(ctx, runnerID, version); err != nil {
+		return nil, err
+	}
+	env, err := s.get(ctx, runnerID, name)
+	if err != nil {
+		return nil, err
+	}
+	env.Version = version
+	env.PromotedFrom = ""
+	env.UpdatedBy = operator
+	if err := s.repo.Save(ctx, env); err != nil {
+		return nil, fmt.Errorf("保存环境失败: %w", err)
+	}
+	env.CurrentVersion = version
+	logger.Info(ctx, "设置Runner环境版本", zap.Int64("runner_id", runnerID), zap.String("env", name), zap.String("version", version))
+	return env, nil
+}
+
+// Promote 把from环境当前的版本发布到后面的to环境
+func (s *RunnerEnv) Promote(ctx context.Context, runnerID int64, from, to, operator string) (*model.RunnerEnv, error) {
+	fromIdx, toIdx := model.RunnerEnvIndex(from), model.RunnerEnvIndex(to)
+	if fromIdx < 0 || toIdx < 0 {
+		return nil, fmt.Errorf("%w: 不支持的环境 %s -> %s", ErrRunnerEnv, from, to)
+	}
+	if fromIdx >= toIdx {
+		return nil, fmt.Errorf("%w: 只能按 %s 的顺序发布", ErrRunnerEnv, strings.Join(model.RunnerEnvs, " -> "))
+	}
+	runner, err := s.getRunner(ctx, runnerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRunnerDeployable(runner); err != nil {
+		return nil, err
+	}
+	source, err := s.get(ctx, runnerID, from)
+	if err != nil {
+		return nil, err
+	}
+	version := source.Version
+	if version == "" {
+		version = runner.Version
+	}
+	target, err := s.get(ctx, runnerID, to)
+	if err != nil {
+		return nil, err
+	}
+	target.Version = version
+	target.PromotedFrom = from
+	target.UpdatedBy = operator
+	if err := s.repo.Save(ctx, target); err != nil {
+		return nil, fmt.Errorf("保存环境失败: %w", err)
+	}
+	target.CurrentVersion = version
+	logger.Info(ctx, "发布Runner环境", zap.Int64("runner_id", runnerID), zap.String("from", from),
+		zap.String("to", to), zap.String("version", version))
+	return target, nil
+}
+
+// SetConfig 设置环境变量，执行函数时传给runner
+func (s *RunnerEnv) SetConfig(ctx context.Context, runnerID int64, name string, vars map[string]string, operator string) (*model.RunnerEnv, error) {
+	runner, err := s.getRunner(ctx, runnerID)
+	if err != nil {
+		return nil, err
+	}
+	env, err := s.get(ctx, runnerID, name)
+	if err != nil {
+		return nil, err
+	}
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	env.Config, _ = json.Marshal(vars)
+	env.UpdatedBy = operator
+	if err := s.repo.Save(ctx, env); err != nil {
+		return nil, fmt.Errorf("保存环境变量失败: %w", err)
+	}
+	env.CurrentVersion = env.Version
+	if env.CurrentVersion == "" {
+		env.CurrentVersion = runner.Version
+	}
+	return env, nil
+}
+
+// Resolve 获取执行函数时环境使用的版本和环境变量
+func (s *RunnerEnv) Resolve(ctx context.Context, runner *model.Runner, name string) (string, map[string]string, error) {
+	env, err := s.get(ctx, runner.ID, name)
+	if err != nil {
+		return "", nil, err
+	}
+	vars, err := env.ConfigVars()
+	if err != nil {
+		return "", nil, fmt.Errorf("解析环境变量失败: %w", err)
+	}
+	version := env.Version
+	if version == "" {
+		version = runner.Version
+	}
+	return version, vars, nil
+}
+
+// get 获取环境，没有保存过时返回未保存的默认环境
+func (s *RunnerEnv) get(ctx context.Context, runnerID int64, name string) (*model.RunnerEnv, error) {
+	if model.RunnerEnvIndex(name) < 0 {
+		return nil, fmt.Errorf("%w: 不支持的环境 %s，可选 %s", ErrRunnerEnv, name, strings.Join(model.RunnerEnvs, ","))
+	}
+	env, err := s.repo.Get(ctx, runnerID, name)
+	if err != nil {
+		return nil, err
+	}
+	if env == nil {
+		env = &model.RunnerEnv{RunnerID: runnerID, Name: name}
+	}
+	return env, nil
+}
+
+func (s *RunnerEnv) getRunner(ctx context.Context, runnerID int64) (*model.Runner, error) {
+	runner, err := s.runnerRepo.Get(ctx, runnerID)
+	if err != nil {
+		return nil, fmt.Errorf("获取Runner失败: %w", err)
+	}
+	if runner == nil {
+		return nil, errors.New("runner不存在")
+	}
+	return runner, nil
+}
+
+// checkVersion 版本必须是runner已有的版本
+func (s *RunnerEnv) checkVersion(ctx context.Context, runnerID int64, version string) error {
+	versions, err := s.runnerRepo.ListVersions(ctx, runnerID)
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		if v.Version == version {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: 版本不存在 %s", ErrRunnerEnv, version)
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/yunhanshu-net/function-server/pkg/dto"
+	"github.com/yunhanshu-net/function-server/repo"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Export 导出runner的package和函数，函数带上保存的最新源码
+func (s *Runner) Export(ctx context.Context, id int64) (*dto.ExportRunnerResp, error) {
+	runner, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("获取Runner失败: %w", err)
+	}
+	if runner == nil {
+		return nil, errors.New("runner不存在")
+	}
+	treeRepo := repo.NewServiceTreeRepo(s.repo.GetDB())
+	funcRepo := repo.NewRunnerFuncRepo(s.repo.GetDB())
+
+	packages, err := treeRepo.GetPackagesByRunner(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	funcs, err := funcRepo.GetByRunner(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dto.ExportRunnerResp{
+		User:        runner.User,
+		Name:        runner.Name,
+		Title:       runner.Title,
+		Description: runner.Description,
+		Version:     runner.Version,
+		Packages:    make([]*dto.ExportPackage, 0, len(packages)),
+		Functions:   make([]*dto.ExportFunc, 0, len(funcs)),
+	}
+	paths := make(map[int64]string, len(packages))
+	for _, pkg := range packages {
+		paths[pkg.ID] = pkg.GetPackagePath()
+		resp.Packages = append(resp.Packages, &dto.ExportPackage{
+			Path:        paths[pkg.ID],
+			Name:        pkg.Name,
+			Title:       pkg.Title,
+			Description: pkg.Description,
+		})
+	}
+	for i := range funcs {
+		fn := &funcs[i]
+		if err := loadFuncCode(ctx, funcRepo, fn); err != nil {
+			return nil, fmt.Errorf("读取函数%s的源码失败: %w", fn.Name, err)
+		}
+		if fn.Code == "" {
+			logger.Warn(ctx, "导出的函数没有保存源码", zap.Int64("runner_id", id), zap.Int64("func_id", fn.ID))
+		}
+		resp.Functions = append(resp.Functions, &dto.ExportFunc{
+			Package:     paths[fn.TreeID],
+			Name:        fn.Name,
+			Title:       fn.Title,
+			Description: fn.Description,
+			Method:      fn.Method,
+			Router:      strings.TrimPrefix(fn.Path, "/"+runner.User+"/"+runner.Name),
+			Code:        fn.Code,
+		})
+	}
+	return resp, nil
+}
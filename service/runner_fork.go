@@ -0,0 +1,352 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/yunhanshu-net/function-runtime/pkg/dto/coder"
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/repo"
+	"github.com/yunhanshu-net/pkg/dto/runnerproject"
+	"github.com/yunhanshu-net/pkg/logger"
+	"github.com/yunhanshu-net/pkg/x/jsonx"
+	"go.uber.org/zap"
+)
+
+// ForkResult fork任务的结果
+type ForkResult struct {
+	RunnerID  int64  `json:"runner_id"`
+	Version   string `json:"version"`
+	Packages  int    `json:"packages"`
+	Functions int    `json:"functions"`
+}
+
+// Fork 完整复制Runner：创建项目，复制服务树的package和函数，并在runcher上重新部署所有函数的代码
+// 复制在后台任务中执行，立即返回任务，name为空时使用源runner的名称
+func (s *Runner) Fork(ctx context.Context, id int64, name, title string, operator string) (*model.Job, error) {
+	source, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("获取源Runner失败: %w", err)
+	}
+	if source == nil {
+		return nil, errors.New("源Runner不存在")
+	}
+	if _, err := requireRuncher(); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = source.Name
+	}
+	if title == "" {
+		title = source.Title
+	}
+	exist, err := s.repo.GetByUserAndName(ctx, operator, name)
+	if err != nil {
+		return nil, fmt.Errorf("检查名称失败: %w", err)
+	}
+	if exist != nil {
+		return nil, fmt.Errorf("名称%s已存在，请指定新的名称", name)
+	}
+
+	job := &model.Job{Type: model.JobTypeRunnerFork, SourceID: id, Base: model.Base{CreatedBy: operator}}
+	return NewJob(s.repo.GetDB()).Start(ctx, job, func(ctx context.Context, progress *JobProgress) (interface{}, error) {
+		return s.deepFork(ctx, progress, source, name, title, operator)
+	})
+}
+
+// deepFork 执行fork，创建runner之后的步骤失败时删除runcher上的项目以及新runner的服务树和函数，任务的target_id指向被删除的runner
+func (s *Runner) deepFork(ctx context.Context, progress *JobProgress, source *model.Runner, name, title, operator string) (result *ForkResult, err error) {
+	treeRepo := repo.NewServiceTreeRepo(s.repo.GetDB())
+	funcRepo := repo.NewRunnerFuncRepo(s.repo.GetDB())
+
+	progress.Step("读取源Runner")
+	packages, err := treeRepo.GetPackagesByRunner(ctx, source.ID)
+	if err != nil {
+		return nil, err
+	}
+	funcs, err := funcRepo.GetByRunner(ctx, source.ID)
+	if err != nil {
+		return nil, err
+	}
+	// 在创建项目之前检查源码，避免复制到一半才发现无法部署
+	for i := range funcs {
+		fn := &funcs[i]
+		if err := loadFuncCode(ctx, funcRepo, fn); err != nil {
+			return nil, fmt.Errorf("读取函数%s的源码失败: %w", fn.Name, err)
+		}
+		if fn.Code == "" {
+			return nil, fmt.Errorf("函数%s没有保存源码，无法fork", fn.Name)
+		}
+	}
+	funcNodes, err := treeRepo.GetFunctionNodesByRunner(ctx, source.ID)
+	if err != nil {
+		return nil, err
+	}
+	sourceNodes := make(map[int64]*model.ServiceTree, len(funcNodes))
+	for _, node := range funcNodes {
+		sourceNodes[node.RefID] = node
+	}
+	progress.SetTotal(1 + len(packages) + len(funcs))
+
+	progress.Step("创建项目")
+	forked := &model.Runner{
+		Title:           title,
+		Name:            name,
+		Description:     source.Description,
+		Language:        source.Language,
+		Tags:            source.Tags,
+		User:            operator,
+		ForkFromID:      &source.ID,
+		ForkFromUser:    source.User,
+		ForkFromVersion: source.Version,
+		Base:            model.Base{CreatedBy: operator, UpdatedBy: operator},
+	}
+	if err := s.Create(ctx, forked); err != nil {
+		return nil, fmt.Errorf("创建Runner失败: %w", err)
+	}
+	progress.SetTarget(forked.ID)
+	defer func() {
+		if err != nil {
+			s.cleanupFork(ctx, forked, operator)
+		}
+	}()
+	if forked.Version == "" {
+		return nil, errors.New("runcher创建项目失败")
+	}
+	if err := treeRepo.Update(ctx, forked.TreeID, &model.ServiceTree{ForkFromID: &source.TreeID}); err != nil {
+		return nil, err
+	}
+	root, err := treeRepo.Get(ctx, forked.TreeID)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, errors.New("新Runner的服务树不存在")
+	}
+	progress.Advance()
+
+	nodeCtx, runcherService, err := runcherForRunner(ctx, forked)
+	if err != nil {
+		return nil, err
+	}
+	version := forked.Version
+	trees := map[int64]*model.ServiceTree{source.TreeID: root}
+	for _, pkg := range packages {
+		progress.Step("复制目录 " + pkg.FullNamePath)
+		parent := trees[pkg.ParentID]
+		if parent == nil {
+			return nil, fmt.Errorf("目录%s的上级目录不存在", pkg.FullNamePath)
+		}
+		node := &model.ServiceTree{
+			Title:       pkg.Title,
+			Name:        pkg.Name,
+			Description: pkg.Description,
+			Tags:        pkg.Tags,
+			Sort:        pkg.Sort,
+			Type:        model.ServiceTreeTypePackage,
+			ForkFromID:  &pkg.ID,
+			Base:        model.Base{CreatedBy: operator, UpdatedBy: operator},
+		}
+		if err := createForkNode(ctx, treeRepo, parent, node); err != nil {
+			return nil, fmt.Errorf("复制目录%s失败: %w", pkg.FullNamePath, err)
+		}
+		rp, err := runnerproject.NewRunner(forked.User, forked.Name, version)
+		if err != nil {
+			return nil, err
+		}
+		rp.Language = "go"
+		rsp, err := runcherService.AddBizPackage2(nodeCtx, &coder.BizPackage{
+			Runner:         rp,
+			AbsPackagePath: node.GetPackagePath(),
+			Language:       forked.Language,
+			EnName:         node.Name,
+			CnName:         node.Title,
+			Desc:           node.Description,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("添加package %s 失败: %w", node.FullNamePath, err)
+		}
+		if rsp.Version != "" {
+			version = rsp.Version
+		}
+		trees[pkg.ID] = node
+		progress.Advance()
+	}
+
+	// 按目录分批部署，同一目录下按函数名匹配runcher返回的api信息
+	var treeIDs []int64
+	byTree := make(map[int64][]*model.RunnerFunc)
+	for i := range funcs {
+		fn := &funcs[i]
+		if _, ok := byTree[fn.TreeID]; !ok {
+			treeIDs = append(treeIDs, fn.TreeID)
+		}
+		byTree[fn.TreeID] = append(byTree[fn.TreeID], fn)
+	}
+	var versions []*model.RunnerVersion
+	for _, treeID := range treeIDs {
+		tree := trees[treeID]
+		if tree == nil {
+			return nil, fmt.Errorf("函数%s所在的目录不存在", byTree[treeID][0].Name)
+		}
+		progress.Step("部署函数 " + tree.FullNamePath)
+		rp, err := runnerproject.NewRunner(forked.User, forked.Name, version)
+		if err != nil {
+			return nil, err
+		}
+		rp.Language = "go"
+		comment := fmt.Sprintf("从 %s/%s(%s) fork", source.User, source.Name, source.Version)
+		req := &coder.AddApisReq{Runner: rp, Msg: comment}
+		for _, fn := range byTree[treeID] {
+			req.CodeApis = append(req.CodeApis, &coder.CodeApi{
+				EnName:         fn.Name,
+				CnName:         fn.Title,
+				Desc:           fn.Description,
+				Language:       "go",
+				Code:           fn.Code,
+				Package:        tree.Name,
+				AbsPackagePath: tree.GetPackagePath(),
+			})
+		}
+		rsp, err := runcherService.AddAPI2(nodeCtx, req)
+		if err != nil {
+			return nil, fmt.Errorf("部署目录%s下的函数失败: %w", tree.FullNamePath, err)
+		}
+		if rsp.Version != "" {
+			version = rsp.Version
+		}
+		for _, fn := range byTree[treeID] {
+			if err := s.forkFunc(ctx, treeRepo, funcRepo, forked, tree, fn, sourceNodes[fn.ID], rsp, comment); err != nil {
+				return nil, err
+			}
+			progress.Advance()
+		}
+		versions = append(versions, &model.RunnerVersion{
+			Base:     model.Base{CreatedBy: operator, UpdatedBy: operator},
+			RunnerID: forked.ID,
+			Version:  rsp.Version,
+			Hash:     rsp.Hash,
+			Desc:     comment,
+			Log:      rsp.ApiChangeInfo.GetChangeLog(),
+			MetaData: json.RawMessage(jsonx.String(rsp)),
+		})
+	}
+
+	progress.Step("版本变更回调")
+	callback, err := onVersionChange(ctx, forked, forked.Version, version)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) > 0 {
+		callback.Apply(versions[len(versions)-1])
+	}
+	for _, v := range versions {
+		if err := s.repo.SaveVersion(ctx, v); err != nil {
+			return nil, fmt.Errorf("保存版本失败: %w", err)
+		}
+	}
+	update := &model.Runner{Version: version}
+	update.UpdatedBy = operator
+	if err := s.repo.Update(ctx, forked.ID, update); err != nil {
+		return nil, fmt.Errorf("更新版本失败: %w", err)
+	}
+
+	logger.Info(ctx, "Fork Runner成功", zap.Int64("source_id", source.ID), zap.Int64("new_id", forked.ID),
+		zap.Int("packages", len(packages)), zap.Int("functions", len(funcs)), zap.String("version", version))
+	return &ForkResult{RunnerID: forked.ID, Version: version, Packages: len(packages), Functions: len(funcs)}, nil
+}
+
+// cleanupFork fork失败时删除已经创建的项目和记录，失败只记录日志
+func (s *Runner) cleanupFork(ctx context.Context, forked *model.Runner, operator string) {
+	logger.Warn(ctx, "Fork失败，清理已创建的Runner", zap.Int64("id", forked.ID), zap.String("name", forked.Name))
+	nodeCtx, runcherService, err := runcherForRunner(ctx, forked)
+	if err == nil {
+		_, err = runcherService.DeleteProject(nodeCtx, &coder.DeleteProjectReq{User: forked.User, Runner: forked.Name})
+	}
+	if err != nil {
+		logger.Warn(ctx, "删除Fork失败的项目失败", zap.Error(err), zap.Int64("id", forked.ID))
+	}
+	if err := s.repo.DeleteWithChildren(ctx, forked.ID, operator); err != nil {
+		logger.Error(ctx, "删除Fork失败的Runner失败", err, zap.Int64("id", forked.ID))
+		return
+	}
+	s.nodes.Release(ctx, forked.RuncherID)
+}
+
+// forkFunc 用runcher返回的api信息创建fork出的函数、函数节点和函数版本
+func (s *Runner) forkFunc(ctx context.Context, treeRepo *repo.ServiceTreeRepo, funcRepo *repo.RunnerFuncRepo, forked *model.Runner,
+	tree *model.ServiceTree, fn *model.RunnerFunc, sourceNode *model.ServiceTree, rsp *coder.AddApisResp, comment string) error {
+	info := changedAPI(rsp.ApiChangeInfo, fn)
+	if info == nil {
+		return fmt.Errorf("runcher没有返回函数%s的信息", fn.Name)
+	}
+	fc := &model.RunnerFunc{
+		AutoRun:         fn.AutoRun,
+		ContractMode:    fn.ContractMode,
+		RedactFields:    fn.RedactFields,
+		PayloadStore:    fn.PayloadStore,
+		Mock:            fn.Mock,
+		Disabled:        fn.Disabled,
+		User:            forked.User,
+		TreeID:          tree.ID,
+		RunnerID:        forked.ID,
+		ForkFromID:      &fn.ID,
+		ForkFromUser:    fn.User,
+		ForkFromVersion: forked.ForkFromVersion,
+		Base:            model.Base{CreatedBy: forked.CreatedBy, UpdatedBy: forked.CreatedBy},
+	}
+	fillRunnerFunc(fc, forked, info)
+	if err := funcRepo.Create(ctx, fc); err != nil {
+		return fmt.Errorf("创建函数%s失败: %w", fn.Name, err)
+	}
+	node := &model.ServiceTree{
+		Type:   model.ServiceTreeTypeFunction,
+		Name:   fc.Name,
+		Title:  fc.Title,
+		RefID:  fc.ID,
+		Method: fc.Method,
+		Base:   model.Base{CreatedBy: forked.CreatedBy, UpdatedBy: forked.CreatedBy},
+	}
+	if sourceNode != nil {
+		node.ForkFromID = &sourceNode.ID
+	}
+	if err := createForkNode(ctx, treeRepo, tree, node); err != nil {
+		return fmt.Errorf("创建函数%s的服务树节点失败: %w", fn.Name, err)
+	}
+	fv := &model.FuncVersion{
+		Base:     model.Base{CreatedBy: forked.CreatedBy, UpdatedBy: forked.CreatedBy},
+		RunnerID: forked.ID,
+		FuncID:   fc.ID,
+		Version:  rsp.Version,
+		Comment:  comment,
+		MetaData: json.RawMessage(jsonx.String(info)),
+		Hash:     rsp.Hash,
+	}
+	if err := fv.SetSource(fn.Code); err != nil {
+		return fmt.Errorf("压缩函数%s的源码失败: %w", fn.Name, err)
+	}
+	return funcRepo.SaveVersion(ctx, fv)
+}
+
+// createForkNode 在parent下创建服务树节点，并设置路径、层级和上级的子节点数量
+func createForkNode(ctx context.Context, treeRepo *repo.ServiceTreeRepo, parent *model.ServiceTree, node *model.ServiceTree) error {
+	node.ParentID = parent.ID
+	node.RunnerID = parent.RunnerID
+	node.User = parent.User
+	if err := treeRepo.Create(ctx, node); err != nil {
+		return err
+	}
+	node.FullIDPath = parent.FullIDPath + fmt.Sprintf("%d", node.ID) + "/"
+	node.FullNamePath = parent.FullNamePath + node.Name + "/"
+	node.Level = parent.Level + 1
+	if err := treeRepo.Update(ctx, node.ID, &model.ServiceTree{
+		FullIDPath:   node.FullIDPath,
+		FullNamePath: node.FullNamePath,
+		Level:        node.Level,
+	}); err != nil {
+		return err
+	}
+	return treeRepo.UpdateChildrenCount(ctx, parent.ID, 1)
+}
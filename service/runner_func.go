@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
+	goapi "github.com/yunhanshu-net/function-go/pkg/dto/api"
 	"github.com/yunhanshu-net/function-runtime/pkg/dto/coder"
 	"github.com/yunhanshu-net/function-server/pkg/db"
 	"github.com/yunhanshu-net/pkg/dto/runnerproject"
@@ -12,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/pkg/contract"
 	"github.com/yunhanshu-net/function-server/pkg/dto"
 	"github.com/yunhanshu-net/function-server/repo"
 	"github.com/yunhanshu-net/pkg/logger"
@@ -67,6 +69,9 @@ func (s *RunnerFunc) Create(ctx context.Context, runnerFunc *model.RunnerFunc) e
 	if gotRunner == nil {
 		return errors.New("关联的Runner不存在")
 	}
+	if err := checkRunnerDeployable(gotRunner); err != nil {
+		return err
+	}
 
 	packageTree, err := s.serviceTreeRepo.Get(ctx, runnerFunc.TreeID)
 	if err != nil {
@@ -93,7 +98,10 @@ func (s *RunnerFunc) Create(ctx context.Context, runnerFunc *model.RunnerFunc) e
 		return err
 	}
 	runner.Language = "go"
-	service := GetRuncherService()
+	nodeCtx, service, err := runcherForRunner(ctx, gotRunner)
+	if err != nil {
+		return err
+	}
 	r := &coder.AddApisReq{
 		Runner: runner,
 		Msg:    runnerFunc.Description,
@@ -109,37 +117,22 @@ func (s *RunnerFunc) Create(ctx context.Context, runnerFunc *model.RunnerFunc) e
 			},
 		},
 	}
-	rsp, err := service.AddAPI2(ctx, r)
+	rsp, err := service.AddAPI2(nodeCtx, r)
 	if err != nil {
 		logger.Error(ctx, "添加api失败", err, zap.Int64("func_id", runnerFunc.ID))
 		return err
 	}
 	logger.Infof(ctx, "rsp:%+v", rsp)
+	callback, err := onVersionChange(ctx, gotRunner, gotRunner.Version, rsp.Version)
+	if err != nil {
+		return err
+	}
 
 	addAPIs := rsp.ApiChangeInfo.AddApi
 	for _, addAPI := range addAPIs {
 
 		fc := *runnerFunc
-
-		fc.Async = addAPI.Async
-		fc.Timeout = addAPI.Timeout
-		fc.Description = addAPI.ApiDesc
-		fc.RenderType = addAPI.RenderType
-		fc.FunctionType = addAPI.FunctionType
-		fc.Name = addAPI.EnglishName
-		fc.Title = addAPI.ChineseName
-		fc.Tags = strings.Join(addAPI.Tags, ",")
-		fc.Request = json.RawMessage(jsonx.String(addAPI.ParamsIn))
-		path := "/" + gotRunner.User + "/" + gotRunner.Name + "/" + strings.Trim(addAPI.Router, "/") + "/"
-		fc.Response = json.RawMessage(jsonx.String(addAPI.ParamsOut))
-		fc.Path = path
-		fc.Method = addAPI.Method
-		fc.Callbacks = strings.Join(addAPI.Callbacks, ",")
-		fc.UseTables = strings.Join(addAPI.UseTables, ",")
-		fc.CreateTables = strings.Join(addAPI.CreateTables, ",")
-		if addAPI.OperateTables != nil {
-			fc.OperateTables = json.RawMessage(jsonx.String(addAPI.OperateTables))
-		}
+		fillRunnerFunc(&fc, gotRunner, addAPI)
 		// 设置默认值
 		if fc.User == "" {
 			fc.User = "admin"
@@ -165,14 +158,19 @@ func (s *RunnerFunc) Create(ctx context.Context, runnerFunc *model.RunnerFunc) e
 		if err != nil {
 			return err
 		}
+		fv := &model.FuncVersion{
+			Base:     model.Base{CreatedBy: fc.CreatedBy, UpdatedBy: fc.UpdatedBy},
+			RunnerID: fc.RunnerID,
+			FuncID:   fc.ID,
+			Version:  rsp.Version,
+			MetaData: json.RawMessage(jsonx.String(addAPI)),
+			Hash:     rsp.Hash,
+		}
+		if err := fv.SetSource(runnerFunc.Code); err != nil {
+			logger.Warn(ctx, "压缩函数源码失败", zap.Error(err), zap.Int64("func_id", fc.ID))
+		}
 		go func() {
-			s.runnerFuncRepo.SaveVersion(ctx, &model.FuncVersion{
-				Base:     model.Base{CreatedBy: fc.CreatedBy, UpdatedBy: fc.UpdatedBy},
-				FuncID:   fc.ID,
-				Version:  rsp.Version,
-				MetaData: json.RawMessage(jsonx.String(addAPI)),
-				Hash:     rsp.Hash,
-			})
+			s.runnerFuncRepo.SaveVersion(ctx, fv)
 		}()
 
 	}
@@ -182,7 +180,7 @@ func (s *RunnerFunc) Create(ctx context.Context, runnerFunc *model.RunnerFunc) e
 		if err != nil {
 			logger.Error(ctx, "更新版本失败", err, zap.Int64("func_id", runnerFunc.ID))
 		}
-		s.runnerRepo.CreateRunnerVersion(ctx, &model.RunnerVersion{
+		version := &model.RunnerVersion{
 			Base:     model.Base{CreatedBy: runnerFunc.CreatedBy, UpdatedBy: runnerFunc.UpdatedBy},
 			Desc:     runnerFunc.Description,
 			Log:      rsp.ApiChangeInfo.GetChangeLog(),
@@ -190,7 +188,9 @@ func (s *RunnerFunc) Create(ctx context.Context, runnerFunc *model.RunnerFunc) e
 			RunnerID: runnerFunc.RunnerID,
 			MetaData: json.RawMessage(jsonx.String(rsp)),
 			Hash:     rsp.Hash,
-		})
+		}
+		callback.Apply(version)
+		s.runnerRepo.CreateRunnerVersion(ctx, version)
 
 	}()
 
@@ -198,11 +198,63 @@ func (s *RunnerFunc) Create(ctx context.Context, runnerFunc *model.RunnerFunc) e
 	return nil
 }
 
+// fillRunnerFunc 用runcher返回的api信息填充函数
+func fillRunnerFunc(fc *model.RunnerFunc, runner *model.Runner, info *goapi.Info) {
+	fc.Async = info.Async
+	fc.Timeout = info.Timeout
+	fc.Description = info.ApiDesc
+	fc.RenderType = info.RenderType
+	fc.FunctionType = info.FunctionType
+	fc.Name = info.EnglishName
+	fc.Title = info.ChineseName
+	fc.Tags = strings.Join(info.Tags, ",")
+	fc.Request = json.RawMessage(jsonx.String(info.ParamsIn))
+	fc.Response = json.RawMessage(jsonx.String(info.ParamsOut))
+	fc.Path = FuncPath(runner, info.Router)
+	fc.Method = info.Method
+	fc.Callbacks = strings.Join(info.Callbacks, ",")
+	fc.UseTables = strings.Join(info.UseTables, ",")
+	fc.CreateTables = strings.Join(info.CreateTables, ",")
+	if info.OperateTables != nil {
+		fc.OperateTables = json.RawMessage(jsonx.String(info.OperateTables))
+	}
+}
+
 // Get 获取函数详情
 func (s *RunnerFunc) Get(ctx context.Context, id int64) (*model.RunnerFunc, error) {
 	logger.Debug(ctx, "开始获取函数详情", zap.Int64("id", id))
 	return s.runnerFuncRepo.Get(ctx, id)
 }
+
+// Source 获取函数源码，version为空时获取当前版本
+func (s *RunnerFunc) Source(ctx context.Context, id int64, version string) (*model.FuncVersion, string, error) {
+	v, err := s.runnerFuncRepo.GetSourceVersion(ctx, id, version)
+	if err != nil {
+		return nil, "", err
+	}
+	if v == nil {
+		return nil, "", nil
+	}
+	code, err := v.GetSource()
+	if err != nil {
+		return nil, "", fmt.Errorf("解压函数源码失败: %w", err)
+	}
+	return v, code, nil
+}
+
+// loadFuncCode 从最新的函数版本中读取源码，没有保存源码的函数Code为空
+func loadFuncCode(ctx context.Context, funcRepo *repo.RunnerFuncRepo, fn *model.RunnerFunc) error {
+	if fn.Code != "" {
+		return nil
+	}
+	v, err := funcRepo.GetSourceVersion(ctx, fn.ID, "")
+	if err != nil || v == nil {
+		return err
+	}
+	fn.Code, err = v.GetSource()
+	return err
+}
+
 func (s *RunnerFunc) Versions(ctx context.Context, id int64) ([]model.FuncVersion, error) {
 	versions, err := s.runnerFuncRepo.GetVersions(ctx, id)
 	if err != nil {
@@ -211,6 +263,16 @@ func (s *RunnerFunc) Versions(ctx context.Context, id int64) ([]model.FuncVersio
 	return versions, nil
 }
 
+// GetByRunnerRouter 根据Runner和请求路由获取函数
+func (s *RunnerFunc) GetByRunnerRouter(ctx context.Context, runner *model.Runner, method string, router string) (*model.RunnerFunc, error) {
+	return s.runnerFuncRepo.GetByRunnerPath(ctx, runner.ID, method, FuncPath(runner, router))
+}
+
+// FuncPath 生成函数在runner_func表中保存的path，格式：/user/runner/router/
+func FuncPath(runner *model.Runner, router string) string {
+	return "/" + runner.User + "/" + runner.Name + "/" + strings.Trim(router, "/") + "/"
+}
+
 // GetByTreeId GetByTreeId
 func (s *RunnerFunc) GetByTreeId(ctx context.Context, id int64) (*model.RunnerFunc, error) {
 	get, err := s.serviceTreeRepo.Get(ctx, id)
@@ -260,6 +322,17 @@ func (s *RunnerFunc) Update(ctx context.Context, id int64, updateData *model.Run
 		}
 	}
 
+	// 校验契约校验模式
+	if updateData.ContractMode != "" && contract.NormalizeMode(updateData.ContractMode) != updateData.ContractMode {
+		return fmt.Errorf("不支持的契约校验模式: %s", updateData.ContractMode)
+	}
+	if updateData.PayloadStore != "" && updateData.PayloadStore != model.PayloadStoreFull && updateData.PayloadStore != model.PayloadStoreHash {
+		return fmt.Errorf("不支持的执行记录保存方式: %s", updateData.PayloadStore)
+	}
+	if updateData.Mock != "" && updateData.Mock != model.MockOn && updateData.Mock != model.MockOff {
+		return fmt.Errorf("不支持的模拟模式: %s", updateData.Mock)
+	}
+
 	// 如果要更新服务树，需要检查服务树是否存在
 	if updateData.TreeID > 0 && updateData.TreeID != existingFunc.TreeID {
 		treeExists, err := s.runnerFuncRepo.CheckServiceTreeExists(ctx, updateData.TreeID)
@@ -295,10 +368,19 @@ func (s *RunnerFunc) Delete(ctx context.Context, id int64, operator string) erro
 	if runnerFunc == nil {
 		return errors.New("函数不存在")
 	}
+	if err := loadFuncCode(ctx, s.runnerFuncRepo, runnerFunc); err != nil {
+		logger.Warn(ctx, "读取函数源码失败", zap.Error(err), zap.Int64("id", id))
+	}
 	gotRunner, err := s.runnerRepo.Get(ctx, runnerFunc.RunnerID)
 	if err != nil {
 		return err
 	}
+	if gotRunner == nil {
+		return errors.New("关联的Runner不存在")
+	}
+	if err := checkRunnerDeployable(gotRunner); err != nil {
+		return err
+	}
 	packageTree, err := s.serviceTreeRepo.Get(ctx, runnerFunc.TreeID)
 	if err != nil {
 		logger.Error(ctx, "检查服务树存在性失败", err, zap.Int64("tree_id", runnerFunc.TreeID))
@@ -310,7 +392,10 @@ func (s *RunnerFunc) Delete(ctx context.Context, id int64, operator string) erro
 		return err
 	}
 	runner.Language = "go"
-	service := GetRuncherService()
+	nodeCtx, service, err := runcherForRunner(ctx, gotRunner)
+	if err != nil {
+		return err
+	}
 	r := &coder.DeleteAPIsReq{
 		Runner: runner,
 		Msg:    runnerFunc.Description,
@@ -326,10 +411,38 @@ func (s *RunnerFunc) Delete(ctx context.Context, id int64, operator string) erro
 			},
 		},
 	}
-	_, err = service.DeleteAPIs(ctx, r)
+	rsp, err := service.DeleteAPIs(nodeCtx, r)
 	if err != nil {
 		return err
 	}
+	callback, err := onVersionChange(ctx, gotRunner, gotRunner.Version, rsp.Version)
+	if err != nil {
+		return err
+	}
+	// 记录版本，回滚时按版本记录重放函数的变更
+	if err := s.runnerRepo.Update(ctx, gotRunner.ID, &model.Runner{Version: rsp.Version}); err != nil {
+		logger.Error(ctx, "更新版本失败", err, zap.Int64("func_id", id))
+	}
+	go func() {
+		version := &model.RunnerVersion{
+			Base:     model.Base{CreatedBy: operator, UpdatedBy: operator},
+			Desc:     gotRunner.Description,
+			Log:      rsp.GetDelApisDesc(),
+			Version:  rsp.Version,
+			RunnerID: gotRunner.ID,
+			MetaData: json.RawMessage(jsonx.String(coder.AddApisResp{
+				Hash:    rsp.Hash,
+				Version: rsp.Version,
+				ApiChangeInfo: &coder.ApiChangeInfo{
+					CurrentVersion: rsp.Version,
+					DelApi:         rsp.DelApis,
+				},
+			})),
+			Hash: rsp.Hash,
+		}
+		callback.Apply(version)
+		s.runnerRepo.CreateRunnerVersion(ctx, version)
+	}()
 	//删除对应tree和对应函数
 
 	// 设置删除者
@@ -354,7 +467,10 @@ func (s *RunnerFunc) DeleteByIds(ctx context.Context, ids []int64, operator stri
 	if ids == nil || len(ids) == 0 {
 		return fmt.Errorf("ids is empty")
 	}
-	service := GetRuncherService()
+	service, err := requireRuncher()
+	if err != nil {
+		return err
+	}
 
 	var gotRunner *model.Runner
 	del := &coder.DeleteAPIsReq{}
@@ -369,6 +485,9 @@ func (s *RunnerFunc) DeleteByIds(ctx context.Context, ids []int64, operator stri
 		if runnerFunc == nil {
 			return errors.New("函数不存在")
 		}
+		if err := loadFuncCode(ctx, s.runnerFuncRepo, runnerFunc); err != nil {
+			logger.Warn(ctx, "读取函数源码失败", zap.Error(err), zap.Int64("id", id))
+		}
 		if gotRunner == nil {
 			gotRunner, err = s.runnerRepo.Get(ctx, runnerFunc.RunnerID)
 			if err != nil {
@@ -413,12 +532,23 @@ func (s *RunnerFunc) DeleteByIds(ctx context.Context, ids []int64, operator stri
 	if gotRunner == nil {
 		return errors.New("gotRunner is nil")
 	}
+	if err := checkRunnerDeployable(gotRunner); err != nil {
+		return err
+	}
 
-	rsp, err := service.DeleteAPIs(ctx, del)
+	nodeCtx, err := withRunnerNode(ctx, gotRunner)
+	if err != nil {
+		return err
+	}
+	rsp, err := service.DeleteAPIs(nodeCtx, del)
 	if err != nil {
 		logger.Errorf(ctx, "DeleteAPIs err:%s", err.Error())
 	} else {
 		fmt.Println(rsp)
+		callback, err := onVersionChange(ctx, gotRunner, gotRunner.Version, rsp.Version)
+		if err != nil {
+			return err
+		}
 		if len(rsp.DelApis) != len(ids) {
 			logger.Warnf(ctx, "删除的api和实际删除数量不符：user：%v rel：%v", len(ids), len(rsp.DelApis))
 		}
@@ -428,7 +558,7 @@ func (s *RunnerFunc) DeleteByIds(ctx context.Context, ids []int64, operator stri
 				"version": rsp.Version,
 			})
 		go func() {
-			s.runnerRepo.CreateRunnerVersion(ctx, &model.RunnerVersion{
+			version := &model.RunnerVersion{
 				Base:     model.Base{CreatedBy: gotRunner.CreatedBy, UpdatedBy: gotRunner.UpdatedBy},
 				Desc:     gotRunner.Description,
 				Log:      rsp.GetDelApisDesc(),
@@ -443,8 +573,9 @@ func (s *RunnerFunc) DeleteByIds(ctx context.Context, ids []int64, operator stri
 					},
 				})),
 				Hash: rsp.Hash,
-			})
-
+			}
+			callback.Apply(version)
+			s.runnerRepo.CreateRunnerVersion(ctx, version)
 		}()
 	}
 
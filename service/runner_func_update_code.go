@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	goapi "github.com/yunhanshu-net/function-go/pkg/dto/api"
+	"github.com/yunhanshu-net/function-runtime/pkg/dto/coder"
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/repo"
+	"github.com/yunhanshu-net/pkg/dto/runnerproject"
+	"github.com/yunhanshu-net/pkg/logger"
+	"github.com/yunhanshu-net/pkg/x/jsonx"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// UpdateCode 修改函数代码并重新部署，函数ID、执行记录和在服务树上的位置都不变
+// runcher编译通过后，用返回的api信息刷新参数、路由等信息，更新runner版本并记录函数版本和runner版本
+func (s *RunnerFunc) UpdateCode(ctx context.Context, id int64, code string, comment string, operator string) (*model.RunnerFunc, error) {
+	if strings.TrimSpace(code) == "" {
+		return nil, errors.New("code 不能为空")
+	}
+	fn, err := s.runnerFuncRepo.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("获取函数失败: %w", err)
+	}
+	if fn == nil {
+		return nil, errors.New("函数不存在")
+	}
+	gotRunner, err := s.runnerRepo.Get(ctx, fn.RunnerID)
+	if err != nil {
+		return nil, err
+	}
+	if gotRunner == nil {
+		return nil, errors.New("关联的Runner不存在")
+	}
+	if err := checkRunnerDeployable(gotRunner); err != nil {
+		return nil, err
+	}
+	packageTree, err := s.serviceTreeRepo.Get(ctx, fn.TreeID)
+	if err != nil {
+		return nil, fmt.Errorf("获取服务树失败: %w", err)
+	}
+	if packageTree == nil {
+		return nil, errors.New("关联的服务树不存在")
+	}
+
+	rp, err := runnerproject.NewRunner(gotRunner.User, gotRunner.Name, gotRunner.Version)
+	if err != nil {
+		return nil, err
+	}
+	rp.Language = "go"
+	nodeCtx, service, err := runcherForRunner(ctx, gotRunner)
+	if err != nil {
+		return nil, err
+	}
+	if comment == "" {
+		comment = "修改函数" + fn.Name + "的代码"
+	}
+	rsp, err := service.AddAPI2(nodeCtx, &coder.AddApisReq{
+		Runner: rp,
+		Msg:    comment,
+		CodeApis: []*coder.CodeApi{
+			{
+				EnName:         fn.Name,
+				CnName:         fn.Title,
+				Desc:           fn.Description,
+				Language:       "go",
+				Code:           code,
+				Package:        packageTree.Name,
+				AbsPackagePath: packageTree.GetPackagePath(),
+			},
+		},
+	})
+	if err != nil {
+		logger.Error(ctx, "重新部署函数失败", err, zap.Int64("func_id", id))
+		return nil, err
+	}
+	callback, err := onVersionChange(ctx, gotRunner, gotRunner.Version, rsp.Version)
+	if err != nil {
+		return nil, err
+	}
+	// runcher已经切换到新版本，之后的步骤失败时切回旧版本，和数据库中的记录保持一致
+	restore := func(cause error) error {
+		return restoreVersion(ctx, gotRunner, gotRunner.Version, rsp.Version, cause)
+	}
+	info := changedAPI(rsp.ApiChangeInfo, fn)
+	if info == nil {
+		return nil, restore(errors.New("runcher没有返回函数信息，请检查代码中的函数名称"))
+	}
+
+	oldPath, oldMethod := fn.Path, fn.Method
+	updated := &model.RunnerFunc{}
+	fillRunnerFunc(updated, gotRunner, info)
+	updated.UpdatedBy = operator
+	if updated.Name == "" {
+		updated.Name = fn.Name
+	}
+	if updated.Path != oldPath || !strings.EqualFold(updated.Method, oldMethod) {
+		exist, err := s.runnerFuncRepo.GetByRunnerPath(ctx, fn.RunnerID, updated.Method, updated.Path)
+		if err != nil {
+			return nil, restore(err)
+		}
+		if exist != nil && exist.ID != id {
+			return nil, restore(fmt.Errorf("路由 %s %s 已被函数%s使用", updated.Method, updated.Path, exist.Name))
+		}
+	}
+
+	// 路由变化时旧路由已经不存在，记录为删除，回滚和版本比较按版本记录重放
+	meta := *rsp
+	if rsp.ApiChangeInfo != nil && (updated.Path != oldPath || !strings.EqualFold(updated.Method, oldMethod)) {
+		change := *rsp.ApiChangeInfo
+		change.DelApi = append(change.DelApi, &goapi.Info{
+			Router:      strings.TrimPrefix(oldPath, "/"+gotRunner.User+"/"+gotRunner.Name),
+			Method:      oldMethod,
+			EnglishName: fn.Name,
+			ChineseName: fn.Title,
+		})
+		meta.ApiChangeInfo = &change
+	}
+
+	fv := &model.FuncVersion{
+		Base:     model.Base{CreatedBy: operator, UpdatedBy: operator},
+		RunnerID: fn.RunnerID,
+		FuncID:   id,
+		Version:  rsp.Version,
+		Comment:  comment,
+		MetaData: json.RawMessage(jsonx.String(info)),
+		Hash:     rsp.Hash,
+	}
+	if err := fv.SetSource(code); err != nil {
+		return nil, restore(fmt.Errorf("压缩函数源码失败: %w", err))
+	}
+	err = s.runnerRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		funcRepo := repo.NewRunnerFuncRepo(tx)
+		runnerRepo := repo.NewRunnerRepo(tx)
+		if err := funcRepo.UpdateWithTx(ctx, tx, id, updated); err != nil {
+			return fmt.Errorf("更新函数失败: %w", err)
+		}
+		if err := updateFuncTreeNode(ctx, tx, id, packageTree, updated, operator); err != nil {
+			return fmt.Errorf("更新服务树失败: %w", err)
+		}
+		if err := funcRepo.SaveVersion(ctx, fv); err != nil {
+			return fmt.Errorf("保存函数版本失败: %w", err)
+		}
+		update := &model.Runner{Version: rsp.Version}
+		update.UpdatedBy = operator
+		if err := runnerRepo.UpdateWithTx(ctx, tx, gotRunner.ID, update); err != nil {
+			return fmt.Errorf("更新版本失败: %w", err)
+		}
+		version := &model.RunnerVersion{
+			Base:     model.Base{CreatedBy: operator, UpdatedBy: operator},
+			RunnerID: gotRunner.ID,
+			Version:  rsp.Version,
+			Hash:     rsp.Hash,
+			Desc:     comment,
+			Log:      rsp.ApiChangeInfo.GetChangeLog(),
+			MetaData: json.RawMessage(jsonx.String(meta)),
+		}
+		callback.Apply(version)
+		return runnerRepo.SaveVersionWithTx(ctx, tx, version)
+	})
+	if err != nil {
+		logger.Error(ctx, "保存函数代码修改失败", err, zap.Int64("func_id", id), zap.String("version", rsp.Version))
+		return nil, restore(err)
+	}
+
+	fn, err = s.runnerFuncRepo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	fn.Code = code
+	logger.Info(ctx, "修改函数代码成功", zap.Int64("id", id), zap.String("version", rsp.Version))
+	return fn, nil
+}
+
+// changedAPI 在runcher返回的变更中找到该函数的api信息：优先按函数名匹配，其次按原路由匹配
+func changedAPI(change *coder.ApiChangeInfo, fn *model.RunnerFunc) *goapi.Info {
+	if change == nil {
+		return nil
+	}
+	infos := append(append([]*goapi.Info{}, change.UpdateApi...), change.AddApi...)
+	for _, info := range infos {
+		if info.EnglishName == fn.Name {
+			return info
+		}
+	}
+	for _, info := range infos {
+		if strings.HasSuffix(strings.TrimSuffix(fn.Path, "/"), "/"+strings.Trim(info.Router, "/")) {
+			return info
+		}
+	}
+	if len(infos) == 1 {
+		return infos[0]
+	}
+	return nil
+}
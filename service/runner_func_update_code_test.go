@@ -0,0 +1,33 @@
+package service
+
+import (
+	"testing"
+
+	goapi "github.com/yunhanshu-net/function-go/pkg/dto/api"
+	"github.com/yunhanshu-net/function-runtime/pkg/dto/coder"
+	"github.com/yunhanshu-net/function-server/model"
+)
+
+func TestChangedAPI(t *testing.T) {
+	fn := &model.RunnerFunc{Name: "add", Path: "/u/r/demo/add/"}
+	byName := &goapi.Info{Router: "/demo/add2", EnglishName: "add"}
+	byRouter := &goapi.Info{Router: "/demo/add", EnglishName: "plus"}
+	other := &goapi.Info{Router: "/demo/sub", EnglishName: "sub"}
+
+	cases := []struct {
+		name   string
+		change *coder.ApiChangeInfo
+		want   *goapi.Info
+	}{
+		{"没有变更", nil, nil},
+		{"按函数名匹配", &coder.ApiChangeInfo{UpdateApi: []*goapi.Info{byRouter}, AddApi: []*goapi.Info{byName}}, byName},
+		{"按原路由匹配", &coder.ApiChangeInfo{UpdateApi: []*goapi.Info{other, byRouter}}, byRouter},
+		{"只有一个变更", &coder.ApiChangeInfo{AddApi: []*goapi.Info{other}}, other},
+		{"多个变更都不匹配", &coder.ApiChangeInfo{AddApi: []*goapi.Info{other, {Router: "/demo/mul", EnglishName: "mul"}}}, nil},
+	}
+	for _, c := range cases {
+		if got := changedAPI(c.change, fn); got != c.want {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/yunhanshu-net/function-runtime/pkg/dto/coder"
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/repo"
+	"github.com/yunhanshu-net/pkg/dto/runnerproject"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Migrate 把runner迁移到另一个runcher节点
+// 在目标节点上重新创建项目，按服务树依次添加package和函数代码，成功后切换runner的节点，再删除原节点上的项目
+func (s *Runner) Migrate(ctx context.Context, id int64, runcherID int64, operator string) (*model.Runner, error) {
+	runner, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("获取Runner失败: %w", err)
+	}
+	if runner == nil {
+		return nil, errors.New("runner不存在")
+	}
+	if err := checkRunnerDeployable(runner); err != nil {
+		return nil, err
+	}
+	if runner.RuncherID != nil && *runner.RuncherID == runcherID {
+		return nil, errors.New("runner已经在该节点上")
+	}
+	target, err := s.nodes.Get(ctx, runcherID)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		return nil, errors.New("runcher节点不存在")
+	}
+	if !s.nodes.available(target) {
+		return nil, fmt.Errorf("%w: 节点%s心跳超时、不再分配runner或已满", ErrNoRuncherNode, target.Name)
+	}
+	reserved, err := s.nodes.Reserve(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	if !reserved {
+		return nil, fmt.Errorf("%w: 节点%s已满", ErrNoRuncherNode, target.Name)
+	}
+	migrated := false
+	defer func() {
+		if !migrated {
+			s.nodes.Release(ctx, &target.ID)
+		}
+	}()
+
+	srcCtx, runcherService, err := runcherForRunner(ctx, runner)
+	if err != nil {
+		return nil, err
+	}
+	source := runcherNodeFromContext(srcCtx)
+	dstCtx := WithRuncherNode(ctx, target.Name)
+	logger.Info(ctx, "开始迁移Runner", zap.Int64("id", id), zap.String("from", source), zap.String("to", target.Name))
+
+	version, err := s.replayProject(dstCtx, runcherService, runner)
+	if err != nil {
+		// 清理目标节点上没有完成的项目，原节点上的项目不受影响
+		if _, delErr := runcherService.DeleteProject(dstCtx, &coder.DeleteProjectReq{User: runner.User, Runner: runner.Name}); delErr != nil {
+			logger.Warn(ctx, "清理目标节点上的项目失败", zap.Error(delErr), zap.String("node", target.Name))
+		}
+		return nil, fmt.Errorf("迁移到节点%s失败: %w", target.Name, err)
+	}
+
+	oldVersion := runner.Version
+	update := &model.Runner{RuncherID: &target.ID, Version: version}
+	update.UpdatedBy = operator
+	if err := s.repo.Update(ctx, id, update); err != nil {
+		return nil, fmt.Errorf("更新Runner节点失败: %w", err)
+	}
+	migrated = true
+	s.nodes.Release(ctx, runner.RuncherID)
+	runner.RuncherID = &target.ID
+	runner.Version = version
+	nodeNames.Store(target.ID, target.Name)
+
+	from := source
+	if from == "" {
+		from = "默认节点"
+	}
+	err = s.repo.SaveVersion(ctx, &model.RunnerVersion{
+		RunnerID: id,
+		Version:  version,
+		Comment:  fmt.Sprintf("从%s迁移到节点%s", from, target.Name),
+		Base:     model.Base{CreatedBy: operator},
+	})
+	if err != nil {
+		logger.Error(ctx, "保存迁移版本失败", err, zap.Int64("runner_id", id))
+	}
+
+	// runner已经切换到目标节点，原节点上的项目删除失败只记录日志
+	_, err = runcherService.DeleteProject(srcCtx, &coder.DeleteProjectReq{User: runner.User, Runner: runner.Name, Version: oldVersion})
+	if err != nil {
+		logger.Warn(ctx, "删除原节点上的项目失败", zap.Error(err), zap.String("node", from))
+	}
+
+	logger.Info(ctx, "迁移Runner成功", zap.Int64("id", id), zap.String("to", target.Name), zap.String("version", version))
+	return runner, nil
+}
+
+// replayProject 在ctx指定的节点上重建runner的项目：创建项目，依次添加package和函数，返回最终的版本
+func (s *Runner) replayProject(ctx context.Context, runcherService RuncherService, runner *model.Runner) (string, error) {
+	treeRepo := repo.NewServiceTreeRepo(s.repo.GetDB())
+	funcRepo := repo.NewRunnerFuncRepo(s.repo.GetDB())
+
+	version, err := runcherService.CreateProject(ctx, runner)
+	if err != nil {
+		return "", fmt.Errorf("创建项目失败: %w", err)
+	}
+
+	packages, err := treeRepo.GetPackagesByRunner(ctx, runner.ID)
+	if err != nil {
+		return "", err
+	}
+	trees := make(map[int64]*model.ServiceTree, len(packages))
+	for _, pkg := range packages {
+		trees[pkg.ID] = pkg
+		rp, err := runnerproject.NewRunner(runner.User, runner.Name, version)
+		if err != nil {
+			return "", err
+		}
+		rp.Language = "go"
+		rsp, err := runcherService.AddBizPackage2(ctx, &coder.BizPackage{
+			Runner:         rp,
+			AbsPackagePath: pkg.GetPackagePath(),
+			Language:       runner.Language,
+			EnName:         pkg.Name,
+			CnName:         pkg.Title,
+			Desc:           pkg.Description,
+		})
+		if err != nil {
+			return "", fmt.Errorf("添加package %s 失败: %w", pkg.FullNamePath, err)
+		}
+		if rsp.Version != "" {
+			version = rsp.Version
+		}
+	}
+
+	funcs, err := funcRepo.GetByRunner(ctx, runner.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(funcs) == 0 {
+		return version, nil
+	}
+	rp, err := runnerproject.NewRunner(runner.User, runner.Name, version)
+	if err != nil {
+		return "", err
+	}
+	rp.Language = "go"
+	req := &coder.AddApisReq{Runner: rp, Msg: "重建项目"}
+	for i := range funcs {
+		fn := &funcs[i]
+		if err := loadFuncCode(ctx, funcRepo, fn); err != nil {
+			return "", fmt.Errorf("读取函数%s的源码失败: %w", fn.Name, err)
+		}
+		if fn.Code == "" {
+			return "", fmt.Errorf("函数%s没有保存源码，无法在目标节点上重建", fn.Name)
+		}
+		tree := trees[fn.TreeID]
+		if tree == nil {
+			// 根目录下的函数
+			if tree, err = treeRepo.Get(ctx, fn.TreeID); err != nil {
+				return "", err
+			}
+			if tree == nil {
+				return "", fmt.Errorf("函数%s所在的目录不存在", fn.Name)
+			}
+			trees[fn.TreeID] = tree
+		}
+		req.CodeApis = append(req.CodeApis, &coder.CodeApi{
+			EnName:         fn.Name,
+			CnName:         fn.Title,
+			Desc:           fn.Description,
+			Language:       "go",
+			Code:           fn.Code,
+			Package:        tree.Name,
+			AbsPackagePath: tree.GetPackagePath(),
+		})
+	}
+	rsp, err := runcherService.AddAPI2(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("添加函数失败: %w", err)
+	}
+	return rsp.Version, nil
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	goapi "github.com/yunhanshu-net/function-go/pkg/dto/api"
+	"github.com/yunhanshu-net/function-runtime/pkg/dto/coder"
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/pkg/dto/runcher"
+	"github.com/yunhanshu-net/function-server/repo"
+	"github.com/yunhanshu-net/pkg/logger"
+	"github.com/yunhanshu-net/pkg/x/jsonx"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// RollbackResult 回滚结果
+type RollbackResult struct {
+	Runner  *model.Runner
+	Target  string        // 回滚到的版本
+	Added   []*goapi.Info // 恢复的函数
+	Removed []*goapi.Info // 删除的函数
+	Updated []*goapi.Info // 更新的函数
+	Skipped []*goapi.Info // runcher上的代码已经恢复，但没有可以恢复的函数记录，需要重新创建函数
+}
+
+// apiKey 函数在版本中的标识
+func apiKey(method, router string) string {
+	return strings.ToUpper(method) + " " + strings.Trim(router, "/")
+}
+
+// apisAtVersion 按顺序重放版本记录中的api变更，得到目标版本的api集合
+func apisAtVersion(versions []model.RunnerVersion, target string) (map[string]*goapi.Info, error) {
+	last := -1
+	for i, v := range versions {
+		if v.Version == target {
+			last = i
+		}
+	}
+	if last < 0 {
+		return nil, fmt.Errorf("版本不存在: %s", target)
+	}
+	apis := make(map[string]*goapi.Info)
+	for _, v := range versions[:last+1] {
+		if len(v.MetaData) == 0 {
+			continue
+		}
+		var rsp coder.AddApisResp
+		if err := json.Unmarshal(v.MetaData, &rsp); err != nil || rsp.ApiChangeInfo == nil {
+			// 创建项目、迁移等版本没有api变更
+			continue
+		}
+		for _, info := range rsp.ApiChangeInfo.DelApi {
+			if info.Method != "" {
+				delete(apis, apiKey(info.Method, info.Router))
+				continue
+			}
+			for key, exist := range apis {
+				if strings.Trim(exist.Router, "/") == strings.Trim(info.Router, "/") {
+					delete(apis, key)
+				}
+			}
+		}
+		for _, info := range append(rsp.ApiChangeInfo.AddApi, rsp.ApiChangeInfo.UpdateApi...) {
+			apis[apiKey(info.Method, info.Router)] = info
+		}
+	}
+	return apis, nil
+}
+
+// updateFuncTreeNode 按函数信息更新函数在服务树上的节点，和函数记录在同一个事务中更新
+func updateFuncTreeNode(ctx context.Context, tx *gorm.DB, funcID int64, packageTree *model.ServiceTree, fn *model.RunnerFunc, operator string) error {
+	node := &model.ServiceTree{
+		Name:         fn.Name,
+		Title:        fn.Title,
+		Method:       fn.Method,
+		FullNamePath: packageTree.FullNamePath + fn.Name + "/",
+	}
+	node.UpdatedBy = operator
+	return tx.WithContext(ctx).Model(&model.ServiceTree{}).
+		Where("ref_id = ? AND type = ?", funcID, model.ServiceTreeTypeFunction).Updates(node).Error
+}
+
+// Rollback 把runner回滚到之前的版本
+// runcher切换代码后，按目标版本的api集合恢复被删除的函数、删除之后新增的函数，并把回滚记录为一个新版本
+func (s *Runner) Rollback(ctx context.Context, id int64, target string, operator string) (*RollbackResult, error) {
+	runner, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("获取Runner失败: %w", err)
+	}
+	if runner == nil {
+		return nil, errors.New("runner不存在")
+	}
+	if err := checkRunnerDeployable(runner); err != nil {
+		return nil, err
+	}
+	if runner.Version == target {
+		return nil, fmt.Errorf("当前已经是版本%s", target)
+	}
+	versions, err := s.repo.ListVersions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	apis, err := apisAtVersion(versions, target)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeCtx, runcherService, err := runcherForRunner(ctx, runner)
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := runcherService.ActivateVersion(nodeCtx, &runcher.ActivateVersionReq{
+		User:           runner.User,
+		Runner:         runner.Name,
+		Version:        target,
+		CurrentVersion: runner.Version,
+	})
+	if err != nil {
+		return nil, err
+	}
+	version := rsp.Version
+	if version == "" {
+		version = target
+	}
+	callback, err := onVersionChange(ctx, runner, runner.Version, version)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RollbackResult{Target: target}
+	err = s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		funcRepo := repo.NewRunnerFuncRepo(tx)
+		funcs, err := funcRepo.GetByRunner(ctx, id)
+		if err != nil {
+			return err
+		}
+		current := make(map[string]model.RunnerFunc, len(funcs))
+		for _, fn := range funcs {
+			current[strings.ToUpper(fn.Method)+" "+fn.Path] = fn
+		}
+
+		treeRepo := repo.NewServiceTreeRepo(tx)
+		packages := make(map[int64]*model.ServiceTree)
+		// updateNode 函数名称、标题在版本间可能不同，同步更新函数在服务树上的节点
+		updateNode := func(fn *model.RunnerFunc, fc *model.RunnerFunc) error {
+			pkg, ok := packages[fn.TreeID]
+			if !ok {
+				if pkg, err = treeRepo.Get(ctx, fn.TreeID); err != nil {
+					return err
+				}
+				packages[fn.TreeID] = pkg
+			}
+			if pkg == nil {
+				logger.Warn(ctx, "回滚时没有找到函数所在的package", zap.Int64("func_id", fn.ID), zap.Int64("tree_id", fn.TreeID))
+				return nil
+			}
+			if err := updateFuncTreeNode(ctx, tx, fn.ID, pkg, fc, operator); err != nil {
+				return fmt.Errorf("更新函数%s的服务树节点失败: %w", fc.Name, err)
+			}
+			return nil
+		}
+
+		for _, info := range apis {
+			fc := model.RunnerFunc{}
+			fillRunnerFunc(&fc, runner, info)
+			fc.UpdatedBy = operator
+			key := strings.ToUpper(fc.Method) + " " + fc.Path
+			if fn, ok := current[key]; ok {
+				delete(current, key)
+				if err := funcRepo.UpdateWithTx(ctx, tx, fn.ID, &fc); err != nil {
+					return fmt.Errorf("更新函数%s失败: %w", fn.Name, err)
+				}
+				if err := updateNode(&fn, &fc); err != nil {
+					return err
+				}
+				result.Updated = append(result.Updated, info)
+				continue
+			}
+			deleted, err := funcRepo.GetDeletedByRunnerPath(ctx, id, fc.Method, fc.Path)
+			if err != nil {
+				return err
+			}
+			if deleted == nil {
+				// 没有函数记录时跳过，runcher上的代码已经恢复，在结果中返回，需要重新创建函数
+				logger.Warn(ctx, "回滚时没有找到已删除的函数", zap.Int64("runner_id", id), zap.String("path", fc.Path))
+				result.Skipped = append(result.Skipped, info)
+				continue
+			}
+			if err := funcRepo.RestoreWithTx(ctx, tx, deleted.ID, operator); err != nil {
+				return fmt.Errorf("恢复函数%s失败: %w", deleted.Name, err)
+			}
+			if err := funcRepo.UpdateWithTx(ctx, tx, deleted.ID, &fc); err != nil {
+				return fmt.Errorf("更新函数%s失败: %w", deleted.Name, err)
+			}
+			if err := updateNode(deleted, &fc); err != nil {
+				return err
+			}
+			result.Added = append(result.Added, info)
+		}
+
+		for _, fn := range current {
+			if err := funcRepo.DeleteWithTx(ctx, tx, fn.ID, operator); err != nil {
+				return fmt.Errorf("删除函数%s失败: %w", fn.Name, err)
+			}
+			result.Removed = append(result.Removed, &goapi.Info{
+				Router:      strings.TrimPrefix(fn.Path, "/"+runner.User+"/"+runner.Name),
+				Method:      fn.Method,
+				EnglishName: fn.Name,
+				ChineseName: fn.Title,
+			})
+		}
+
+		update := &model.Runner{Version: version}
+		update.UpdatedBy = operator
+		if err := s.repo.UpdateWithTx(ctx, tx, id, update); err != nil {
+			return err
+		}
+		record := &model.RunnerVersion{
+			Base:     model.Base{CreatedBy: operator, UpdatedBy: operator},
+			RunnerID: id,
+			Version:  version,
+			Hash:     rsp.Hash,
+			Comment:  "回滚到 " + target,
+			Log: fmt.Sprintf("回滚到%s：恢复%d个函数，删除%d个函数，%d个函数没有记录需要重新创建",
+				target, len(result.Added), len(result.Removed), len(result.Skipped)),
+			MetaData: json.RawMessage(jsonx.String(coder.AddApisResp{
+				Version: version,
+				Hash:    rsp.Hash,
+				ApiChangeInfo: &coder.ApiChangeInfo{
+					CurrentVersion: version,
+					AddApi:         result.Added,
+					DelApi:         result.Removed,
+					UpdateApi:      result.Updated,
+				},
+			})),
+		}
+		callback.Apply(record)
+		return s.repo.SaveVersionWithTx(ctx, tx, record)
+	})
+	if err != nil {
+		// runcher已经切换到目标版本，切回原来的版本，和函数记录保持一致
+		logger.Error(ctx, "回滚Runner函数记录失败", err, zap.Int64("id", id), zap.String("target", target), zap.String("version", version))
+		return nil, restoreVersion(ctx, runner, runner.Version, version, fmt.Errorf("回滚函数记录失败: %w", err))
+	}
+
+	runner.Version = version
+	result.Runner = runner
+	logger.Info(ctx, "回滚Runner成功", zap.Int64("id", id), zap.String("target", target), zap.String("version", version),
+		zap.Int("added", len(result.Added)), zap.Int("removed", len(result.Removed)), zap.Int("skipped", len(result.Skipped)))
+	return result, nil
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/pkg/dto/runcher"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Runner状态变更动作
+const (
+	RunnerActionStart   = "start"
+	RunnerActionStop    = "stop"
+	RunnerActionSuspend = "suspend"
+	RunnerActionArchive = "archive"
+)
+
+// ErrRunnerNotRunning runner不是运行中，不能执行函数
+var ErrRunnerNotRunning = errors.New("runner未运行")
+
+// ErrRunnerArchived runner已归档，不能再部署新的版本
+var ErrRunnerArchived = errors.New("runner已归档")
+
+// ErrRunnerStatusTransition 当前状态不允许该动作
+var ErrRunnerStatusTransition = errors.New("当前状态不允许该操作")
+
+// runnerTransition 动作的目标状态以及允许执行该动作的状态，归档是终态
+type runnerTransition struct {
+	to   int8
+	from []int8
+}
+
+var runnerTransitions = map[string]runnerTransition{
+	RunnerActionStart:   {to: model.RunnerStatusRunning, from: []int8{model.RunnerStatusStopped, model.RunnerStatusSuspended}},
+	RunnerActionStop:    {to: model.RunnerStatusStopped, from: []int8{model.RunnerStatusRunning, model.RunnerStatusSuspended}},
+	RunnerActionSuspend: {to: model.RunnerStatusSuspended, from: []int8{model.RunnerStatusRunning}},
+	RunnerActionArchive: {to: model.RunnerStatusArchived, from: []int8{model.RunnerStatusRunning, model.RunnerStatusStopped, model.RunnerStatusSuspended}},
+}
+
+// checkRunnerRunning 执行函数前检查runner状态
+func checkRunnerRunning(runner *model.Runner) error {
+	if runner == nil || runner.IsRunning() {
+		return nil
+	}
+	return fmt.Errorf("%w: %s/%s 当前状态为 %s", ErrRunnerNotRunning, runner.User, runner.Name, model.RunnerStatusName(runner.Status))
+}
+
+// checkRunnerDeployable 部署、同步、发布版本前检查runner没有归档
+func checkRunnerDeployable(runner *model.Runner) error {
+	if runner == nil || runner.Status != model.RunnerStatusArchived {
+		return nil
+	}
+	return fmt.Errorf("%w: %s/%s 不能部署", ErrRunnerArchived, runner.User, runner.Name)
+}
+
+// ChangeStatus 执行状态变更动作：校验状态流转，更新状态并记录变更历史，再通知runcher
+// 状态以CAS方式更新，并发修改时只有一个成功；runcher处理失败时事务回滚，状态不变
+// 通知runcher后事务提交失败时，再通知runcher恢复原来的状态
+func (s *Runner) ChangeStatus(ctx context.Context, id int64, action, reason, operator string) (*model.Runner, error) {
+	transition, ok := runnerTransitions[action]
+	if !ok {
+		return nil, fmt.Errorf("不支持的操作: %s", action)
+	}
+	runner, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("获取Runner失败: %w", err)
+	}
+	if runner == nil {
+		return nil, errors.New("runner不存在")
+	}
+	from := runner.Status
+	if from == 0 {
+		from = model.RunnerStatusRunning
+	}
+	allowed := false
+	for _, status := range transition.from {
+		if status == from {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: %s 状态不能执行 %s", ErrRunnerStatusTransition, model.RunnerStatusName(from), action)
+	}
+
+	nodeCtx, runcherService, err := runcherForRunner(ctx, runner)
+	if err != nil {
+		return nil, err
+	}
+	notify := func(action string, to int8) error {
+		return runcherService.UpdateRunnerStatus(nodeCtx, &runcher.RunnerStatusReq{
+			User:    runner.User,
+			Runner:  runner.Name,
+			Version: runner.Version,
+			Action:  action,
+			Status:  model.RunnerStatusName(to),
+			Reason:  reason,
+		})
+	}
+
+	notified := false
+	err = s.repo.GetDB().Transaction(func(tx *gorm.DB) error {
+		updated, err := s.repo.UpdateStatusFrom(ctx, tx, id, runner.Status, transition.to)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return fmt.Errorf("%w: 状态已被修改，请刷新后重试", ErrRunnerStatusTransition)
+		}
+		err = s.repo.CreateStatusLogWithTx(ctx, tx, &model.RunnerStatusLog{
+			RunnerID: id,
+			Action:   action,
+			From:     from,
+			To:       transition.to,
+			Reason:   reason,
+			Base:     model.Base{CreatedBy: operator},
+		})
+		if err != nil {
+			return err
+		}
+		if err := notify(action, transition.to); err != nil {
+			return err
+		}
+		notified = true
+		return nil
+	})
+	if err != nil {
+		logger.Error(ctx, "更新Runner状态失败", err, zap.Int64("id", id), zap.String("action", action))
+		if notified {
+			if revertErr := notify(revertAction(from), from); revertErr != nil {
+				logger.Error(ctx, "恢复runcher中的Runner状态失败", revertErr, zap.Int64("id", id),
+					zap.String("status", model.RunnerStatusName(from)))
+			}
+		}
+		return nil, err
+	}
+
+	runner.Status = transition.to
+	logger.Info(ctx, "Runner状态变更成功", zap.Int64("id", id), zap.String("action", action),
+		zap.String("from", model.RunnerStatusName(from)), zap.String("to", model.RunnerStatusName(transition.to)))
+	return runner, nil
+}
+
+// revertAction 恢复到status时通知runcher的动作
+func revertAction(status int8) string {
+	for action, transition := range runnerTransitions {
+		if transition.to == status {
+			return action
+		}
+	}
+	return ""
+}
+
+// StatusHistory 获取Runner状态变更历史
+func (s *Runner) StatusHistory(ctx context.Context, id int64) ([]model.RunnerStatusLog, error) {
+	return s.repo.GetStatusLogs(ctx, id)
+}
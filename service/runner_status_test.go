@@ -0,0 +1,34 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yunhanshu-net/function-server/model"
+)
+
+func TestRunnerStatusGuards(t *testing.T) {
+	for _, status := range []int8{0, model.RunnerStatusRunning, model.RunnerStatusStopped, model.RunnerStatusSuspended} {
+		if err := checkRunnerDeployable(&model.Runner{Status: status}); err != nil {
+			t.Errorf("status %d should be deployable: %v", status, err)
+		}
+	}
+	if err := checkRunnerDeployable(&model.Runner{Status: model.RunnerStatusArchived}); !errors.Is(err, ErrRunnerArchived) {
+		t.Errorf("archived runner should not be deployable, got %v", err)
+	}
+	if err := checkRunnerRunning(&model.Runner{Status: model.RunnerStatusStopped}); !errors.Is(err, ErrRunnerNotRunning) {
+		t.Errorf("stopped runner should not run, got %v", err)
+	}
+}
+
+func TestRevertAction(t *testing.T) {
+	// 归档是终态，其他可以作为变更前状态的都能找到恢复的动作
+	for _, transition := range runnerTransitions {
+		for _, from := range transition.from {
+			action := revertAction(from)
+			if action == "" || runnerTransitions[action].to != from {
+				t.Errorf("revert to %d got action %q", from, action)
+			}
+		}
+	}
+}
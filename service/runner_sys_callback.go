@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	resp "github.com/yunhanshu-net/function-go/pkg/dto/response"
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/pkg/config"
+	"github.com/yunhanshu-net/function-server/pkg/db"
+	"github.com/yunhanshu-net/function-server/pkg/dto/runcher"
+	"github.com/yunhanshu-net/function-server/pkg/dto/syscallback"
+	"github.com/yunhanshu-net/function-server/repo"
+	"github.com/yunhanshu-net/pkg/logger"
+	"github.com/yunhanshu-net/pkg/x/jsonx"
+	"go.uber.org/zap"
+)
+
+// sysCallbackRouter runner中处理系统回调的路由
+const sysCallbackRouter = "_sysCallback"
+
+// SysOnVersionChange 版本变更系统回调，部署后以新版本调用，runner可以在回调中建表、迁移数据
+const SysOnVersionChange = "SysOnVersionChange"
+
+// sysCallbackPrefix 系统回调类型的前缀，系统回调只能由服务端调用
+const sysCallbackPrefix = "Sys"
+
+// sysCallbackRegistry 系统回调的说明和超时时间，不对用户开放，和函数回调分开注册
+var sysCallbackRegistry = map[string]CallbackSpec{
+	SysOnVersionChange: {Type: SysOnVersionChange, Desc: "版本变更，用于建表等迁移操作", Timeout: 60 * time.Second},
+}
+
+// isSysCallback 是否是系统回调类型
+func isSysCallback(callbackType string) bool {
+	return strings.HasPrefix(callbackType, sysCallbackPrefix)
+}
+
+// sysCallbackTimeout 获取系统回调的超时时间，配置中按类型设置的优先
+func sysCallbackTimeout(callbackType string) time.Duration {
+	if seconds, ok := config.Get().RunConfig.CallbackTimeouts[callbackType]; ok && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return sysCallbackRegistry[callbackType].Timeout
+}
+
+// 系统回调的结果
+const (
+	SysCallbackSuccess = "success"
+	SysCallbackFail    = "fail"
+)
+
+// ErrSysCallback 系统回调失败，部署已经回滚
+var ErrSysCallback = errors.New("版本变更回调失败")
+
+// sysOnVersionChangeBody 回调请求体，type用于runner区分系统回调类型
+type sysOnVersionChangeBody struct {
+	Type string `json:"type"`
+	syscallback.SysOnVersionChangeReq
+}
+
+// SysCallbackResult 系统回调的结果，保存在runner版本记录上
+type SysCallbackResult struct {
+	Status string
+	Msg    string
+	Data   json.RawMessage // runner返回的结果，data中是runner处理的版本变更信息 SysOnVersionChangeResp
+}
+
+// Apply 把回调结果写入版本记录
+func (r *SysCallbackResult) Apply(v *model.RunnerVersion) {
+	if r == nil || v == nil {
+		return
+	}
+	v.CallbackStatus = r.Status
+	v.CallbackMsg = r.Msg
+	v.CallbackResult = r.Data
+}
+
+// onVersionChange 部署后以新版本调用runner的版本变更回调
+// 回调失败时runcher切回旧版本，并保存一条回调失败的版本记录，返回ErrSysCallback，调用方不再保存这次部署
+func onVersionChange(ctx context.Context, runner *model.Runner, oldVersion, newVersion string) (*SysCallbackResult, error) {
+	if oldVersion == newVersion {
+		return nil, nil
+	}
+	result := callVersionChange(ctx, runner, oldVersion, newVersion)
+	if result.Status == SysCallbackSuccess {
+		logger.Info(ctx, "版本变更回调成功", zap.Int64("runner_id", runner.ID),
+			zap.String("old_version", oldVersion), zap.String("new_version", newVersion))
+		return result, nil
+	}
+
+	err := fmt.Errorf("%w: %s", ErrSysCallback, result.Msg)
+	logger.Error(ctx, "版本变更回调失败，回滚部署", err, zap.Int64("runner_id", runner.ID),
+		zap.String("old_version", oldVersion), zap.String("new_version", newVersion))
+	err = restoreVersion(ctx, runner, oldVersion, newVersion, err)
+	saveFailedVersion(ctx, runner, oldVersion, newVersion, result)
+	return result, err
+}
+
+// saveFailedVersion 保存回调失败的版本记录，方便在版本历史中查看失败原因
+// 回滚、对比等按版本重放的逻辑会跳过回调失败的版本，保存失败只记录日志
+func saveFailedVersion(ctx context.Context, runner *model.Runner, oldVersion, newVersion string, result *SysCallbackResult) {
+	gormDB := db.GetDB()
+	if gormDB == nil {
+		return
+	}
+	version := &model.RunnerVersion{
+		RunnerID: runner.ID,
+		Version:  newVersion,
+		Log:      fmt.Sprintf("版本变更回调失败，已回滚到%s", oldVersion),
+	}
+	result.Apply(version)
+	if err := repo.NewRunnerRepo(gormDB).SaveVersion(ctx, version); err != nil {
+		logger.Error(ctx, "保存回调失败的版本记录失败", err, zap.Int64("runner_id", runner.ID), zap.String("version", newVersion))
+	}
+}
+
+// restoreVersion 部署后的步骤失败时让runcher切回旧版本，返回带有回滚结果的cause
+// 旧版本为空（首次部署）时不切换
+func restoreVersion(ctx context.Context, runner *model.Runner, oldVersion, newVersion string, cause error) error {
+	if oldVersion == "" || oldVersion == newVersion {
+		return cause
+	}
+	nodeCtx, runcherService, err := runcherForRunner(ctx, runner)
+	if err == nil {
+		_, err = runcherService.ActivateVersion(nodeCtx, &runcher.ActivateVersionReq{
+			User:           runner.User,
+			Runner:         runner.Name,
+			Version:        oldVersion,
+			CurrentVersion: newVersion,
+		})
+	}
+	if err != nil {
+		logger.Error(ctx, "回滚部署失败", err, zap.Int64("runner_id", runner.ID), zap.String("version", oldVersion))
+		return fmt.Errorf("%w，回滚到%s失败: %s", cause, oldVersion, err)
+	}
+	logger.Info(ctx, "已回滚部署", zap.Int64("runner_id", runner.ID), zap.String("version", oldVersion), zap.String("from", newVersion))
+	return cause
+}
+
+// callVersionChange 调用版本变更回调，执行失败和runner返回错误都记录为失败
+func callVersionChange(ctx context.Context, runner *model.Runner, oldVersion, newVersion string) *SysCallbackResult {
+	result := &SysCallbackResult{Status: SysCallbackFail}
+	nodeCtx, runcherService, err := runcherForRunner(ctx, runner)
+	if err != nil {
+		result.Msg = err.Error()
+		return result
+	}
+	timeout := sysCallbackTimeout(SysOnVersionChange)
+	timeoutCtx, cancel := context.WithTimeout(nodeCtx, timeout)
+	defer cancel()
+
+	msg, err := runcherService.RunFunction2(timeoutCtx, &runcher.RunFunctionReq{
+		User:    runner.User,
+		Runner:  runner.Name,
+		Version: newVersion,
+		Method:  http.MethodPost,
+		Router:  sysCallbackRouter,
+		Body: jsonx.String(sysOnVersionChangeBody{
+			Type:                  SysOnVersionChange,
+			SysOnVersionChangeReq: syscallback.SysOnVersionChangeReq{OldVersion: oldVersion, NewVersion: newVersion},
+		}),
+	})
+	if err != nil {
+		if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+			result.Msg = fmt.Sprintf("回调执行超时(%s)", timeout)
+		} else {
+			result.Msg = err.Error()
+		}
+		return result
+	}
+	result.Data = msg.Data
+
+	var res resp.RunFunctionResp
+	if err := json.Unmarshal(msg.Data, &res); err != nil {
+		result.Msg = "解析回调结果失败: " + err.Error()
+		return result
+	}
+	if res.Code != 0 {
+		result.Msg = res.Msg
+		return result
+	}
+	result.Status = SysCallbackSuccess
+	return result
+}
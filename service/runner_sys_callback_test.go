@@ -0,0 +1,35 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/yunhanshu-net/function-server/model"
+)
+
+func TestSysCallbackSpec(t *testing.T) {
+	if !isSysCallback(SysOnVersionChange) || isSysCallback("OnPageLoad") {
+		t.Fatal("only Sys* types are system callbacks")
+	}
+	for callbackType := range callbackRegistry {
+		if isSysCallback(callbackType) {
+			t.Errorf("system callback %s should not be in the user callback registry", callbackType)
+		}
+	}
+	if timeout := sysCallbackTimeout(SysOnVersionChange); timeout != sysCallbackRegistry[SysOnVersionChange].Timeout || timeout == 0 {
+		t.Errorf("unexpected timeout %s", timeout)
+	}
+}
+
+func TestSysCallbackResultApply(t *testing.T) {
+	v := &model.RunnerVersion{}
+	var nilResult *SysCallbackResult
+	nilResult.Apply(v)
+	if v.CallbackStatus != "" {
+		t.Fatal("nil result should not change the version")
+	}
+	(&SysCallbackResult{Status: SysCallbackFail, Msg: "建表失败", Data: json.RawMessage(`{"code":1}`)}).Apply(v)
+	if v.CallbackStatus != SysCallbackFail || v.CallbackMsg != "建表失败" || string(v.CallbackResult) != `{"code":1}` {
+		t.Fatalf("unexpected version callback fields: %+v", v)
+	}
+}
@@ -0,0 +1,343 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/yunhanshu-net/function-server/model"
+	"github.com/yunhanshu-net/function-server/pkg/dto"
+	"github.com/yunhanshu-net/function-server/pkg/textdiff"
+	"github.com/yunhanshu-net/function-server/repo"
+	"github.com/yunhanshu-net/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// upstreamDiff 上游函数的一个变化，以及同步时需要的函数和源码
+type upstreamDiff struct {
+	change   *dto.UpstreamChange
+	upstream *model.RunnerFunc // 上游函数，删除的函数是已删除的记录
+	fork     *model.RunnerFunc // fork中对应的函数，上游新增的函数为nil
+	code     string            // 上游当前的源码
+}
+
+// latestSource 函数最新保存的源码，没有保存源码时为空
+func latestSource(sources []model.FuncVersion) (string, error) {
+	if len(sources) == 0 {
+		return "", nil
+	}
+	return sources[len(sources)-1].GetSource()
+}
+
+// upstreamState 三方比较时一个上游函数的源码和删除状态
+type upstreamState struct {
+	base        string // 上次同步时上游的源码，为空表示没有基准
+	fork        string // fork当前的源码
+	upstream    string // 上游当前的源码
+	forkDeleted bool   // fork删除了该函数
+	upDeleted   bool   // 上游删除了该函数
+}
+
+// classifyUpstream 判断上游函数是否需要同步，以及fork是否修改过（修改过时为冲突）
+// fork删除的函数不再同步；上游删除的函数总是需要同步；上游源码为空、和fork相同或和基准相同时没有变化
+// 没有基准时无法判断fork是否修改过，按修改过处理
+func classifyUpstream(st upstreamState) (changed, forkModified bool) {
+	if st.forkDeleted {
+		return false, false
+	}
+	if !st.upDeleted && (st.upstream == "" || st.upstream == st.fork || (st.base != "" && st.upstream == st.base)) {
+		return false, false
+	}
+	return true, st.base == "" || st.fork != st.base
+}
+
+// upstreamChanges 比较fork出的runner和上游，找出上游自上次同步以来变化的函数
+// 每个fork的函数用ForkFromVersion记录上次同步时上游的版本，以该版本上游的源码作为比较的基准：
+// 上游源码和基准不同表示上游有变化，fork源码和基准不同表示fork修改过，两者都有变化时为冲突
+// fork删除了的上游函数不再同步
+func (s *Runner) upstreamChanges(ctx context.Context, fork *model.Runner) (*model.Runner, []*upstreamDiff, error) {
+	if fork.ForkFromID == nil {
+		return nil, nil, errors.New("runner不是fork出来的，没有上游")
+	}
+	upstream, err := s.repo.Get(ctx, *fork.ForkFromID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取上游Runner失败: %w", err)
+	}
+	if upstream == nil {
+		return nil, nil, errors.New("上游Runner不存在")
+	}
+	funcRepo := repo.NewRunnerFuncRepo(s.repo.GetDB())
+	versions, err := s.repo.ListVersions(ctx, upstream.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	upFuncs, err := funcRepo.GetByRunner(ctx, upstream.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	deleted, err := funcRepo.GetDeletedByRunner(ctx, upstream.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	forkedFuncs, err := funcRepo.GetForkedByRunner(ctx, fork.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 同一个上游函数在fork中删除后又同步过时，以没有删除的为准
+	forked := make(map[int64]*model.RunnerFunc, len(forkedFuncs))
+	for i := range forkedFuncs {
+		fc := &forkedFuncs[i]
+		if exist, ok := forked[*fc.ForkFromID]; ok && !exist.DeletedAt.Valid {
+			continue
+		}
+		forked[*fc.ForkFromID] = fc
+	}
+
+	known := make(map[string]bool, len(versions))
+	for _, v := range versions {
+		known[v.Version] = true
+	}
+	// 比较的基准和fork的源码，同步版本不在上游的版本记录中时没有基准
+	sources := func(fc *model.RunnerFunc, upSources []model.FuncVersion) (base string, forkCode string, err error) {
+		if known[fc.ForkFromVersion] {
+			if base, err = sourceAtVersion(upSources, versions, fc.ForkFromVersion); err != nil {
+				return "", "", fmt.Errorf("读取上游函数%s的源码失败: %w", fc.Name, err)
+			}
+		}
+		v, err := funcRepo.GetSourceVersion(ctx, fc.ID, "")
+		if err != nil {
+			return "", "", err
+		}
+		if v != nil {
+			if forkCode, err = v.GetSource(); err != nil {
+				return "", "", fmt.Errorf("读取函数%s的源码失败: %w", fc.Name, err)
+			}
+		}
+		return base, forkCode, nil
+	}
+	newChange := func(fn *model.RunnerFunc, changeType string) *dto.UpstreamChange {
+		return &dto.UpstreamChange{
+			UpstreamFuncID: fn.ID,
+			Name:           fn.Name,
+			Title:          fn.Title,
+			Method:         fn.Method,
+			Router:         FuncRouter(upstream, fn),
+			Type:           changeType,
+		}
+	}
+
+	var diffs []*upstreamDiff
+	for i := range upFuncs {
+		up := &upFuncs[i]
+		upSources, err := funcRepo.GetSourceVersions(ctx, up.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		code, err := latestSource(upSources)
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取上游函数%s的源码失败: %w", up.Name, err)
+		}
+		fc, ok := forked[up.ID]
+		if !ok {
+			change := newChange(up, dto.UpstreamChangeAdded)
+			change.CodeDiff = textdiff.Unified("", up.Name, "", code, 3)
+			diffs = append(diffs, &upstreamDiff{change: change, upstream: up, code: code})
+			continue
+		}
+		if fc.DeletedAt.Valid {
+			continue
+		}
+		base, forkCode, err := sources(fc, upSources)
+		if err != nil {
+			return nil, nil, err
+		}
+		changed, forkModified := classifyUpstream(upstreamState{base: base, fork: forkCode, upstream: code})
+		if !changed {
+			continue
+		}
+		change := newChange(up, dto.UpstreamChangeUpdated)
+		change.FuncID = fc.ID
+		change.BaseVersion = fc.ForkFromVersion
+		change.ForkModified = forkModified
+		change.Conflict = forkModified
+		change.CodeDiff = textdiff.Unified(fc.ForkFromVersion, upstream.Version, base, code, 3)
+		diffs = append(diffs, &upstreamDiff{change: change, upstream: up, fork: fc, code: code})
+	}
+	for i := range deleted {
+		up := &deleted[i]
+		fc, ok := forked[up.ID]
+		if !ok || fc.DeletedAt.Valid {
+			continue
+		}
+		upSources, err := funcRepo.GetSourceVersions(ctx, up.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		base, forkCode, err := sources(fc, upSources)
+		if err != nil {
+			return nil, nil, err
+		}
+		_, forkModified := classifyUpstream(upstreamState{base: base, fork: forkCode, upDeleted: true})
+		change := newChange(up, dto.UpstreamChangeRemoved)
+		change.FuncID = fc.ID
+		change.BaseVersion = fc.ForkFromVersion
+		change.ForkModified = forkModified
+		change.Conflict = forkModified
+		diffs = append(diffs, &upstreamDiff{change: change, upstream: up, fork: fc})
+	}
+	sort.SliceStable(diffs, func(i, j int) bool { return diffs[i].upstream.ID < diffs[j].upstream.ID })
+	return upstream, diffs, nil
+}
+
+// UpstreamStatus 获取上游自上次同步以来变化的函数
+func (s *Runner) UpstreamStatus(ctx context.Context, id int64) (*dto.UpstreamStatusResp, error) {
+	fork, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("获取Runner失败: %w", err)
+	}
+	if fork == nil {
+		return nil, errors.New("runner不存在")
+	}
+	upstream, diffs, err := s.upstreamChanges(ctx, fork)
+	if err != nil {
+		return nil, err
+	}
+	resp := &dto.UpstreamStatusResp{
+		UpstreamID:      upstream.ID,
+		Upstream:        upstream.User + "/" + upstream.Name,
+		UpstreamVersion: upstream.Version,
+		ForkFromVersion: fork.ForkFromVersion,
+		Changes:         make([]*dto.UpstreamChange, 0, len(diffs)),
+	}
+	for _, d := range diffs {
+		resp.Changes = append(resp.Changes, d.change)
+	}
+	return resp, nil
+}
+
+// SyncUpstream 同步选中的上游变化：更新的函数重新部署上游的代码，新增的函数创建到对应的目录，删除的函数从fork中删除
+// 有冲突的变化默认跳过，force时使用上游覆盖fork的修改；每个函数单独部署，失败时之前已同步的函数不回滚
+func (s *Runner) SyncUpstream(ctx context.Context, id int64, upstreamFuncIDs []int64, force bool, operator string) (*dto.SyncUpstreamResp, error) {
+	fork, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("获取Runner失败: %w", err)
+	}
+	if fork == nil {
+		return nil, errors.New("runner不存在")
+	}
+	if err := checkRunnerDeployable(fork); err != nil {
+		return nil, err
+	}
+	upstream, diffs, err := s.upstreamChanges(ctx, fork)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*upstreamDiff, len(diffs))
+	for _, d := range diffs {
+		byID[d.upstream.ID] = d
+	}
+	var selected []*upstreamDiff
+	for _, upstreamFuncID := range upstreamFuncIDs {
+		d, ok := byID[upstreamFuncID]
+		if !ok {
+			return nil, fmt.Errorf("上游函数%d没有需要同步的变化", upstreamFuncID)
+		}
+		if d.change.Type != dto.UpstreamChangeRemoved && d.code == "" {
+			return nil, fmt.Errorf("上游函数%s没有保存源码，无法同步", d.upstream.Name)
+		}
+		selected = append(selected, d)
+	}
+
+	funcs := NewRunnerFunc(s.repo.GetDB())
+	funcRepo := repo.NewRunnerFuncRepo(s.repo.GetDB())
+	comment := fmt.Sprintf("同步上游 %s/%s(%s)", upstream.User, upstream.Name, upstream.Version)
+	resp := &dto.SyncUpstreamResp{Synced: []*dto.UpstreamChange{}, Conflicts: []*dto.UpstreamChange{}}
+	for _, d := range selected {
+		if d.change.Conflict && !force {
+			resp.Conflicts = append(resp.Conflicts, d.change)
+			continue
+		}
+		switch d.change.Type {
+		case dto.UpstreamChangeUpdated:
+			if _, err := funcs.UpdateCode(ctx, d.fork.ID, d.code, comment, operator); err != nil {
+				return resp, fmt.Errorf("同步函数%s失败: %w", d.upstream.Name, err)
+			}
+			if err := funcRepo.Update(ctx, d.fork.ID, &model.RunnerFunc{ForkFromVersion: upstream.Version}); err != nil {
+				return resp, fmt.Errorf("更新函数%s的同步版本失败: %w", d.upstream.Name, err)
+			}
+		case dto.UpstreamChangeAdded:
+			tree, err := s.forkTreeOf(ctx, fork, upstream, d.upstream)
+			if err != nil {
+				return resp, err
+			}
+			fc := &model.RunnerFunc{
+				Name:            d.upstream.Name,
+				Title:           d.upstream.Title,
+				Description:     d.upstream.Description,
+				Code:            d.code,
+				TreeID:          tree.ID,
+				User:            fork.User,
+				ForkFromID:      &d.upstream.ID,
+				ForkFromUser:    upstream.User,
+				ForkFromVersion: upstream.Version,
+				Base:            model.Base{CreatedBy: operator, UpdatedBy: operator},
+			}
+			if err := funcs.Create(ctx, fc); err != nil {
+				return resp, fmt.Errorf("同步函数%s失败: %w", d.upstream.Name, err)
+			}
+			d.change.FuncID = fc.ID
+		case dto.UpstreamChangeRemoved:
+			if err := funcs.Delete(ctx, d.fork.ID, operator); err != nil {
+				return resp, fmt.Errorf("删除函数%s失败: %w", d.fork.Name, err)
+			}
+		}
+		resp.Synced = append(resp.Synced, d.change)
+	}
+	// 上游所有的变化都已经同步时，runner的同步版本更新为上游当前的版本
+	resp.ForkFromVersion = fork.ForkFromVersion
+	if _, pending, err := s.upstreamChanges(ctx, fork); err != nil {
+		logger.Warn(ctx, "检查未同步的上游变化失败", zap.Error(err), zap.Int64("id", id))
+	} else if len(pending) == 0 && fork.ForkFromVersion != upstream.Version {
+		update := &model.Runner{ForkFromVersion: upstream.Version}
+		update.UpdatedBy = operator
+		if err := s.repo.Update(ctx, id, update); err != nil {
+			return resp, fmt.Errorf("更新Runner的同步版本失败: %w", err)
+		}
+		resp.ForkFromVersion = upstream.Version
+	}
+	logger.Info(ctx, "同步上游完成", zap.Int64("id", id), zap.Int64("upstream_id", upstream.ID),
+		zap.Int("synced", len(resp.Synced)), zap.Int("conflicts", len(resp.Conflicts)),
+		zap.String("fork_from_version", resp.ForkFromVersion))
+	return resp, nil
+}
+
+// forkTreeOf 找到上游函数所在目录在fork中对应的目录：优先按ForkFromID匹配，其次按package路径匹配
+func (s *Runner) forkTreeOf(ctx context.Context, fork, upstream *model.Runner, up *model.RunnerFunc) (*model.ServiceTree, error) {
+	treeRepo := repo.NewServiceTreeRepo(s.repo.GetDB())
+	if up.TreeID == upstream.TreeID {
+		return treeRepo.Get(ctx, fork.TreeID)
+	}
+	upTree, err := treeRepo.Get(ctx, up.TreeID)
+	if err != nil {
+		return nil, err
+	}
+	if upTree == nil {
+		return nil, fmt.Errorf("上游函数%s所在的目录不存在", up.Name)
+	}
+	packages, err := treeRepo.GetPackagesByRunner(ctx, fork.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range packages {
+		if pkg.ForkFromID != nil && *pkg.ForkFromID == upTree.ID {
+			return pkg, nil
+		}
+	}
+	for _, pkg := range packages {
+		if pkg.GetPackagePath() == upTree.GetPackagePath() {
+			return pkg, nil
+		}
+	}
+	return nil, fmt.Errorf("目录%s在fork中不存在，请先创建后再同步函数%s", upTree.GetPackagePath(), up.Name)
+}
@@ -0,0 +1,33 @@
+package service
+
+import "testing"
+
+func TestClassifyUpstream(t *testing.T) {
+	cases := []struct {
+		name         string
+		st           upstreamState
+		changed      bool
+		forkModified bool
+	}{
+		{"有基准，fork未修改，上游未变化", upstreamState{base: "v1", fork: "v1", upstream: "v1"}, false, false},
+		{"有基准，fork未修改，上游有变化", upstreamState{base: "v1", fork: "v1", upstream: "v2"}, true, false},
+		{"有基准，fork修改，上游未变化", upstreamState{base: "v1", fork: "f1", upstream: "v1"}, false, false},
+		{"有基准，fork修改，上游有变化", upstreamState{base: "v1", fork: "f1", upstream: "v2"}, true, true},
+		{"有基准，fork和上游改成一样", upstreamState{base: "v1", fork: "v2", upstream: "v2"}, false, false},
+		{"没有基准，fork和上游相同", upstreamState{fork: "v1", upstream: "v1"}, false, false},
+		{"没有基准，fork和上游不同", upstreamState{fork: "f1", upstream: "v2"}, true, true},
+		{"上游没有源码", upstreamState{base: "v1", fork: "v1"}, false, false},
+		{"fork删除，上游有变化", upstreamState{base: "v1", fork: "v1", upstream: "v2", forkDeleted: true}, false, false},
+		{"fork删除，上游也删除", upstreamState{base: "v1", fork: "v1", forkDeleted: true, upDeleted: true}, false, false},
+		{"上游删除，fork未修改", upstreamState{base: "v1", fork: "v1", upDeleted: true}, true, false},
+		{"上游删除，fork修改", upstreamState{base: "v1", fork: "f1", upDeleted: true}, true, true},
+		{"上游删除，没有基准", upstreamState{fork: "v1", upDeleted: true}, true, true},
+	}
+	for _, c := range cases {
+		changed, forkModified := classifyUpstream(c.st)
+		if changed != c.changed || forkModified != c.forkModified {
+			t.Errorf("%s: got changed=%v forkModified=%v, want changed=%v forkModified=%v",
+				c.name, changed, forkModified, c.changed, c.forkModified)
+		}
+	}
+}
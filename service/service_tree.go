@@ -47,6 +47,14 @@ func (s *ServiceTree) CreateNode(ctx context.Context, serviceTree *model.Service
 	if serviceTree.ParentID == 0 {
 		return fmt.Errorf("ParentID 不能为0")
 	}
+	// package需要在runcher上创建对应的包，runcher不可用时不创建目录
+	var runcherServiceIns RuncherService
+	if serviceTree.Type == model.ServiceTreeTypePackage {
+		var err error
+		if runcherServiceIns, err = requireRuncher(); err != nil {
+			return err
+		}
+	}
 	// 检查同级目录下名称是否已存在
 	existing, err := s.repo.GetByName(ctx, serviceTree.ParentID, serviceTree.Name)
 	if err != nil {
@@ -76,6 +84,11 @@ func (s *ServiceTree) CreateNode(ctx context.Context, serviceTree *model.Service
 	if err != nil {
 		return err
 	}
+	if serviceTree.Type == model.ServiceTreeTypePackage {
+		if err := checkRunnerDeployable(gotRunner); err != nil {
+			return err
+		}
+	}
 	// 创建服务树（需要先创建以获得ID）
 	if err := s.repo.Create(ctx, serviceTree); err != nil {
 		logger.Error(ctx, "创建服务树失败", err)
@@ -102,8 +115,6 @@ func (s *ServiceTree) CreateNode(ctx context.Context, serviceTree *model.Service
 	}
 
 	if serviceTree.Type == model.ServiceTreeTypePackage {
-		runcherServiceIns := GetRuncherService()
-
 		runner, err := runnerproject.NewRunner(gotRunner.User, gotRunner.Name, gotRunner.Version)
 		if err != nil {
 			return err
@@ -118,7 +129,11 @@ func (s *ServiceTree) CreateNode(ctx context.Context, serviceTree *model.Service
 			Desc:           serviceTree.Description,
 		}
 
-		pkgResp, err := runcherServiceIns.AddBizPackage2(ctx, pkg)
+		nodeCtx, err := withRunnerNode(ctx, gotRunner)
+		if err != nil {
+			return err
+		}
+		pkgResp, err := runcherServiceIns.AddBizPackage2(nodeCtx, pkg)
 		if err != nil {
 			logger.Errorf(ctx, "runcherServiceIns.AddBizPackage err:%s req:%+v  resp:%+v", err.Error(), pkg, pkgResp)
 		}